@@ -0,0 +1,66 @@
+// Command reconcile imports a processor settlement CSV, reconciles it
+// against the payments table and stores the report, e.g.
+//
+//	go run ./cmd/reconcile -file settlement-2026-10-18.csv -date 2026-10-18 -out report.csv
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"payment-service/config"
+	"payment-service/db"
+	"payment-service/repository"
+	"payment-service/service"
+	"time"
+)
+
+func main() {
+	file := flag.String("file", "", "settlement CSV to import")
+	date := flag.String("date", time.Now().UTC().AddDate(0, 0, -1).Format("2006-01-02"), "transaction date (UTC) the file covers")
+	out := flag.String("out", "", "also write the report to this CSV file")
+	flag.Parse()
+
+	if *file == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	day, err := time.Parse("2006-01-02", *date)
+	if err != nil {
+		log.Fatal("invalid -date: ", err)
+	}
+
+	cfg := config.LoadConfig()
+	database, err := db.InitDB(cfg.DBUrl)
+	if err != nil {
+		log.Fatal("failed to connect database: ", err)
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	svc := service.NewReconciliationService(repository.NewReconciliationRepository(database), repository.NewPaymentRepository(database))
+	run, err := svc.Import(filepath.Base(*file), f, day)
+	if err != nil {
+		log.Fatal("reconciliation failed: ", err)
+	}
+
+	fmt.Printf("run %d for %s: %d rows, %d matched, %d missing internally, %d missing at provider, %d amount mismatches\n",
+		run.ID, day.Format("2006-01-02"), run.Rows, run.Matched, run.MissingInternal, run.MissingProvider, run.AmountMismatch)
+
+	if *out != "" {
+		report, err := os.Create(*out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer report.Close()
+		if err := service.WriteReportCSV(report, run); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
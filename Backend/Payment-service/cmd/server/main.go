@@ -1,37 +1,153 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"payment-service/config"
 	"payment-service/db"
 	handlers "payment-service/handler"
+	"payment-service/middleware"
+	"payment-service/money"
 	"payment-service/repository"
+	"payment-service/risk"
 	"payment-service/service"
+	"time"
 
+	"github.com/gajare/BAJAR-App/Backend/pkg/authz"
 	"github.com/gorilla/mux"
 )
 
 func main() {
 	cfg := config.LoadConfig()
+	if _, err := money.NormalizeCurrency(cfg.BaseCurrency); err != nil {
+		log.Fatal("invalid BASE_CURRENCY: ", cfg.BaseCurrency)
+	}
 	log.Println("Configuration loaded")
 	database, err := db.InitDB(cfg.DBUrl)
 	if err != nil {
 		log.Fatal("failed to connect database: ", err)
 	}
 
+	rateSvc := service.NewExchangeRateService(repository.NewExchangeRateRepository(database), cfg.BaseCurrency, cfg.Currencies)
+	if cfg.RatesFile != "" {
+		if err := rateSvc.LoadFile(cfg.RatesFile); err != nil {
+			log.Fatal("failed to load exchange rates: ", err)
+		}
+		log.Println("Exchange rates loaded from", cfg.RatesFile)
+	}
+	rateHandler := &handlers.ExchangeRateHandler{Service: rateSvc}
+
+	methodSvc := service.NewSavedPaymentMethodService(repository.NewSavedPaymentMethodRepository(database))
+	methodHandler := &handlers.SavedPaymentMethodHandler{Service: methodSvc}
+
+	riskRepo := repository.NewRiskRepository(database)
+	riskHandler := &handlers.RiskHandler{Repo: riskRepo}
+	large, err := money.Parse(cfg.RiskLargeAmount, cfg.BaseCurrency)
+	if err != nil {
+		log.Fatal("invalid RISK_LARGE_AMOUNT: ", err)
+	}
+	veryLarge, err := money.Parse(cfg.RiskVeryLargeAmount, cfg.BaseCurrency)
+	if err != nil {
+		log.Fatal("invalid RISK_VERY_LARGE_AMOUNT: ", err)
+	}
+	riskPipeline := &risk.Pipeline{
+		ReviewScore: cfg.RiskReviewScore,
+		DenyScore:   cfg.RiskDenyScore,
+		Rules: []risk.Rule{
+			&risk.BlocklistRule{Store: riskRepo, Score: 100},
+			&risk.VelocityRule{Counter: riskRepo, Window: time.Hour, MaxPerUser: 10, MaxPerIP: 20, MaxPerCard: 5, Score: 30},
+			&risk.AmountRule{Thresholds: []risk.AmountThreshold{{Minor: large.Minor, Score: 25}, {Minor: veryLarge.Minor, Score: 50}}},
+			&risk.CountryMismatchRule{Score: 25},
+		},
+	}
+
+	ledgerSvc := service.NewLedgerService(repository.NewLedgerRepository(database))
+	ledgerHandler := &handlers.LedgerHandler{Service: ledgerSvc}
+
 	repo := repository.NewPaymentRepository(database)
-	svc := service.NewPaymentService(repo)
+	svc := service.NewPaymentService(repo, rateSvc, methodSvc, riskPipeline, ledgerSvc, cfg.AuthorizationTTL)
 	handler := &handlers.PaymentHandler{Service: svc}
 
+	reconHandler := &handlers.ReconciliationHandler{
+		Service: service.NewReconciliationService(repository.NewReconciliationRepository(database), repo),
+	}
+
+	payoutSvc, err := service.NewPayoutService(repository.NewPayoutRepository(database), svc, cfg.SellerCommissionBPS)
+	if err != nil {
+		log.Fatal("invalid SELLER_COMMISSION_BPS: ", err)
+	}
+	payoutHandler := &handlers.PayoutHandler{Service: payoutSvc}
+
+	go service.RunAuthorizationExpiry(context.Background(), svc, cfg.AuthorizationSweepInterval)
+	go service.RunGiftCardExpiry(context.Background(), ledgerSvc, cfg.GiftCardExpiryInterval)
+
 	r := mux.NewRouter()
 
-	r.HandleFunc("/payments", handler.CreatePayment).Methods("POST")
-	r.HandleFunc("/payments/{id:[0-9]+}", handler.GetPayment).Methods("GET")
-	r.HandleFunc("/payments", handler.ListPayments).Methods("GET")
-	r.HandleFunc("/payments/{id:[0-9]+}/status", handler.UpdatePaymentStatus).Methods("PUT")
-	r.HandleFunc("/payments/{id:[0-9]+}/refund", handler.RefundPayment).Methods("POST")
+	authenticated := middleware.JWTAuth(authz.NewJWKSVerifier(cfg.JWKSURL, cfg.JWKSCacheTTL))
+	// need wraps a handler that requires a permission; the caller must
+	// already be authenticated.
+	need := func(perm string, h http.HandlerFunc) http.Handler {
+		return middleware.Require(perm)(h)
+	}
+	staff := func(perm string, h http.HandlerFunc) http.Handler {
+		return authenticated(need(perm, h))
+	}
+
+	r.Handle("/payments", authenticated(http.HandlerFunc(handler.CreatePayment))).Methods("POST")
+	r.Handle("/payments/{id:[0-9]+}", authenticated(http.HandlerFunc(handler.GetPayment))).Methods("GET")
+	r.Handle("/payments", authenticated(http.HandlerFunc(handler.ListPayments))).Methods("GET")
+	r.Handle("/payments/{id:[0-9]+}/status", staff(authz.PermPaymentsWrite, handler.UpdatePaymentStatus)).Methods("PUT")
+	r.Handle("/payments/{id:[0-9]+}/refund", staff(authz.PermPaymentsWrite, handler.RefundPayment)).Methods("POST")
+	r.Handle("/payments/authorize", authenticated(http.HandlerFunc(handler.AuthorizePayment))).Methods("POST")
+	r.Handle("/payments/{id:[0-9]+}/capture", staff(authz.PermPaymentsWrite, handler.CapturePayment)).Methods("POST")
+	r.Handle("/payments/{id:[0-9]+}/void", staff(authz.PermPaymentsWrite, handler.VoidPayment)).Methods("POST")
+	r.Handle("/payments/{id:[0-9]+}/collect", staff(authz.PermPaymentsCollect, handler.CollectCashPayment)).Methods("POST")
+
+	r.Handle("/orders/{orderID:[0-9]+}/payments", authenticated(http.HandlerFunc(handler.PayOrder))).Methods("POST")
+	r.Handle("/orders/{orderID:[0-9]+}/payments", authenticated(http.HandlerFunc(handler.GetOrderPayments))).Methods("GET")
+
+	r.Handle("/users/{userID:[0-9]+}/payment-methods", authenticated(http.HandlerFunc(methodHandler.SavePaymentMethod))).Methods("POST")
+	r.Handle("/users/{userID:[0-9]+}/payment-methods", authenticated(http.HandlerFunc(methodHandler.ListPaymentMethods))).Methods("GET")
+	r.Handle("/users/{userID:[0-9]+}/payment-methods/{id:[0-9]+}/default", authenticated(http.HandlerFunc(methodHandler.SetDefaultPaymentMethod))).Methods("PUT")
+	r.Handle("/users/{userID:[0-9]+}/payment-methods/{id:[0-9]+}", authenticated(http.HandlerFunc(methodHandler.DeletePaymentMethod))).Methods("DELETE")
+
+	r.Handle("/gift-cards/balance", authenticated(http.HandlerFunc(ledgerHandler.GiftCardBalance))).Methods("POST")
+	r.Handle("/users/{userID:[0-9]+}/store-credit", authenticated(http.HandlerFunc(ledgerHandler.StoreCredit))).Methods("GET")
+
+	r.Handle("/sellers/{sellerID:[0-9]+}/earnings", authenticated(http.HandlerFunc(payoutHandler.Earnings))).Methods("GET")
+	r.Handle("/sellers/{sellerID:[0-9]+}/payouts", authenticated(http.HandlerFunc(payoutHandler.SellerPayouts))).Methods("GET")
+
+	// public
+	r.HandleFunc("/exchange-rates", rateHandler.ListLatestRates).Methods("GET")
+	r.HandleFunc("/exchange-rates/{currency:[A-Za-z]{3}}/history", rateHandler.RateHistory).Methods("GET")
+
+	// staff only, each route with its own permission
+	admin := r.PathPrefix("/admin").Subrouter()
+	admin.Use(authenticated)
+	admin.Handle("/exchange-rates", need(authz.PermFinanceWrite, rateHandler.SetRate)).Methods("POST")
+
+	admin.Handle("/reconciliations", need(authz.PermFinanceWrite, reconHandler.ImportSettlement)).Methods("POST")
+	admin.Handle("/reconciliations", need(authz.PermFinanceWrite, reconHandler.ListRuns)).Methods("GET")
+	admin.Handle("/reconciliations/{id:[0-9]+}", need(authz.PermFinanceWrite, reconHandler.GetRun)).Methods("GET")
+	admin.Handle("/reconciliations/{id:[0-9]+}/report.csv", need(authz.PermFinanceWrite, reconHandler.DownloadReport)).Methods("GET")
+
+	admin.Handle("/reviews", need(authz.PermRiskReview, handler.ListHeldPayments)).Methods("GET")
+	admin.Handle("/reviews/{id:[0-9]+}/approve", need(authz.PermRiskReview, handler.ApprovePayment)).Methods("POST")
+	admin.Handle("/reviews/{id:[0-9]+}/reject", need(authz.PermRiskReview, handler.RejectPayment)).Methods("POST")
+
+	admin.Handle("/blocklist", need(authz.PermRiskReview, riskHandler.ListBlocklist)).Methods("GET")
+	admin.Handle("/blocklist", need(authz.PermRiskReview, riskHandler.AddBlocklistEntry)).Methods("POST")
+	admin.Handle("/blocklist/{id:[0-9]+}", need(authz.PermRiskReview, riskHandler.DeleteBlocklistEntry)).Methods("DELETE")
+
+	admin.Handle("/gift-cards", need(authz.PermFinanceWrite, ledgerHandler.IssueGiftCard)).Methods("POST")
+	admin.Handle("/gift-cards/{id:[0-9]+}", need(authz.PermFinanceWrite, ledgerHandler.GetGiftCard)).Methods("GET")
+	admin.Handle("/gift-cards/{id:[0-9]+}/entries", need(authz.PermFinanceWrite, ledgerHandler.GiftCardHistory)).Methods("GET")
+
+	admin.Handle("/payouts", need(authz.PermPayoutsWrite, payoutHandler.RunPayouts)).Methods("POST")
+	admin.Handle("/payouts", need(authz.PermPayoutsRead, payoutHandler.ListPayouts)).Methods("GET")
+	admin.Handle("/payouts/{id:[0-9]+}/paid", need(authz.PermPayoutsWrite, payoutHandler.MarkPayoutPaid)).Methods("POST")
 
 	addr := ":" + cfg.Port
 	fmt.Println("Server running on", addr)
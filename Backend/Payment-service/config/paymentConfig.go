@@ -1,13 +1,94 @@
 package config
 
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
 type Config struct {
 	DBUrl string
 	Port  string
+	// JWKSURL is where the User-service publishes the keys its access
+	// tokens are signed with; they are cached for JWKSCacheTTL.
+	JWKSURL      string
+	JWKSCacheTTL time.Duration
+
+	// BaseCurrency is the currency the books are kept in; every payment
+	// also records its amount converted into it.
+	BaseCurrency string
+	// Currencies lists the currencies customers may be charged in.
+	Currencies []string
+	// RatesFile optionally points at a JSON file of exchange rates that is
+	// loaded into the rate history at startup.
+	RatesFile string
+
+	// AuthorizationTTL is how long an uncaptured authorization is held
+	// before the expiry job voids it; the job runs every
+	// AuthorizationSweepInterval.
+	AuthorizationTTL           time.Duration
+	AuthorizationSweepInterval time.Duration
+
+	// GiftCardExpiryInterval is how often expired gift cards are written
+	// off.
+	GiftCardExpiryInterval time.Duration
+
+	// Risk scoring: a total of RiskReviewScore holds a payment for manual
+	// review, RiskDenyScore declines it. Large amounts are decimal strings
+	// in the base currency.
+	RiskReviewScore     int
+	RiskDenyScore       int
+	RiskLargeAmount     string
+	RiskVeryLargeAmount string
+
+	// SellerCommissionBPS is the platform's commission on marketplace
+	// sellers' takings, in basis points (1000 is 10%).
+	SellerCommissionBPS int
 }
 
 func LoadConfig() Config {
 	return Config{
-		DBUrl: "host=localhost user=postgres password=1234 dbname=paymentsdb port=5432 sslmode=disable",
-		Port:  "8080",
+		DBUrl:        getEnv("DATABASE_DSN", "host=localhost user=postgres password=1234 dbname=paymentsdb port=5432 sslmode=disable"),
+		Port:         getEnv("PORT", "8080"),
+		BaseCurrency: strings.ToUpper(strings.TrimSpace(getEnv("BASE_CURRENCY", "INR"))),
+		Currencies:   strings.Split(getEnv("CURRENCIES", "INR,USD,EUR"), ","),
+		RatesFile:    getEnv("EXCHANGE_RATES_FILE", ""),
+
+		JWKSURL:      getEnv("JWKS_URL", "http://localhost:8080/.well-known/jwks.json"),
+		JWKSCacheTTL: getDuration("JWKS_CACHE_TTL", 10*time.Minute),
+
+		AuthorizationTTL:           getDuration("AUTHORIZATION_TTL", 7*24*time.Hour),
+		AuthorizationSweepInterval: getDuration("AUTHORIZATION_SWEEP_INTERVAL", 15*time.Minute),
+
+		GiftCardExpiryInterval: getDuration("GIFT_CARD_EXPIRY_INTERVAL", time.Hour),
+
+		RiskReviewScore:     getInt("RISK_REVIEW_SCORE", 50),
+		RiskDenyScore:       getInt("RISK_DENY_SCORE", 80),
+		RiskLargeAmount:     getEnv("RISK_LARGE_AMOUNT", "50000"),
+		RiskVeryLargeAmount: getEnv("RISK_VERY_LARGE_AMOUNT", "200000"),
+
+		SellerCommissionBPS: getInt("SELLER_COMMISSION_BPS", 1000),
+	}
+}
+
+func getEnv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func getDuration(key string, fallback time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(key)); err == nil && d > 0 {
+		return d
+	}
+	return fallback
+}
+
+func getInt(key string, fallback int) int {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return n
 	}
+	return fallback
 }
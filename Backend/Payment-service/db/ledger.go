@@ -0,0 +1,27 @@
+package db
+
+import "gorm.io/gorm"
+
+// protectLedger makes journal entries append-only in the database itself,
+// so not even a stray UPDATE from a console can rewrite history.
+func protectLedger(db *gorm.DB) error {
+	statements := []string{
+		`CREATE OR REPLACE FUNCTION ledger_append_only() RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION 'ledger journal is append-only';
+		END
+		$$ LANGUAGE plpgsql`,
+		`DROP TRIGGER IF EXISTS journal_entries_append_only ON journal_entries`,
+		`CREATE TRIGGER journal_entries_append_only BEFORE UPDATE OR DELETE ON journal_entries
+			FOR EACH ROW EXECUTE FUNCTION ledger_append_only()`,
+		`DROP TRIGGER IF EXISTS journal_lines_append_only ON journal_lines`,
+		`CREATE TRIGGER journal_lines_append_only BEFORE UPDATE OR DELETE ON journal_lines
+			FOR EACH ROW EXECUTE FUNCTION ledger_append_only()`,
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
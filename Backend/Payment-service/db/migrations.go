@@ -0,0 +1,115 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migration is a one-off schema or data change that AutoMigrate cannot
+// express. Each runs once, in order, inside a transaction, before
+// AutoMigrate brings the tables in line with the models.
+type migration struct {
+	ID string
+	Up func(tx *gorm.DB) error
+}
+
+var migrations = []migration{
+	{ID: "0001_payment_amount_minor_units", Up: migratePaymentAmountToMinorUnits},
+	{ID: "0002_payment_base_amount", Up: migratePaymentBaseAmount},
+	{ID: "0003_payment_captured_amount", Up: migratePaymentCapturedAmount},
+}
+
+type schemaMigration struct {
+	ID        string    `gorm:"primaryKey;size:100"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (schemaMigration) TableName() string { return "schema_migrations" }
+
+func runMigrations(db *gorm.DB) error {
+	if err := db.AutoMigrate(&schemaMigration{}); err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		var applied int64
+		if err := db.Model(&schemaMigration{}).Where("id = ?", m.ID).Count(&applied).Error; err != nil {
+			return err
+		}
+		if applied > 0 {
+			continue
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&schemaMigration{ID: m.ID, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %s: %w", m.ID, err)
+		}
+	}
+	return nil
+}
+
+// migratePaymentAmountToMinorUnits replaces the numeric(10,2) amount column
+// with integer paise plus a currency code. Every payment taken before the
+// change was in rupees, and numeric*100 is exact, so nothing is rounded.
+func migratePaymentAmountToMinorUnits(tx *gorm.DB) error {
+	if !tx.Migrator().HasTable("payments") || !tx.Migrator().HasColumn("payments", "amount") {
+		return nil
+	}
+	statements := []string{
+		`ALTER TABLE payments ADD COLUMN IF NOT EXISTS amount_minor bigint`,
+		`ALTER TABLE payments ADD COLUMN IF NOT EXISTS amount_currency varchar(3)`,
+		`UPDATE payments SET amount_minor = (COALESCE(amount, 0) * 100)::bigint, amount_currency = 'INR'`,
+		`ALTER TABLE payments ALTER COLUMN amount_minor SET NOT NULL, ALTER COLUMN amount_currency SET NOT NULL`,
+		`ALTER TABLE payments DROP COLUMN amount`,
+	}
+	for _, stmt := range statements {
+		if err := tx.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migratePaymentBaseAmount backfills the base currency amount. Existing
+// payments were all taken in the base currency, so the rate is 1.
+func migratePaymentBaseAmount(tx *gorm.DB) error {
+	if !tx.Migrator().HasTable("payments") || tx.Migrator().HasColumn("payments", "base_amount_minor") {
+		return nil
+	}
+	statements := []string{
+		`ALTER TABLE payments ADD COLUMN base_amount_minor bigint, ADD COLUMN base_amount_currency varchar(3)`,
+		`UPDATE payments SET base_amount_minor = amount_minor, base_amount_currency = amount_currency`,
+		`ALTER TABLE payments ALTER COLUMN base_amount_minor SET NOT NULL, ALTER COLUMN base_amount_currency SET NOT NULL`,
+	}
+	for _, stmt := range statements {
+		if err := tx.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migratePaymentCapturedAmount adds the captured amount. Payments taken
+// before authorize/capture existed were never partially captured, so it
+// starts at zero in the payment's own currency.
+func migratePaymentCapturedAmount(tx *gorm.DB) error {
+	if !tx.Migrator().HasTable("payments") || tx.Migrator().HasColumn("payments", "captured_minor") {
+		return nil
+	}
+	statements := []string{
+		`ALTER TABLE payments ADD COLUMN captured_minor bigint NOT NULL DEFAULT 0, ADD COLUMN captured_currency varchar(3)`,
+		`UPDATE payments SET captured_currency = amount_currency`,
+		`ALTER TABLE payments ALTER COLUMN captured_currency SET NOT NULL`,
+	}
+	for _, stmt := range statements {
+		if err := tx.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
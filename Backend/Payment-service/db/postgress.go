@@ -13,7 +13,17 @@ func InitDB(dsn string) (*gorm.DB, error) {
 		return nil, err
 	}
 
-	if err := db.AutoMigrate(&models.Payment{}); err != nil {
+	if err := runMigrations(db); err != nil {
+		return nil, err
+	}
+
+	if err := db.AutoMigrate(&models.Payment{}, &models.ExchangeRate{}, &models.SavedPaymentMethod{},
+		&models.ReconciliationRun{}, &models.ReconciliationItem{}, &models.BlocklistEntry{}, &models.OrderPayment{},
+		&models.LedgerAccount{}, &models.JournalEntry{}, &models.JournalLine{}, &models.GiftCard{},
+		&models.SellerShare{}, &models.SellerPayout{}); err != nil {
+		return nil, err
+	}
+	if err := protectLedger(db); err != nil {
 		return nil, err
 	}
 
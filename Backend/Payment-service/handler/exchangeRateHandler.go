@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"payment-service/service"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type ExchangeRateHandler struct {
+	Service service.ExchangeRateService
+}
+
+func (h *ExchangeRateHandler) ListLatestRates(w http.ResponseWriter, r *http.Request) {
+	rates, err := h.Service.LatestRates()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"base":  h.Service.BaseCurrency(),
+		"rates": rates,
+	})
+}
+
+func (h *ExchangeRateHandler) RateHistory(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	rates, err := h.Service.History(mux.Vars(r)["currency"], limit)
+	if err != nil {
+		http.Error(w, err.Error(), statusFor(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rates)
+}
+
+func (h *ExchangeRateHandler) SetRate(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Currency    string      `json:"currency"`
+		Rate        json.Number `json:"rate"`
+		EffectiveAt time.Time   `json:"effective_at"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rate, err := h.Service.SetRate(body.Currency, body.Rate.String(), "admin", body.EffectiveAt)
+	if err != nil {
+		http.Error(w, err.Error(), statusFor(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rate)
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"payment-service/middleware"
+	"payment-service/money"
+	"payment-service/service"
+	"strconv"
+	"time"
+
+	"github.com/gajare/BAJAR-App/Backend/pkg/authz"
+	"github.com/gorilla/mux"
+)
+
+type LedgerHandler struct {
+	Service service.LedgerService
+}
+
+// IssueGiftCard answers with the card and its code. The code is shown only
+// this once.
+func (h *LedgerHandler) IssueGiftCard(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Amount    money.Money `json:"amount"`
+		ExpiresAt *time.Time  `json:"expires_at"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	issuer, _ := middleware.IdentityFromContext(r.Context())
+	card, code, err := h.Service.IssueGiftCard(body.Amount, body.ExpiresAt, issuer.UserID)
+	if err != nil {
+		http.Error(w, err.Error(), statusFor(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(struct {
+		service.GiftCardBalance
+		Code string `json:"code"`
+	}{card, code})
+}
+
+// GiftCardBalance looks a card up by code. The code is posted rather than
+// put in the URL so it does not end up in access logs.
+func (h *LedgerHandler) GiftCardBalance(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	card, err := h.Service.GiftCardByCode(body.Code)
+	if err != nil {
+		http.Error(w, err.Error(), statusFor(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(card)
+}
+
+func (h *LedgerHandler) GetGiftCard(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	card, err := h.Service.GiftCard(id)
+	if err != nil {
+		http.Error(w, err.Error(), statusFor(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(card)
+}
+
+func (h *LedgerHandler) GiftCardHistory(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	entries, err := h.Service.GiftCardHistory(id, limit)
+	if err != nil {
+		http.Error(w, err.Error(), statusFor(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// StoreCredit lists a user's store credit balances, one per currency.
+func (h *LedgerHandler) StoreCredit(w http.ResponseWriter, r *http.Request) {
+	userID, _ := strconv.ParseUint(mux.Vars(r)["userID"], 10, 64)
+	if caller, _ := middleware.IdentityFromContext(r.Context()); !caller.Can(authz.PermPaymentsRead) && caller.UserID != userID {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	balances, err := h.Service.StoreCredit(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(balances)
+}
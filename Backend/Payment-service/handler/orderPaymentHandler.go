@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"payment-service/middleware"
+	"payment-service/models"
+	"payment-service/money"
+	"strconv"
+
+	"github.com/gajare/BAJAR-App/Backend/pkg/authz"
+	"github.com/gorilla/mux"
+)
+
+type payOrderRequest struct {
+	UserID  uint64           `json:"user_id"`
+	Total   money.Money      `json:"total"`
+	Tenders []models.Payment `json:"tenders"`
+	// Shares is what each marketplace seller's lines come to. Only the
+	// order service and staff may set them.
+	Shares []models.SellerShare `json:"shares"`
+}
+
+// PayOrder takes payment for an order in one or more tenders, e.g.
+// {"user_id":1,"total":{...},"tenders":[{"payment_method":"gift_card",...},{"payment_method":"cod",...}]}.
+func (h *PaymentHandler) PayOrder(w http.ResponseWriter, r *http.Request) {
+	orderID, _ := strconv.ParseUint(mux.Vars(r)["orderID"], 10, 64)
+	var req payOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !actsFor(r, req.UserID, authz.PermPaymentsCreate) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if caller, _ := middleware.IdentityFromContext(r.Context()); len(req.Shares) > 0 && !caller.Can(authz.PermPaymentsCreate) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	for i := range req.Tenders {
+		req.Tenders[i].ClientIP = shopperIP(r, req.Tenders[i].ClientIP)
+	}
+
+	order := models.OrderPayment{OrderID: orderID, UserID: req.UserID, Total: req.Total, Shares: req.Shares}
+	summary, err := h.Service.PayOrder(order, req.Tenders)
+	if err != nil {
+		http.Error(w, err.Error(), statusFor(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	status := http.StatusCreated
+	for _, t := range summary.Tenders {
+		if t.Status == models.StatusOnHold {
+			status = http.StatusAccepted
+		}
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(summary)
+}
+
+func (h *PaymentHandler) GetOrderPayments(w http.ResponseWriter, r *http.Request) {
+	orderID, _ := strconv.ParseUint(mux.Vars(r)["orderID"], 10, 64)
+	summary, err := h.Service.GetOrderPaymentSummary(orderID)
+	if err != nil {
+		http.Error(w, err.Error(), statusFor(err))
+		return
+	}
+	if caller, _ := middleware.IdentityFromContext(r.Context()); !caller.Can(authz.PermPaymentsRead) && caller.UserID != summary.UserID {
+		http.Error(w, "order payment not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+// CollectCashPayment is called by the delivery flow when the courier hands
+// over the cash for a cash on delivery payment.
+func (h *PaymentHandler) CollectCashPayment(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	courier, _ := middleware.IdentityFromContext(r.Context())
+	payment, err := h.Service.CollectCashPayment(id, courier.UserID)
+	if err != nil {
+		http.Error(w, err.Error(), statusFor(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payment)
+}
@@ -2,12 +2,22 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"payment-service/middleware"
 	"payment-service/models"
+	"payment-service/money"
+	"payment-service/repository"
 	"payment-service/service"
 	"strconv"
+	"time"
 
+	"github.com/gajare/BAJAR-App/Backend/pkg/authz"
 	"github.com/gorilla/mux"
+	"gorm.io/gorm"
 )
 
 type PaymentHandler struct {
@@ -20,13 +30,17 @@ func (h *PaymentHandler) CreatePayment(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if !actsFor(r, payment.UserID, authz.PermPaymentsCreate) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	payment.ClientIP = shopperIP(r, payment.ClientIP)
 	created, err := h.Service.CreatePayment(payment)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), statusFor(err))
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(created)
+	writeCreated(w, created)
 }
 func (h *PaymentHandler) GetPayment(w http.ResponseWriter, r *http.Request) {
 	idStr := mux.Vars(r)["id"]
@@ -36,18 +50,117 @@ func (h *PaymentHandler) GetPayment(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
+	// Customers only see their own payments; pretend others do not exist.
+	if caller, _ := middleware.IdentityFromContext(r.Context()); !caller.Can(authz.PermPaymentsRead) && caller.UserID != payment.UserID {
+		http.Error(w, "payment not found", http.StatusNotFound)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(payment)
 }
 
+// ListPayments supports the query parameters status, method, order_id,
+// user_id (staff only), currency with min_amount/max_amount, from/to
+// (RFC 3339 or YYYY-MM-DD; "to" dates are inclusive), limit and cursor.
 func (h *PaymentHandler) ListPayments(w http.ResponseWriter, r *http.Request) {
-	payments, err := h.Service.ListPayments()
+	filter, err := parsePaymentFilter(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	caller, _ := middleware.IdentityFromContext(r.Context())
+	if !caller.Can(authz.PermPaymentsRead) {
+		filter.UserID = &caller.UserID
+	}
+
+	page, err := h.Service.ListPayments(filter, r.URL.Query().Get("cursor"))
+	if err != nil {
+		http.Error(w, err.Error(), statusFor(err))
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(payments)
+	json.NewEncoder(w).Encode(page)
+}
+
+func parsePaymentFilter(r *http.Request) (repository.PaymentFilter, error) {
+	q := r.URL.Query()
+	f := repository.PaymentFilter{
+		Status: q.Get("status"),
+		Method: q.Get("method"),
+	}
+	var err error
+	if f.UserID, err = optionalUint(q.Get("user_id"), "user_id"); err != nil {
+		return f, err
+	}
+	if f.OrderID, err = optionalUint(q.Get("order_id"), "order_id"); err != nil {
+		return f, err
+	}
+	if limit := q.Get("limit"); limit != "" {
+		if f.Limit, err = strconv.Atoi(limit); err != nil {
+			return f, errors.New("limit must be a number")
+		}
+	}
+
+	if q.Get("currency") != "" {
+		if f.Currency, err = money.NormalizeCurrency(q.Get("currency")); err != nil {
+			return f, err
+		}
+	}
+	for _, bound := range []struct {
+		param string
+		dst   **int64
+	}{{"min_amount", &f.MinAmount}, {"max_amount", &f.MaxAmount}} {
+		v := q.Get(bound.param)
+		if v == "" {
+			continue
+		}
+		if f.Currency == "" {
+			return f, errors.New("currency is required with min_amount or max_amount")
+		}
+		m, err := money.Parse(v, f.Currency)
+		if err != nil {
+			return f, fmt.Errorf("%s: %w", bound.param, err)
+		}
+		*bound.dst = &m.Minor
+	}
+
+	if f.From, err = parseTimeParam(q.Get("from"), false); err != nil {
+		return f, err
+	}
+	if f.To, err = parseTimeParam(q.Get("to"), true); err != nil {
+		return f, err
+	}
+	return f, nil
+}
+
+func optionalUint(v, name string) (*uint64, error) {
+	if v == "" {
+		return nil, nil
+	}
+	n, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a number", name)
+	}
+	return &n, nil
+}
+
+// parseTimeParam accepts RFC 3339 timestamps or plain dates. A plain date
+// used as an upper bound covers the whole day.
+func parseTimeParam(v string, upper bool) (*time.Time, error) {
+	if v == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %q", v)
+	}
+	if upper {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
 }
 
 func (h *PaymentHandler) UpdatePaymentStatus(w http.ResponseWriter, r *http.Request) {
@@ -55,16 +168,17 @@ func (h *PaymentHandler) UpdatePaymentStatus(w http.ResponseWriter, r *http.Requ
 	id, _ := strconv.ParseUint(idStr, 10, 64)
 
 	var body struct {
-		Status string `json:"status"`
+		Status            string `json:"status"`
+		ProviderReference string `json:"provider_reference"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	updated, err := h.Service.UpdatePaymentStatus(id, body.Status)
+	updated, err := h.Service.UpdatePaymentStatus(id, body.Status, body.ProviderReference)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), statusFor(err))
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -75,11 +189,200 @@ func (h *PaymentHandler) RefundPayment(w http.ResponseWriter, r *http.Request) {
 	idStr := mux.Vars(r)["id"]
 	id, _ := strconv.ParseUint(idStr, 10, 64)
 
-	refunded, err := h.Service.RefundPayment(id)
+	refund := h.Service.RefundPayment
+	if r.URL.Query().Get("to") == "store_credit" {
+		refund = h.Service.RefundToStoreCredit
+	}
+	refunded, err := refund(id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), statusFor(err))
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(refunded)
 }
+
+func (h *PaymentHandler) AuthorizePayment(w http.ResponseWriter, r *http.Request) {
+	var payment models.Payment
+	if err := json.NewDecoder(r.Body).Decode(&payment); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !actsFor(r, payment.UserID, authz.PermPaymentsCreate) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	payment.ClientIP = shopperIP(r, payment.ClientIP)
+	authorized, err := h.Service.AuthorizePayment(payment)
+	if err != nil {
+		http.Error(w, err.Error(), statusFor(err))
+		return
+	}
+	writeCreated(w, authorized)
+}
+
+// writeCreated answers 202 for payments held for review so callers know the
+// outcome is not final yet.
+func writeCreated(w http.ResponseWriter, p models.Payment) {
+	w.Header().Set("Content-Type", "application/json")
+	if p.Status == models.StatusOnHold {
+		w.WriteHeader(http.StatusAccepted)
+	} else {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(p)
+}
+
+// actsFor reports whether the caller may act on userID's behalf: it is
+// that user, or it holds perm (staff, or a service such as the order
+// service).
+func actsFor(r *http.Request, userID uint64, perm string) bool {
+	caller, _ := middleware.IdentityFromContext(r.Context())
+	return caller.Can(perm) || !caller.IsService() && caller.UserID == userID
+}
+
+// shopperIP is the address risk checks are run against: the one the
+// request came from, unless a service such as the order service forwards
+// the shopper's own. Anyone else could dodge the IP rules with it.
+func shopperIP(r *http.Request, forwarded string) string {
+	caller, _ := middleware.IdentityFromContext(r.Context())
+	if caller.IsService() && net.ParseIP(forwarded) != nil {
+		return forwarded
+	}
+	return clientIP(r)
+}
+
+// clientIP is the address the request came from, without the port.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (h *PaymentHandler) ListHeldPayments(w http.ResponseWriter, r *http.Request) {
+	payments, err := h.Service.ListHeldPayments()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payments)
+}
+
+func (h *PaymentHandler) ApprovePayment(w http.ResponseWriter, r *http.Request) {
+	h.reviewPayment(w, r, true)
+}
+
+func (h *PaymentHandler) RejectPayment(w http.ResponseWriter, r *http.Request) {
+	h.reviewPayment(w, r, false)
+}
+
+func (h *PaymentHandler) reviewPayment(w http.ResponseWriter, r *http.Request, approve bool) {
+	idStr := mux.Vars(r)["id"]
+	id, _ := strconv.ParseUint(idStr, 10, 64)
+
+	var body struct {
+		Note string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	reviewer, _ := middleware.IdentityFromContext(r.Context())
+
+	reviewed, err := h.Service.ReviewPayment(id, approve, reviewer.UserID, body.Note)
+	if err != nil {
+		http.Error(w, err.Error(), statusFor(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reviewed)
+}
+
+func (h *PaymentHandler) CapturePayment(w http.ResponseWriter, r *http.Request) {
+	idStr := mux.Vars(r)["id"]
+	id, _ := strconv.ParseUint(idStr, 10, 64)
+
+	// An empty body captures everything that is left.
+	var body struct {
+		Amount *money.Money `json:"amount"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	captured, err := h.Service.CapturePayment(id, body.Amount)
+	if err != nil {
+		http.Error(w, err.Error(), statusFor(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(captured)
+}
+
+func (h *PaymentHandler) VoidPayment(w http.ResponseWriter, r *http.Request) {
+	idStr := mux.Vars(r)["id"]
+	id, _ := strconv.ParseUint(idStr, 10, 64)
+
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	voided, err := h.Service.VoidPayment(id, body.Reason)
+	if err != nil {
+		http.Error(w, err.Error(), statusFor(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(voided)
+}
+
+// statusFor maps validation errors to 400, missing records to 404, state
+// conflicts to 409 and everything else to 500.
+func statusFor(err error) int {
+	switch {
+	case errors.Is(err, service.ErrPaymentMethodNotFound),
+		errors.Is(err, service.ErrGiftCardNotFound),
+		errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrPaymentDeclined),
+		errors.Is(err, service.ErrInsufficientBalance),
+		errors.Is(err, service.ErrGiftCardExpired):
+		return http.StatusPaymentRequired
+	case errors.Is(err, service.ErrInvalidTransition),
+		errors.Is(err, service.ErrAuthorizationExpired),
+		errors.Is(err, service.ErrOrderAlreadyPaid),
+		errors.Is(err, repository.ErrShareTaken),
+		errors.Is(err, repository.ErrAlreadyReversed),
+		errors.Is(err, repository.ErrPayoutNotPending):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrCaptureTooLarge),
+		errors.Is(err, service.ErrInvalidCursor),
+		errors.Is(err, service.ErrTenderMismatch),
+		errors.Is(err, service.ErrShareMismatch),
+		errors.Is(err, service.ErrMissingReference),
+		errors.Is(err, service.ErrInvalidTender):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrInvalidPaymentMethod),
+		errors.Is(err, service.ErrRawCardData):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrInvalidAmount),
+		errors.Is(err, service.ErrUnsupportedCurrency),
+		errors.Is(err, service.ErrRateNotFound),
+		errors.Is(err, money.ErrInvalidRate),
+		errors.Is(err, money.ErrInvalidCurrency),
+		errors.Is(err, money.ErrInvalidAmount),
+		errors.Is(err, money.ErrTooPrecise),
+		errors.Is(err, money.ErrCurrencyMismatch),
+		errors.Is(err, money.ErrOverflow):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"payment-service/middleware"
+	"payment-service/service"
+	"strconv"
+
+	"github.com/gajare/BAJAR-App/Backend/pkg/authz"
+	"github.com/gorilla/mux"
+)
+
+type PayoutHandler struct {
+	Service service.PayoutService
+}
+
+// Earnings is a seller's unpaid takings per currency, for its finance
+// members and for finance staff.
+func (h *PayoutHandler) Earnings(w http.ResponseWriter, r *http.Request) {
+	sellerID, ok := sellerFor(w, r)
+	if !ok {
+		return
+	}
+	earnings, err := h.Service.Earnings(sellerID)
+	if err != nil {
+		http.Error(w, err.Error(), statusFor(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(earnings)
+}
+
+func (h *PayoutHandler) SellerPayouts(w http.ResponseWriter, r *http.Request) {
+	sellerID, ok := sellerFor(w, r)
+	if !ok {
+		return
+	}
+	payouts, err := h.Service.ListPayouts(sellerID)
+	if err != nil {
+		http.Error(w, err.Error(), statusFor(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payouts)
+}
+
+// RunPayouts creates payouts for everything that has become payable since
+// the last run and answers with them.
+func (h *PayoutHandler) RunPayouts(w http.ResponseWriter, r *http.Request) {
+	payouts, err := h.Service.RunPayouts()
+	if err != nil {
+		http.Error(w, err.Error(), statusFor(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(payouts)
+}
+
+// ListPayouts lists every seller's payouts, or one seller's with
+// ?seller_id=.
+func (h *PayoutHandler) ListPayouts(w http.ResponseWriter, r *http.Request) {
+	sellerID, _ := strconv.ParseUint(r.URL.Query().Get("seller_id"), 10, 64)
+	payouts, err := h.Service.ListPayouts(sellerID)
+	if err != nil {
+		http.Error(w, err.Error(), statusFor(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payouts)
+}
+
+// MarkPayoutPaid records the bank transfer that paid a payout, e.g.
+// {"reference":"UTR123456"}.
+func (h *PayoutHandler) MarkPayoutPaid(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	var body struct {
+		Reference string `json:"reference"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	payout, err := h.Service.MarkPayoutPaid(id, body.Reference)
+	if err != nil {
+		http.Error(w, err.Error(), statusFor(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payout)
+}
+
+// sellerFor returns the seller in the URL if the caller may see its
+// payouts. Otherwise it writes a 403 and returns false.
+func sellerFor(w http.ResponseWriter, r *http.Request) (uint64, bool) {
+	sellerID, _ := strconv.ParseUint(mux.Vars(r)["sellerID"], 10, 64)
+	caller, _ := middleware.IdentityFromContext(r.Context())
+	if !caller.CanForSeller(sellerID, authz.PermPayoutsRead) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return 0, false
+	}
+	return sellerID, true
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"payment-service/service"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const maxSettlementFileSize = 32 << 20
+
+type ReconciliationHandler struct {
+	Service service.ReconciliationService
+}
+
+// ImportSettlement accepts a settlement CSV either as a multipart "file"
+// field or as the raw request body. The transaction date to reconcile is
+// given as ?date=YYYY-MM-DD and defaults to yesterday (UTC).
+func (h *ReconciliationHandler) ImportSettlement(w http.ResponseWriter, r *http.Request) {
+	date := time.Now().UTC().AddDate(0, 0, -1)
+	if d := r.URL.Query().Get("date"); d != "" {
+		parsed, err := time.Parse("2006-01-02", d)
+		if err != nil {
+			http.Error(w, "date must be YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		date = parsed
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxSettlementFileSize)
+	var file io.Reader = r.Body
+	fileName := r.URL.Query().Get("file_name")
+	if mf, header, err := r.FormFile("file"); err == nil {
+		defer mf.Close()
+		file, fileName = mf, header.Filename
+	} else if !errors.Is(err, http.ErrNotMultipart) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	run, err := h.Service.Import(fileName, file, date)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidSettlementFile) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	run.Items = nil
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(run)
+}
+
+func (h *ReconciliationHandler) ListRuns(w http.ResponseWriter, r *http.Request) {
+	runs, err := h.Service.ListRuns()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runs)
+}
+
+func (h *ReconciliationHandler) GetRun(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	run, err := h.Service.GetRun(id)
+	if err != nil {
+		http.Error(w, err.Error(), statusFor(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(run)
+}
+
+func (h *ReconciliationHandler) DownloadReport(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	run, err := h.Service.GetRun(id)
+	if err != nil {
+		http.Error(w, err.Error(), statusFor(err))
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="reconciliation-%d-%s.csv"`, run.ID, run.TransactionDate.Format("2006-01-02")))
+	service.WriteReportCSV(w, run)
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"payment-service/middleware"
+	"payment-service/models"
+	"payment-service/repository"
+	"payment-service/risk"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+type RiskHandler struct {
+	Repo repository.RiskRepository
+}
+
+func (h *RiskHandler) ListBlocklist(w http.ResponseWriter, r *http.Request) {
+	entries, err := h.Repo.ListBlocklist()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+func (h *RiskHandler) AddBlocklistEntry(w http.ResponseWriter, r *http.Request) {
+	var entry models.BlocklistEntry
+	if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch entry.Kind {
+	case risk.KeyUser, risk.KeyIP, risk.KeyCardToken:
+	case risk.KeyCountry:
+		entry.Value = strings.ToUpper(entry.Value)
+	default:
+		http.Error(w, "kind must be user, ip, card_token or country", http.StatusBadRequest)
+		return
+	}
+	entry.Value = strings.TrimSpace(entry.Value)
+	if entry.Value == "" {
+		http.Error(w, "value is required", http.StatusBadRequest)
+		return
+	}
+	entry.ID = 0
+	if caller, ok := middleware.IdentityFromContext(r.Context()); ok {
+		entry.CreatedBy = caller.UserID
+	}
+
+	created, err := h.Repo.CreateBlocklistEntry(entry)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+func (h *RiskHandler) DeleteBlocklistEntry(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err := h.Repo.DeleteBlocklistEntry(id); err != nil {
+		http.Error(w, err.Error(), statusFor(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"payment-service/models"
+	"payment-service/service"
+	"strconv"
+
+	"github.com/gajare/BAJAR-App/Backend/pkg/authz"
+	"github.com/gorilla/mux"
+)
+
+type SavedPaymentMethodHandler struct {
+	Service service.SavedPaymentMethodService
+}
+
+func (h *SavedPaymentMethodHandler) SavePaymentMethod(w http.ResponseWriter, r *http.Request) {
+	userID, _ := strconv.ParseUint(mux.Vars(r)["userID"], 10, 64)
+	if !actsFor(r, userID, authz.PermPaymentsWrite) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	// Decode into a request type so the gateway token, which is hidden in
+	// responses, can still be supplied.
+	var body struct {
+		models.SavedPaymentMethod
+		GatewayToken string `json:"gateway_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	method := body.SavedPaymentMethod
+	method.UserID = userID
+	method.GatewayToken = body.GatewayToken
+
+	saved, err := h.Service.Save(method)
+	if err != nil {
+		http.Error(w, err.Error(), statusFor(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(saved)
+}
+
+func (h *SavedPaymentMethodHandler) ListPaymentMethods(w http.ResponseWriter, r *http.Request) {
+	userID, _ := strconv.ParseUint(mux.Vars(r)["userID"], 10, 64)
+	if !actsFor(r, userID, authz.PermPaymentsRead) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	methods, err := h.Service.List(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(methods)
+}
+
+func (h *SavedPaymentMethodHandler) SetDefaultPaymentMethod(w http.ResponseWriter, r *http.Request) {
+	userID, _ := strconv.ParseUint(mux.Vars(r)["userID"], 10, 64)
+	if !actsFor(r, userID, authz.PermPaymentsWrite) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	id, _ := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err := h.Service.SetDefault(userID, id); err != nil {
+		http.Error(w, err.Error(), statusFor(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *SavedPaymentMethodHandler) DeletePaymentMethod(w http.ResponseWriter, r *http.Request) {
+	userID, _ := strconv.ParseUint(mux.Vars(r)["userID"], 10, 64)
+	if !actsFor(r, userID, authz.PermPaymentsWrite) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	id, _ := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err := h.Service.Delete(userID, id); err != nil {
+		http.Error(w, err.Error(), statusFor(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gajare/BAJAR-App/Backend/pkg/authz"
+)
+
+// Identity is the caller as described by a User-service access token.
+type Identity = authz.Identity
+
+// JWTAuth rejects requests without a bearer token v accepts and stores the
+// caller's Identity in the request context.
+func JWTAuth(v authz.TokenVerifier) func(http.Handler) http.Handler {
+	return authz.Authenticate(v)
+}
+
+// IdentityFromContext returns the caller set by JWTAuth.
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	return authz.FromContext(ctx)
+}
+
+// Require must run after JWTAuth; it only lets callers holding all of
+// perms through.
+func Require(perms ...string) func(http.Handler) http.Handler {
+	return authz.Require(perms...)
+}
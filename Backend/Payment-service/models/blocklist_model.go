@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// BlocklistEntry blocks payments from a user ID, IP address, card token or
+// billing country (see the risk.Key constants for Kind).
+type BlocklistEntry struct {
+	ID        uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	Kind      string    `gorm:"size:20;not null;uniqueIndex:idx_blocklist_kind_value" json:"kind"`
+	Value     string    `gorm:"size:255;not null;uniqueIndex:idx_blocklist_kind_value" json:"value"`
+	Reason    string    `gorm:"size:255" json:"reason,omitempty"`
+	CreatedBy uint64    `json:"created_by,omitempty"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// ExchangeRate is one entry in the rate history. Rate is the number of
+// BaseCurrency units one unit of Currency buys (e.g. USD 83.12 against an
+// INR base). Rows are only ever inserted, so the history stays intact.
+type ExchangeRate struct {
+	ID           uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	BaseCurrency string    `gorm:"size:3;not null;index:idx_exchange_rates_pair,priority:1" json:"base_currency"`
+	Currency     string    `gorm:"size:3;not null;index:idx_exchange_rates_pair,priority:2" json:"currency"`
+	Rate         string    `gorm:"type:numeric(20,10);not null" json:"rate"`
+	Source       string    `gorm:"size:50;not null" json:"source"`
+	EffectiveAt  time.Time `gorm:"not null;index:idx_exchange_rates_pair,priority:3" json:"effective_at"`
+	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
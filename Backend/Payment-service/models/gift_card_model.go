@@ -0,0 +1,25 @@
+package models
+
+import (
+	"payment-service/money"
+	"time"
+)
+
+// GiftCard is a code that can be spent like money. Only a hash of the code
+// is stored; the balance lives on the card's ledger account.
+type GiftCard struct {
+	ID            uint64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	CodeHash      string      `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	Last4         string      `gorm:"size:4;not null" json:"last4"`
+	AccountID     uint64      `gorm:"not null" json:"account_id"`
+	InitialAmount money.Money `gorm:"embedded;embeddedPrefix:initial_" json:"initial_amount"`
+	ExpiresAt     *time.Time  `gorm:"index" json:"expires_at,omitempty"`
+	// ExpiredAt is set once the remaining balance has been written off.
+	ExpiredAt *time.Time `json:"expired_at,omitempty"`
+	IssuedBy  uint64     `json:"issued_by"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (g GiftCard) Expired(now time.Time) bool {
+	return g.ExpiresAt != nil && !now.Before(*g.ExpiresAt)
+}
@@ -0,0 +1,76 @@
+package models
+
+import "time"
+
+// Ledger account types. Gift card and store credit accounts hold money
+// owed to customers and may never go negative; the system accounts are the
+// other side of each entry.
+const (
+	AccountGiftCard    = "gift_card"    // owner is the gift card ID
+	AccountStoreCredit = "store_credit" // owner is the user ID
+	AccountIssued      = "issued"       // gift cards sold
+	AccountRedeemed    = "redeemed"     // balances spent on orders
+	AccountRefunded    = "refunded"     // refunds paid out as store credit
+	AccountBreakage    = "breakage"     // balances lost to expiry
+)
+
+// Journal entry kinds.
+const (
+	EntryIssue        = "issue"
+	EntryRedeem       = "redeem"
+	EntryRefundCredit = "refund_credit"
+	EntryExpire       = "expire"
+	EntryReversal     = "reversal"
+)
+
+// LedgerAccount holds money in a single currency. Its balance is never
+// stored; it is the sum of the account's journal lines.
+type LedgerAccount struct {
+	ID        uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	Type      string    `gorm:"size:20;not null;uniqueIndex:idx_ledger_accounts_owner" json:"type"`
+	OwnerID   uint64    `gorm:"not null;uniqueIndex:idx_ledger_accounts_owner" json:"owner_id"`
+	Currency  string    `gorm:"size:3;not null;uniqueIndex:idx_ledger_accounts_owner" json:"currency"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// Customer reports whether the account holds a customer's money, as
+// opposed to being one of the system accounts.
+func (a LedgerAccount) Customer() bool {
+	return a.Type == AccountGiftCard || a.Type == AccountStoreCredit
+}
+
+// JournalEntry is one immutable double-entry posting. Corrections are made
+// with a reversal entry, never by editing.
+type JournalEntry struct {
+	ID         uint64        `gorm:"primaryKey;autoIncrement" json:"id"`
+	Kind       string        `gorm:"size:20;not null" json:"kind"`
+	Reference  string        `gorm:"size:100;index" json:"reference,omitempty"` // e.g. "payment:12"
+	Memo       string        `gorm:"size:255" json:"memo,omitempty"`
+	ReversesID *uint64       `json:"reverses_id,omitempty"`
+	CreatedAt  time.Time     `gorm:"autoCreateTime" json:"created_at"`
+	Lines      []JournalLine `gorm:"foreignKey:EntryID" json:"lines"`
+}
+
+// JournalLine moves Amount minor units into (positive) or out of
+// (negative) an account. The lines of an entry sum to zero.
+type JournalLine struct {
+	ID        uint64 `gorm:"primaryKey;autoIncrement" json:"id"`
+	EntryID   uint64 `gorm:"not null;index" json:"entry_id"`
+	AccountID uint64 `gorm:"not null;index" json:"account_id"`
+	Amount    int64  `gorm:"not null" json:"amount"`
+	Currency  string `gorm:"size:3;not null" json:"currency"`
+}
+
+// Balanced reports whether the entry's lines sum to zero in every currency.
+func (e JournalEntry) Balanced() bool {
+	sums := map[string]int64{}
+	for _, l := range e.Lines {
+		sums[l.Currency] += l.Amount
+	}
+	for _, sum := range sums {
+		if sum != 0 {
+			return false
+		}
+	}
+	return len(e.Lines) >= 2
+}
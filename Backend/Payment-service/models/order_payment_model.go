@@ -0,0 +1,19 @@
+package models
+
+import (
+	"payment-service/money"
+	"time"
+)
+
+// OrderPayment is the total an order has to be paid, possibly split across
+// several tenders (payments of different methods, e.g. a gift card plus
+// cash on delivery). The tenders are the payments with the same OrderID.
+// Shares split the total between the marketplace sellers whose lines the
+// order holds; whatever they leave over is BAJAR's own.
+type OrderPayment struct {
+	OrderID   uint64        `gorm:"primaryKey;autoIncrement:false" json:"order_id"`
+	UserID    uint64        `gorm:"not null;index" json:"user_id"`
+	Total     money.Money   `gorm:"embedded;embeddedPrefix:total_" json:"total"`
+	CreatedAt time.Time     `gorm:"autoCreateTime" json:"created_at"`
+	Shares    []SellerShare `gorm:"foreignKey:OrderID;references:OrderID" json:"shares,omitempty"`
+}
@@ -1,13 +1,127 @@
 package models
 
-import "time"
+import (
+	"payment-service/money"
+	"payment-service/risk"
+	"time"
+)
+
+const (
+	StatusPending           = "pending"
+	StatusSuccess           = "success"
+	StatusRefunded          = "refunded"
+	StatusAuthorized        = "authorized"
+	StatusPartiallyCaptured = "partially_captured"
+	StatusCaptured          = "captured"
+	StatusVoided            = "voided"
+	StatusOnHold            = "on_hold"  // waiting for manual risk review
+	StatusDeclined          = "declined" // denied by the risk pipeline
+	StatusRejected          = "rejected" // rejected by a reviewer
+)
+
+// Cash on delivery payments wait for the courier instead of a processor.
+const (
+	StatusAwaitingCollection = "awaiting_collection"
+	StatusCollected          = "collected"
+)
+
+// Payment methods. Saved methods use the card, upi and wallet types.
+const (
+	MethodCard        = MethodTypeCard
+	MethodUPI         = MethodTypeUPI
+	MethodWallet      = MethodTypeWallet
+	MethodGiftCard    = "gift_card"
+	MethodStoreCredit = "store_credit"
+	MethodCOD         = "cod"
+)
+
+// LedgerMethod reports whether payments of the method are paid from a
+// ledger balance (gift card or store credit) rather than by a processor.
+func LedgerMethod(method string) bool {
+	return method == MethodGiftCard || method == MethodStoreCredit
+}
+
+const (
+	IntentSale      = "sale"      // charge immediately
+	IntentAuthorize = "authorize" // hold now, capture later
+)
 
 type Payment struct {
-	ID            uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
-	OrderID       uint64    `gorm:"not null" json:"order_id"`
-	UserID        uint64    `gorm:"not null" json:"user_id"`
-	Amount        float64   `gorm:"type:numeric(10,2)" json:"amount"`
-	PaymentMethod string    `gorm:"size:50" json:"payment_method"`
-	Status        string    `gorm:"size:50;default:'pending'" json:"status"`
-	CreatedAt     time.Time `gorm:"autoCreateTime" json:"created_at"`
+	ID            uint64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	OrderID       uint64      `gorm:"not null" json:"order_id"`
+	UserID        uint64      `gorm:"not null" json:"user_id"`
+	Amount        money.Money `gorm:"embedded;embeddedPrefix:amount_" json:"amount"`
+	PaymentMethod string      `gorm:"size:50" json:"payment_method"`
+	Status        string      `gorm:"size:50;default:'pending'" json:"status"`
+	CreatedAt     time.Time   `gorm:"autoCreateTime" json:"created_at"`
+	Intent        string      `gorm:"size:20;not null;default:'sale'" json:"intent"`
+
+	// BaseAmount is Amount converted into the base currency with the rate
+	// snapshot below, so finance can reconcile without looking rates up again.
+	BaseAmount     money.Money `gorm:"embedded;embeddedPrefix:base_amount_" json:"base_amount"`
+	ExchangeRateID *uint64     `json:"exchange_rate_id,omitempty"`
+	ExchangeRate   string      `gorm:"type:numeric(20,10);not null;default:1" json:"exchange_rate"`
+
+	// SavedPaymentMethodID selects one of the user's saved methods; when
+	// set, PaymentMethod is filled in from it.
+	SavedPaymentMethodID *uint64 `gorm:"index" json:"saved_payment_method_id,omitempty"`
+
+	// Authorize-then-capture. Amount is what was authorized; Captured is
+	// how much of it has been taken so far.
+	Captured      money.Money `gorm:"embedded;embeddedPrefix:captured_" json:"captured"`
+	AuthorizedAt  *time.Time  `json:"authorized_at,omitempty"`
+	AuthExpiresAt *time.Time  `gorm:"index" json:"auth_expires_at,omitempty"`
+	CapturedAt    *time.Time  `json:"captured_at,omitempty"`
+	VoidedAt      *time.Time  `json:"voided_at,omitempty"`
+	VoidReason    string      `gorm:"size:50" json:"void_reason,omitempty"`
+
+	// ProviderReference is the processor's transaction ID, used to match
+	// the payment against settlement reports.
+	ProviderReference string `gorm:"size:100;index" json:"provider_reference,omitempty"`
+
+	// Risk assessment made before the payment was created or authorized.
+	ClientIP        string        `gorm:"size:45;index" json:"client_ip,omitempty"`
+	BillingCountry  string        `gorm:"size:2" json:"billing_country,omitempty"`
+	ShippingCountry string        `gorm:"size:2" json:"shipping_country,omitempty"`
+	RiskScore       int           `gorm:"not null;default:0" json:"risk_score"`
+	RiskDecision    string        `gorm:"size:10" json:"risk_decision,omitempty"`
+	RiskSignals     []risk.Signal `gorm:"serializer:json;type:jsonb" json:"risk_signals,omitempty"`
+	ReviewedBy      *uint64       `json:"reviewed_by,omitempty"`
+	ReviewedAt      *time.Time    `json:"reviewed_at,omitempty"`
+	ReviewNote      string        `gorm:"size:255" json:"review_note,omitempty"`
+
+	// Cash on delivery: when and by whom the cash was collected.
+	CollectedAt *time.Time `json:"collected_at,omitempty"`
+	CollectedBy *uint64    `json:"collected_by,omitempty"`
+
+	// Gift card and store credit payments. GiftCardCode is only accepted on
+	// input, and is the only way to name a card; the card is then
+	// remembered by ID. LedgerEntryID is the redemption.
+	GiftCardID    *uint64 `json:"gift_card_id,omitempty"`
+	GiftCardCode  string  `gorm:"-" json:"gift_card_code,omitempty"`
+	LedgerEntryID *uint64 `json:"ledger_entry_id,omitempty"`
+	RefundEntryID *uint64 `json:"refund_entry_id,omitempty"`
+}
+
+// SettledAmount is what the processor should pay out for the payment: the
+// captured amount for authorize/capture payments, otherwise the full amount.
+func (p Payment) SettledAmount() money.Money {
+	if p.Captured.Minor > 0 {
+		return p.Captured
+	}
+	return p.Amount
+}
+
+// Settled reports whether the money has actually been received.
+func (p Payment) Settled() bool {
+	switch p.Status {
+	case StatusSuccess, StatusCaptured, StatusCollected:
+		return true
+	}
+	return false
+}
+
+// Remaining is the authorized amount that has not been captured yet.
+func (p Payment) Remaining() money.Money {
+	return money.Money{Minor: p.Amount.Minor - p.Captured.Minor, Currency: p.Amount.Currency}
 }
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+const (
+	ReconMatched         = "matched"
+	ReconMissingInternal = "missing_internal" // in the settlement file, not in payments
+	ReconMissingProvider = "missing_provider" // in payments, not in the settlement file
+	ReconAmountMismatch  = "amount_mismatch"
+)
+
+// ReconciliationRun is one import of a processor settlement file, checked
+// against the payments created on TransactionDate.
+type ReconciliationRun struct {
+	ID              uint64               `gorm:"primaryKey;autoIncrement" json:"id"`
+	FileName        string               `gorm:"size:255" json:"file_name"`
+	TransactionDate time.Time            `gorm:"type:date;not null;index" json:"transaction_date"`
+	Rows            int                  `json:"rows"`
+	Matched         int                  `json:"matched"`
+	MissingInternal int                  `json:"missing_internal"`
+	MissingProvider int                  `json:"missing_provider"`
+	AmountMismatch  int                  `json:"amount_mismatch"`
+	CreatedAt       time.Time            `gorm:"autoCreateTime" json:"created_at"`
+	Items           []ReconciliationItem `gorm:"foreignKey:RunID" json:"items,omitempty"`
+}
+
+// ReconciliationItem is one line of a run's report. Amounts are in minor
+// units of Currency; a nil amount means that side had no record.
+type ReconciliationItem struct {
+	ID                  uint64  `gorm:"primaryKey;autoIncrement" json:"id"`
+	RunID               uint64  `gorm:"not null;index" json:"run_id"`
+	Result              string  `gorm:"size:30;not null;index" json:"result"`
+	ProviderReference   string  `gorm:"size:100" json:"provider_reference"`
+	PaymentID           *uint64 `json:"payment_id,omitempty"`
+	Currency            string  `gorm:"size:3" json:"currency"`
+	OurAmountMinor      *int64  `json:"our_amount_minor,omitempty"`
+	ProviderAmountMinor *int64  `json:"provider_amount_minor,omitempty"`
+	Note                string  `gorm:"size:255" json:"note,omitempty"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	MethodTypeCard   = "card"
+	MethodTypeUPI    = "upi"
+	MethodTypeWallet = "wallet"
+)
+
+// SavedPaymentMethod is a reusable payment instrument belonging to a
+// User-service user. Cards are only ever held as the gateway's token plus
+// display details; the card number itself never reaches this service.
+type SavedPaymentMethod struct {
+	ID             uint64         `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID         uint64         `gorm:"not null;index;uniqueIndex:idx_saved_payment_methods_default,where:is_default AND deleted_at IS NULL" json:"user_id"`
+	Type           string         `gorm:"size:20;not null" json:"type"`
+	GatewayToken   string         `gorm:"size:255" json:"-"`
+	Brand          string         `gorm:"size:30" json:"brand,omitempty"`
+	Last4          string         `gorm:"size:4" json:"last4,omitempty"`
+	ExpMonth       int            `json:"exp_month,omitempty"`
+	ExpYear        int            `json:"exp_year,omitempty"`
+	UPIID          string         `gorm:"size:100" json:"upi_id,omitempty"`
+	WalletProvider string         `gorm:"size:50" json:"wallet_provider,omitempty"`
+	Label          string         `gorm:"size:100" json:"label,omitempty"`
+	IsDefault      bool           `gorm:"not null;default:false" json:"is_default"`
+	CreatedAt      time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// Expired reports whether a card's expiry month has passed.
+func (m SavedPaymentMethod) Expired(now time.Time) bool {
+	if m.Type != MethodTypeCard {
+		return false
+	}
+	return m.ExpYear < now.Year() || (m.ExpYear == now.Year() && m.ExpMonth < int(now.Month()))
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"payment-service/money"
+	"time"
+)
+
+// Payout statuses.
+const (
+	PayoutPending = "pending" // calculated, not sent to the seller yet
+	PayoutPaid    = "paid"
+)
+
+// SellerShare is the part of an order's total that pays for a
+// marketplace seller's lines. It becomes payable once the order is fully
+// paid and is then paid out, less commission, in a SellerPayout.
+type SellerShare struct {
+	ID        uint64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	OrderID   uint64      `gorm:"not null;index" json:"order_id"`
+	SellerID  uint64      `gorm:"not null;index" json:"seller_id"`
+	Amount    money.Money `gorm:"embedded;embeddedPrefix:amount_" json:"amount"`
+	PayoutID  *uint64     `gorm:"index" json:"payout_id,omitempty"`
+	CreatedAt time.Time   `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// SellerPayout is what a seller is owed for a batch of shares: Gross less
+// Commission, at CommissionBPS basis points. Reference is the bank
+// transfer's, recorded when it is paid.
+type SellerPayout struct {
+	ID            uint64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	SellerID      uint64      `gorm:"not null;index" json:"seller_id"`
+	Gross         money.Money `gorm:"embedded;embeddedPrefix:gross_" json:"gross"`
+	Commission    money.Money `gorm:"embedded;embeddedPrefix:commission_" json:"commission"`
+	Net           money.Money `gorm:"embedded;embeddedPrefix:net_" json:"net"`
+	CommissionBPS int         `gorm:"not null" json:"commission_bps"`
+	Status        string      `gorm:"size:20;not null;index" json:"status"`
+	Reference     string      `gorm:"size:100" json:"reference,omitempty"`
+	CreatedAt     time.Time   `gorm:"autoCreateTime" json:"created_at"`
+	PaidAt        *time.Time  `json:"paid_at,omitempty"`
+}
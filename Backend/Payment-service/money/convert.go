@@ -0,0 +1,87 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+var ErrInvalidRate = errors.New("exchange rate must be a positive decimal")
+
+// Rates are stored as numeric(20,10): at most ten digits either side of
+// the point.
+const (
+	maxRateIntegerDigits  = 10
+	maxRateFractionDigits = 10
+)
+
+// ParseRate parses an exchange rate such as "83.1245". Rates are kept as
+// exact rationals; only the converted amount is ever rounded. Rates with
+// more digits than the database keeps are rejected rather than rounded
+// when stored.
+func ParseRate(s string) (*big.Rat, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.ContainsAny(s, "/eE") {
+		return nil, ErrInvalidRate
+	}
+	whole, fraction, _ := strings.Cut(strings.TrimLeft(s, "+-"), ".")
+	if len(strings.TrimLeft(whole, "0")) > maxRateIntegerDigits || len(strings.TrimRight(fraction, "0")) > maxRateFractionDigits {
+		return nil, fmt.Errorf("%w: at most %d digits before and %d after the point", ErrInvalidRate, maxRateIntegerDigits, maxRateFractionDigits)
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || r.Sign() <= 0 {
+		return nil, ErrInvalidRate
+	}
+	return r, nil
+}
+
+// Convert multiplies the amount by rate and expresses the result in the
+// target currency. The result is rounded once, half to even, at the target
+// currency's minor unit, so repeated conversions do not drift in one
+// direction.
+func (m Money) Convert(rate *big.Rat, to string) (Money, error) {
+	code, err := NormalizeCurrency(to)
+	if err != nil {
+		return Money{}, err
+	}
+	fromDigits, err := MinorUnits(m.Currency)
+	if err != nil {
+		return Money{}, err
+	}
+	if rate == nil || rate.Sign() <= 0 {
+		return Money{}, ErrInvalidRate
+	}
+	toDigits, _ := MinorUnits(code)
+
+	v := new(big.Rat).SetInt64(m.Minor)
+	v.Mul(v, rate)
+	v.Mul(v, new(big.Rat).SetFrac(pow10(toDigits), pow10(fromDigits)))
+
+	minor := roundHalfEven(v)
+	if !minor.IsInt64() {
+		return Money{}, ErrOverflow
+	}
+	return Money{Minor: minor.Int64(), Currency: code}, nil
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+func roundHalfEven(r *big.Rat) *big.Int {
+	q, rem := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+	if rem.Sign() == 0 {
+		return q
+	}
+	// Compare 2*|rem| with the denominator to decide which way to go.
+	twice := new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2))
+	c := twice.Cmp(r.Denom())
+	if c > 0 || (c == 0 && q.Bit(0) == 1) {
+		if r.Sign() < 0 {
+			return q.Sub(q, big.NewInt(1))
+		}
+		return q.Add(q, big.NewInt(1))
+	}
+	return q
+}
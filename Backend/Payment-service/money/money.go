@@ -0,0 +1,272 @@
+// Package money represents monetary amounts as an integer count of the
+// currency's minor unit (paise, cents, ...) together with an ISO 4217 code,
+// so amounts are never subject to floating point rounding.
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidAmount    = errors.New("invalid amount")
+	ErrTooPrecise       = errors.New("amount has more decimal places than the currency allows")
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	ErrOverflow         = errors.New("amount out of range")
+)
+
+// Money is stored as two columns when embedded in a gorm model, e.g.
+// `gorm:"embedded;embeddedPrefix:amount_"` gives amount_minor and
+// amount_currency.
+type Money struct {
+	Minor    int64  `gorm:"column:minor;not null"`
+	Currency string `gorm:"column:currency;size:3;not null"`
+}
+
+// New builds an amount from minor units, validating the currency.
+func New(minor int64, currency string) (Money, error) {
+	code, err := NormalizeCurrency(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Minor: minor, Currency: code}, nil
+}
+
+// Parse reads a decimal string such as "1499.50" in the given currency.
+// Digits beyond the currency's precision are rejected rather than rounded,
+// unless they are all zero.
+func Parse(amount, currency string) (Money, error) {
+	code, err := NormalizeCurrency(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	digits, _ := MinorUnits(code)
+
+	s := strings.TrimSpace(amount)
+	negative := false
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		negative = s[0] == '-'
+		s = s[1:]
+	}
+	whole, frac, hasPoint := strings.Cut(s, ".")
+	if whole == "" && frac == "" || hasPoint && frac == "" || !isDigits(whole) || !isDigits(frac) {
+		return Money{}, ErrInvalidAmount
+	}
+	if len(frac) > digits {
+		if strings.Trim(frac[digits:], "0") != "" {
+			return Money{}, ErrTooPrecise
+		}
+		frac = frac[:digits]
+	}
+	frac += strings.Repeat("0", digits-len(frac))
+
+	minor, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) {
+			return Money{}, ErrOverflow
+		}
+		return Money{}, ErrInvalidAmount
+	}
+	if negative {
+		minor = -minor
+	}
+	return Money{Minor: minor, Currency: code}, nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate reports whether the currency code is a known ISO 4217 code.
+func (m Money) Validate() error {
+	_, err := MinorUnits(m.Currency)
+	return err
+}
+
+func (m Money) IsZero() bool     { return m.Minor == 0 }
+func (m Money) IsPositive() bool { return m.Minor > 0 }
+func (m Money) IsNegative() bool { return m.Minor < 0 }
+
+// Decimal formats the amount with exactly the currency's number of
+// decimal places, e.g. "1499.50".
+func (m Money) Decimal() string {
+	digits, err := MinorUnits(m.Currency)
+	if err != nil {
+		return strconv.FormatInt(m.Minor, 10)
+	}
+	sign := ""
+	abs := new(big.Int).SetInt64(m.Minor)
+	if m.Minor < 0 {
+		sign = "-"
+		abs.Neg(abs)
+	}
+	s := abs.String()
+	if digits == 0 {
+		return sign + s
+	}
+	if len(s) <= digits {
+		s = strings.Repeat("0", digits-len(s)+1) + s
+	}
+	return sign + s[:len(s)-digits] + "." + s[len(s)-digits:]
+}
+
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+func (m Money) sameCurrency(o Money) error {
+	if m.Currency != o.Currency {
+		return fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+	return nil
+}
+
+func (m Money) Add(o Money) (Money, error) {
+	if err := m.sameCurrency(o); err != nil {
+		return Money{}, err
+	}
+	if (o.Minor > 0 && m.Minor > math.MaxInt64-o.Minor) || (o.Minor < 0 && m.Minor < math.MinInt64-o.Minor) {
+		return Money{}, ErrOverflow
+	}
+	return Money{Minor: m.Minor + o.Minor, Currency: m.Currency}, nil
+}
+
+func (m Money) Sub(o Money) (Money, error) {
+	if o.Minor == math.MinInt64 {
+		return Money{}, ErrOverflow
+	}
+	return m.Add(Money{Minor: -o.Minor, Currency: o.Currency})
+}
+
+// Cmp compares two amounts of the same currency, returning -1, 0 or +1.
+func (m Money) Cmp(o Money) (int, error) {
+	if err := m.sameCurrency(o); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.Minor < o.Minor:
+		return -1, nil
+	case m.Minor > o.Minor:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Allocate splits the amount in proportion to the given weights. Minor
+// units left over after integer division go one at a time to the shares
+// with the largest remainders, so the parts always add up to the original.
+func (m Money) Allocate(weights ...int64) ([]Money, error) {
+	if len(weights) == 0 {
+		return nil, errors.New("allocate needs at least one weight")
+	}
+	total := new(big.Int)
+	for _, w := range weights {
+		if w < 0 {
+			return nil, errors.New("allocation weights must not be negative")
+		}
+		total.Add(total, big.NewInt(w))
+	}
+	if total.Sign() == 0 {
+		return nil, errors.New("allocation weights must not all be zero")
+	}
+
+	amount := big.NewInt(m.Minor)
+	parts := make([]Money, len(weights))
+	remainders := make([]*big.Int, len(weights))
+	allocated := int64(0)
+	for i, w := range weights {
+		q, r := new(big.Int).QuoRem(new(big.Int).Mul(amount, big.NewInt(w)), total, new(big.Int))
+		parts[i] = Money{Minor: q.Int64(), Currency: m.Currency}
+		remainders[i] = r.Abs(r)
+		allocated += q.Int64()
+	}
+
+	step := int64(1)
+	if m.Minor < 0 {
+		step = -1
+	}
+	for left := m.Minor - allocated; left != 0; left -= step {
+		best := -1
+		for i := range remainders {
+			if weights[i] == 0 {
+				continue
+			}
+			if best < 0 || remainders[i].Cmp(remainders[best]) > 0 {
+				best = i
+			}
+		}
+		parts[best].Minor += step
+		remainders[best].SetInt64(-1)
+	}
+	return parts, nil
+}
+
+type moneyJSON struct {
+	Value      json.RawMessage `json:"value,omitempty"`
+	Currency   string          `json:"currency"`
+	MinorUnits *int64          `json:"minor_units,omitempty"`
+}
+
+// MarshalJSON writes {"value":"1499.50","currency":"INR","minor_units":149950}.
+// The value is a string so clients never have to parse it as a float.
+func (m Money) MarshalJSON() ([]byte, error) {
+	value, _ := json.Marshal(m.Decimal())
+	minor := m.Minor
+	return json.Marshal(moneyJSON{Value: value, Currency: m.Currency, MinorUnits: &minor})
+}
+
+// UnmarshalJSON accepts either a decimal "value" (as a string or a bare
+// JSON number, read digit for digit) or an integer "minor_units".
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var in moneyJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	if in.MinorUnits != nil {
+		parsed, err := New(*in.MinorUnits, in.Currency)
+		if err != nil {
+			return err
+		}
+		if len(in.Value) > 0 {
+			if byValue, err := decodeValue(in.Value, parsed.Currency); err != nil || byValue.Minor != parsed.Minor {
+				return fmt.Errorf("%w: value and minor_units disagree", ErrInvalidAmount)
+			}
+		}
+		*m = parsed
+		return nil
+	}
+	if len(in.Value) == 0 {
+		return fmt.Errorf("%w: value or minor_units is required", ErrInvalidAmount)
+	}
+	parsed, err := decodeValue(in.Value, in.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+func decodeValue(raw json.RawMessage, currency string) (Money, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		var n json.Number
+		if err := json.Unmarshal(raw, &n); err != nil {
+			return Money{}, ErrInvalidAmount
+		}
+		s = n.String()
+	}
+	if strings.ContainsAny(s, "eE") {
+		return Money{}, ErrInvalidAmount
+	}
+	return Parse(s, currency)
+}
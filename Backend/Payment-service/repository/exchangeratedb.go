@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"payment-service/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ExchangeRateRepository interface {
+	Create(models.ExchangeRate) (models.ExchangeRate, error)
+	Latest(base, currency string, at time.Time) (models.ExchangeRate, error)
+	LatestAll(base string, at time.Time) ([]models.ExchangeRate, error)
+	History(base, currency string, limit int) ([]models.ExchangeRate, error)
+}
+
+type exchangeRateRepository struct {
+	db *gorm.DB
+}
+
+func NewExchangeRateRepository(db *gorm.DB) ExchangeRateRepository {
+	return &exchangeRateRepository{db: db}
+}
+
+func (r *exchangeRateRepository) Create(rate models.ExchangeRate) (models.ExchangeRate, error) {
+	err := r.db.Create(&rate).Error
+	return rate, err
+}
+
+func (r *exchangeRateRepository) Latest(base, currency string, at time.Time) (models.ExchangeRate, error) {
+	var rate models.ExchangeRate
+	err := r.db.Where("base_currency = ? AND currency = ? AND effective_at <= ?", base, currency, at).
+		Order("effective_at DESC, id DESC").First(&rate).Error
+	return rate, err
+}
+
+func (r *exchangeRateRepository) LatestAll(base string, at time.Time) ([]models.ExchangeRate, error) {
+	var rates []models.ExchangeRate
+	err := r.db.Raw(`SELECT DISTINCT ON (currency) * FROM exchange_rates
+		WHERE base_currency = ? AND effective_at <= ?
+		ORDER BY currency, effective_at DESC, id DESC`, base, at).Scan(&rates).Error
+	return rates, err
+}
+
+func (r *exchangeRateRepository) History(base, currency string, limit int) ([]models.ExchangeRate, error) {
+	var rates []models.ExchangeRate
+	err := r.db.Where("base_currency = ? AND currency = ?", base, currency).
+		Order("effective_at DESC, id DESC").Limit(limit).Find(&rates).Error
+	return rates, err
+}
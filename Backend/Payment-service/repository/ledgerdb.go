@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"errors"
+	"payment-service/models"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type LedgerRepository interface {
+	// Account returns the account for the type, owner and currency,
+	// creating it on first use.
+	Account(accountType string, ownerID uint64, currency string) (models.LedgerAccount, error)
+	ListAccounts(accountType string, ownerID uint64) ([]models.LedgerAccount, error)
+	Balance(accountID uint64) (int64, error)
+	// PostLocked locks the accounts, hands their balances to fn and records
+	// the entry fn returns, all in one transaction. Concurrent postings to
+	// the same account therefore see each other's effect. A reversal fails
+	// with ErrAlreadyReversed if its entry has been reversed before.
+	PostLocked(accountIDs []uint64, fn func(locked map[uint64]AccountBalance) (models.JournalEntry, error)) (models.JournalEntry, error)
+	GetEntry(id uint64) (models.JournalEntry, error)
+	ListEntries(accountID uint64, limit int) ([]models.JournalEntry, error)
+
+	// CreateGiftCard stores the card along with its (empty) ledger account.
+	CreateGiftCard(models.GiftCard) (models.GiftCard, error)
+	GetGiftCard(id uint64) (models.GiftCard, error)
+	GetGiftCardByCodeHash(hash string) (models.GiftCard, error)
+	ListExpiredGiftCards(now time.Time, limit int) ([]models.GiftCard, error)
+	MarkGiftCardExpired(id uint64, at time.Time) error
+}
+
+// ErrAlreadyReversed means a journal entry already has a reversal.
+var ErrAlreadyReversed = errors.New("journal entry has already been reversed")
+
+// AccountBalance is an account with its balance in minor units.
+type AccountBalance struct {
+	models.LedgerAccount
+	Balance int64
+}
+
+type ledgerRepository struct {
+	db *gorm.DB
+}
+
+func NewLedgerRepository(db *gorm.DB) LedgerRepository {
+	return &ledgerRepository{db: db}
+}
+
+func (r *ledgerRepository) Account(accountType string, ownerID uint64, currency string) (models.LedgerAccount, error) {
+	return findOrCreateAccount(r.db, accountType, ownerID, currency)
+}
+
+func findOrCreateAccount(db *gorm.DB, accountType string, ownerID uint64, currency string) (models.LedgerAccount, error) {
+	account := models.LedgerAccount{Type: accountType, OwnerID: ownerID, Currency: currency}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&account).Error; err != nil {
+		return account, err
+	}
+	err := db.Where("type = ? AND owner_id = ? AND currency = ?", accountType, ownerID, currency).First(&account).Error
+	return account, err
+}
+
+func (r *ledgerRepository) ListAccounts(accountType string, ownerID uint64) ([]models.LedgerAccount, error) {
+	var accounts []models.LedgerAccount
+	err := r.db.Where("type = ? AND owner_id = ?", accountType, ownerID).Order("currency").Find(&accounts).Error
+	return accounts, err
+}
+
+func (r *ledgerRepository) Balance(accountID uint64) (int64, error) {
+	return balance(r.db, accountID)
+}
+
+func balance(db *gorm.DB, accountID uint64) (int64, error) {
+	var sum int64
+	err := db.Model(&models.JournalLine{}).Where("account_id = ?", accountID).
+		Select("COALESCE(SUM(amount), 0)").Scan(&sum).Error
+	return sum, err
+}
+
+func (r *ledgerRepository) PostLocked(accountIDs []uint64, fn func(map[uint64]AccountBalance) (models.JournalEntry, error)) (models.JournalEntry, error) {
+	var entry models.JournalEntry
+	// Lock in ID order so two postings touching the same accounts cannot
+	// deadlock.
+	ids := append([]uint64(nil), accountIDs...)
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var locked []models.LedgerAccount
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN ?", ids).Order("id").Find(&locked).Error; err != nil {
+			return err
+		}
+		balances := make(map[uint64]AccountBalance, len(locked))
+		for _, a := range locked {
+			b, err := balance(tx, a.ID)
+			if err != nil {
+				return err
+			}
+			balances[a.ID] = AccountBalance{LedgerAccount: a, Balance: b}
+		}
+		var err error
+		if entry, err = fn(balances); err != nil {
+			return err
+		}
+		// A second reversal of the same entry locks the same accounts, so
+		// this check cannot race with it.
+		if entry.ReversesID != nil {
+			var reversals int64
+			if err := tx.Model(&models.JournalEntry{}).Where("reverses_id = ?", *entry.ReversesID).Count(&reversals).Error; err != nil {
+				return err
+			}
+			if reversals > 0 {
+				return ErrAlreadyReversed
+			}
+		}
+		return tx.Create(&entry).Error
+	})
+	return entry, err
+}
+
+func (r *ledgerRepository) GetEntry(id uint64) (models.JournalEntry, error) {
+	var entry models.JournalEntry
+	err := r.db.Preload("Lines").First(&entry, id).Error
+	return entry, err
+}
+
+func (r *ledgerRepository) ListEntries(accountID uint64, limit int) ([]models.JournalEntry, error) {
+	var entries []models.JournalEntry
+	err := r.db.Preload("Lines").
+		Where("id IN (?)", r.db.Model(&models.JournalLine{}).Select("entry_id").Where("account_id = ?", accountID)).
+		Order("id DESC").Limit(limit).Find(&entries).Error
+	return entries, err
+}
+
+func (r *ledgerRepository) CreateGiftCard(card models.GiftCard) (models.GiftCard, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&card).Error; err != nil {
+			return err
+		}
+		account, err := findOrCreateAccount(tx, models.AccountGiftCard, card.ID, card.InitialAmount.Currency)
+		if err != nil {
+			return err
+		}
+		card.AccountID = account.ID
+		return tx.Model(&card).Update("account_id", account.ID).Error
+	})
+	return card, err
+}
+
+func (r *ledgerRepository) GetGiftCard(id uint64) (models.GiftCard, error) {
+	var card models.GiftCard
+	err := r.db.First(&card, id).Error
+	return card, err
+}
+
+func (r *ledgerRepository) GetGiftCardByCodeHash(hash string) (models.GiftCard, error) {
+	var card models.GiftCard
+	err := r.db.Where("code_hash = ?", hash).First(&card).Error
+	return card, err
+}
+
+func (r *ledgerRepository) ListExpiredGiftCards(now time.Time, limit int) ([]models.GiftCard, error) {
+	var cards []models.GiftCard
+	err := r.db.Where("expires_at <= ? AND expired_at IS NULL", now).Order("expires_at").Limit(limit).Find(&cards).Error
+	return cards, err
+}
+
+func (r *ledgerRepository) MarkGiftCardExpired(id uint64, at time.Time) error {
+	return r.db.Model(&models.GiftCard{}).Where("id = ?", id).Update("expired_at", at).Error
+}
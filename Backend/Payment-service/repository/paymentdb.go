@@ -2,15 +2,59 @@ package repository
 
 import (
 	"payment-service/models"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type PaymentRepository interface {
 	Create(models.Payment) (models.Payment, error)
 	GetByID(uint64) (models.Payment, error)
-	List() ([]models.Payment, error)
+	// List returns one page of payments matching the filter, newest first,
+	// along with the number of matches across all pages.
+	List(PaymentFilter) ([]models.Payment, int64, error)
 	Update(models.Payment) (models.Payment, error)
+	// UpdateLocked loads the payment with a row lock, applies fn and saves
+	// the result in one transaction, so concurrent captures cannot race.
+	UpdateLocked(id uint64, fn func(*models.Payment) error) (models.Payment, error)
+	ListExpiredAuthorizations(now time.Time, limit int) ([]models.Payment, error)
+	FindByProviderReferences(refs []string) ([]models.Payment, error)
+	// ListSettledBetween returns payments created in [from, to) that the
+	// processor is expected to have settled. Gift card and store credit
+	// payments never reach the processor and are left out.
+	ListSettledBetween(from, to time.Time) ([]models.Payment, error)
+	// ListByStatus returns payments in status, oldest first.
+	ListByStatus(status string, limit int) ([]models.Payment, error)
+
+	// CreateOrderPayment stores an order total together with all of its
+	// tenders, or nothing if any of them fails.
+	CreateOrderPayment(order models.OrderPayment, tenders []models.Payment) (models.OrderPayment, []models.Payment, error)
+	GetOrderPayment(orderID uint64) (models.OrderPayment, error)
+	ListByOrder(orderID uint64) ([]models.Payment, error)
+}
+
+// PaymentFilter narrows a payment listing. Zero values mean "any".
+// Amounts are minor units and only apply together with Currency.
+type PaymentFilter struct {
+	UserID    *uint64
+	OrderID   *uint64
+	Status    string
+	Method    string
+	Currency  string
+	MinAmount *int64
+	MaxAmount *int64
+	From      *time.Time
+	To        *time.Time
+
+	// After is the keyset cursor: the last payment of the previous page.
+	After *PageCursor
+	Limit int
+}
+
+type PageCursor struct {
+	CreatedAt time.Time
+	ID        uint64
 }
 
 type paymentRepository struct {
@@ -22,6 +66,10 @@ func NewPaymentRepository(db *gorm.DB) PaymentRepository {
 }
 
 func (r *paymentRepository) Create(p models.Payment) (models.Payment, error) {
+	// The database assigns these; a client-chosen ID could collide with
+	// or pre-empt another payment.
+	p.ID = 0
+	p.CreatedAt = time.Time{}
 	if err := r.db.Create(&p).Error; err != nil {
 		return p, err
 	}
@@ -34,13 +82,133 @@ func (r *paymentRepository) GetByID(id uint64) (models.Payment, error) {
 	return payment, err
 }
 
-func (r *paymentRepository) List() ([]models.Payment, error) {
+func (r *paymentRepository) List(f PaymentFilter) ([]models.Payment, int64, error) {
+	q := r.db.Model(&models.Payment{})
+	if f.UserID != nil {
+		q = q.Where("user_id = ?", *f.UserID)
+	}
+	if f.OrderID != nil {
+		q = q.Where("order_id = ?", *f.OrderID)
+	}
+	if f.Status != "" {
+		q = q.Where("status = ?", f.Status)
+	}
+	if f.Method != "" {
+		q = q.Where("payment_method = ?", f.Method)
+	}
+	if f.Currency != "" {
+		q = q.Where("amount_currency = ?", f.Currency)
+		if f.MinAmount != nil {
+			q = q.Where("amount_minor >= ?", *f.MinAmount)
+		}
+		if f.MaxAmount != nil {
+			q = q.Where("amount_minor <= ?", *f.MaxAmount)
+		}
+	}
+	if f.From != nil {
+		q = q.Where("created_at >= ?", *f.From)
+	}
+	if f.To != nil {
+		q = q.Where("created_at < ?", *f.To)
+	}
+
+	var total int64
+	if err := q.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if f.After != nil {
+		q = q.Where("(created_at, id) < (?, ?)", f.After.CreatedAt, f.After.ID)
+	}
 	var payments []models.Payment
-	err := r.db.Find(&payments).Error
-	return payments, err
+	err := q.Order("created_at DESC, id DESC").Limit(f.Limit).Find(&payments).Error
+	return payments, total, err
 }
 
 func (r *paymentRepository) Update(p models.Payment) (models.Payment, error) {
 	err := r.db.Save(&p).Error
 	return p, err
 }
+
+func (r *paymentRepository) UpdateLocked(id uint64, fn func(*models.Payment) error) (models.Payment, error) {
+	var payment models.Payment
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, id).Error; err != nil {
+			return err
+		}
+		if err := fn(&payment); err != nil {
+			return err
+		}
+		return tx.Save(&payment).Error
+	})
+	return payment, err
+}
+
+func (r *paymentRepository) ListExpiredAuthorizations(now time.Time, limit int) ([]models.Payment, error) {
+	var payments []models.Payment
+	err := r.db.Where("status IN ? AND auth_expires_at <= ?",
+		[]string{models.StatusAuthorized, models.StatusPartiallyCaptured}, now).
+		Order("auth_expires_at").Limit(limit).Find(&payments).Error
+	return payments, err
+}
+
+// referenceChunk keeps each IN list well below Postgres's limit of 65535
+// bind parameters per query.
+const referenceChunk = 1000
+
+func (r *paymentRepository) FindByProviderReferences(refs []string) ([]models.Payment, error) {
+	var payments []models.Payment
+	for start := 0; start < len(refs); start += referenceChunk {
+		end := start + referenceChunk
+		if end > len(refs) {
+			end = len(refs)
+		}
+		var chunk []models.Payment
+		if err := r.db.Where("provider_reference IN ?", refs[start:end]).Find(&chunk).Error; err != nil {
+			return nil, err
+		}
+		payments = append(payments, chunk...)
+	}
+	return payments, nil
+}
+
+func (r *paymentRepository) ListSettledBetween(from, to time.Time) ([]models.Payment, error) {
+	var payments []models.Payment
+	err := r.db.Where("status IN ? AND created_at >= ? AND created_at < ?",
+		[]string{models.StatusSuccess, models.StatusCaptured, models.StatusPartiallyCaptured}, from, to).
+		Where("payment_method NOT IN ?", []string{models.MethodGiftCard, models.MethodStoreCredit}).
+		Find(&payments).Error
+	return payments, err
+}
+
+func (r *paymentRepository) ListByStatus(status string, limit int) ([]models.Payment, error) {
+	var payments []models.Payment
+	err := r.db.Where("status = ?", status).Order("created_at, id").Limit(limit).Find(&payments).Error
+	return payments, err
+}
+
+func (r *paymentRepository) CreateOrderPayment(order models.OrderPayment, tenders []models.Payment) (models.OrderPayment, []models.Payment, error) {
+	for i := range tenders {
+		tenders[i].ID = 0
+		tenders[i].CreatedAt = time.Time{}
+	}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&order).Error; err != nil {
+			return err
+		}
+		return tx.Create(&tenders).Error
+	})
+	return order, tenders, err
+}
+
+func (r *paymentRepository) GetOrderPayment(orderID uint64) (models.OrderPayment, error) {
+	var order models.OrderPayment
+	err := r.db.Preload("Shares").First(&order, "order_id = ?", orderID).Error
+	return order, err
+}
+
+func (r *paymentRepository) ListByOrder(orderID uint64) ([]models.Payment, error) {
+	var payments []models.Payment
+	err := r.db.Where("order_id = ?", orderID).Order("id").Find(&payments).Error
+	return payments, err
+}
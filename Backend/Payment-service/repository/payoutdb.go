@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"errors"
+	"payment-service/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrShareTaken means a share is already part of another payout.
+	ErrShareTaken = errors.New("seller share already paid out")
+	// ErrPayoutNotPending means the payout has been paid already.
+	ErrPayoutNotPending = errors.New("payout is not pending")
+)
+
+type PayoutRepository interface {
+	// UnpaidShares lists the shares of sellerID, or of every seller for 0,
+	// that are not in a payout yet.
+	UnpaidShares(sellerID uint64) ([]models.SellerShare, error)
+	// CreatePayout stores the payout and assigns the shares to it, or does
+	// nothing if any of them already belongs to a payout.
+	CreatePayout(payout models.SellerPayout, shareIDs []uint64) (models.SellerPayout, error)
+	GetPayout(id uint64) (models.SellerPayout, error)
+	// ListPayouts lists the payouts of sellerID, or of every seller for 0,
+	// newest first.
+	ListPayouts(sellerID uint64) ([]models.SellerPayout, error)
+	MarkPayoutPaid(id uint64, reference string, at time.Time) (models.SellerPayout, error)
+}
+
+type payoutRepository struct {
+	db *gorm.DB
+}
+
+func NewPayoutRepository(db *gorm.DB) PayoutRepository {
+	return &payoutRepository{db: db}
+}
+
+func (r *payoutRepository) UnpaidShares(sellerID uint64) ([]models.SellerShare, error) {
+	q := r.db.Where("payout_id IS NULL")
+	if sellerID != 0 {
+		q = q.Where("seller_id = ?", sellerID)
+	}
+	var shares []models.SellerShare
+	err := q.Order("id").Find(&shares).Error
+	return shares, err
+}
+
+func (r *payoutRepository) CreatePayout(payout models.SellerPayout, shareIDs []uint64) (models.SellerPayout, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&payout).Error; err != nil {
+			return err
+		}
+		res := tx.Model(&models.SellerShare{}).
+			Where("id IN ? AND seller_id = ? AND payout_id IS NULL", shareIDs, payout.SellerID).
+			Update("payout_id", payout.ID)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected != int64(len(shareIDs)) {
+			return ErrShareTaken
+		}
+		return nil
+	})
+	return payout, err
+}
+
+func (r *payoutRepository) GetPayout(id uint64) (models.SellerPayout, error) {
+	var payout models.SellerPayout
+	err := r.db.First(&payout, id).Error
+	return payout, err
+}
+
+func (r *payoutRepository) ListPayouts(sellerID uint64) ([]models.SellerPayout, error) {
+	q := r.db.Order("created_at DESC, id DESC")
+	if sellerID != 0 {
+		q = q.Where("seller_id = ?", sellerID)
+	}
+	var payouts []models.SellerPayout
+	err := q.Find(&payouts).Error
+	return payouts, err
+}
+
+func (r *payoutRepository) MarkPayoutPaid(id uint64, reference string, at time.Time) (models.SellerPayout, error) {
+	res := r.db.Model(&models.SellerPayout{}).
+		Where("id = ? AND status = ?", id, models.PayoutPending).
+		Updates(map[string]interface{}{"status": models.PayoutPaid, "reference": reference, "paid_at": at})
+	if res.Error != nil {
+		return models.SellerPayout{}, res.Error
+	}
+	payout, err := r.GetPayout(id)
+	if err == nil && res.RowsAffected == 0 {
+		err = ErrPayoutNotPending
+	}
+	return payout, err
+}
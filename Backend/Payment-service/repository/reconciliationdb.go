@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"payment-service/models"
+
+	"gorm.io/gorm"
+)
+
+type ReconciliationRepository interface {
+	CreateRun(models.ReconciliationRun) (models.ReconciliationRun, error)
+	GetRun(id uint64) (models.ReconciliationRun, error)
+	ListRuns(limit int) ([]models.ReconciliationRun, error)
+}
+
+type reconciliationRepository struct {
+	db *gorm.DB
+}
+
+func NewReconciliationRepository(db *gorm.DB) ReconciliationRepository {
+	return &reconciliationRepository{db: db}
+}
+
+// CreateRun stores the run and all of its items in one transaction.
+func (r *reconciliationRepository) CreateRun(run models.ReconciliationRun) (models.ReconciliationRun, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		items := run.Items
+		run.Items = nil
+		if err := tx.Create(&run).Error; err != nil {
+			return err
+		}
+		for i := range items {
+			items[i].RunID = run.ID
+		}
+		if len(items) > 0 {
+			if err := tx.CreateInBatches(&items, 500).Error; err != nil {
+				return err
+			}
+		}
+		run.Items = items
+		return nil
+	})
+	return run, err
+}
+
+func (r *reconciliationRepository) GetRun(id uint64) (models.ReconciliationRun, error) {
+	var run models.ReconciliationRun
+	err := r.db.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("result, provider_reference")
+	}).First(&run, id).Error
+	return run, err
+}
+
+func (r *reconciliationRepository) ListRuns(limit int) ([]models.ReconciliationRun, error) {
+	var runs []models.ReconciliationRun
+	err := r.db.Order("created_at DESC").Limit(limit).Find(&runs).Error
+	return runs, err
+}
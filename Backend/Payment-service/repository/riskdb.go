@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"fmt"
+	"payment-service/models"
+	"payment-service/risk"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RiskRepository backs the risk rules (velocity counts and blocklist
+// lookups) and the blocklist admin API.
+type RiskRepository interface {
+	risk.VelocityCounter
+	risk.BlocklistStore
+	CreateBlocklistEntry(models.BlocklistEntry) (models.BlocklistEntry, error)
+	ListBlocklist() ([]models.BlocklistEntry, error)
+	DeleteBlocklistEntry(id uint64) error
+}
+
+type riskRepository struct {
+	db *gorm.DB
+}
+
+func NewRiskRepository(db *gorm.DB) RiskRepository {
+	return &riskRepository{db: db}
+}
+
+func (r *riskRepository) CountRecentPayments(kind, value string, since time.Time) (int64, error) {
+	q := r.db.Model(&models.Payment{}).Where("payments.created_at >= ?", since)
+	switch kind {
+	case risk.KeyUser:
+		q = q.Where("payments.user_id = ?", value)
+	case risk.KeyIP:
+		q = q.Where("payments.client_ip = ?", value)
+	case risk.KeyCardToken:
+		q = q.Joins("JOIN saved_payment_methods spm ON spm.id = payments.saved_payment_method_id").
+			Where("spm.gateway_token = ?", value)
+	default:
+		return 0, fmt.Errorf("unknown velocity key %q", kind)
+	}
+	var n int64
+	err := q.Count(&n).Error
+	return n, err
+}
+
+func (r *riskRepository) IsBlocked(kind, value string) (bool, error) {
+	var n int64
+	err := r.db.Model(&models.BlocklistEntry{}).Where("kind = ? AND value = ?", kind, value).Count(&n).Error
+	return n > 0, err
+}
+
+func (r *riskRepository) CreateBlocklistEntry(e models.BlocklistEntry) (models.BlocklistEntry, error) {
+	err := r.db.Create(&e).Error
+	return e, err
+}
+
+func (r *riskRepository) ListBlocklist() ([]models.BlocklistEntry, error) {
+	var entries []models.BlocklistEntry
+	err := r.db.Order("kind, value").Find(&entries).Error
+	return entries, err
+}
+
+func (r *riskRepository) DeleteBlocklistEntry(id uint64) error {
+	res := r.db.Delete(&models.BlocklistEntry{}, id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
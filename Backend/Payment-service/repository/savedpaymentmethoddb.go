@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"payment-service/models"
+
+	"gorm.io/gorm"
+)
+
+type SavedPaymentMethodRepository interface {
+	Create(models.SavedPaymentMethod) (models.SavedPaymentMethod, error)
+	GetByID(uint64) (models.SavedPaymentMethod, error)
+	ListByUser(uint64) ([]models.SavedPaymentMethod, error)
+	SetDefault(userID, id uint64) error
+	Delete(userID, id uint64) error
+}
+
+type savedPaymentMethodRepository struct {
+	db *gorm.DB
+}
+
+func NewSavedPaymentMethodRepository(db *gorm.DB) SavedPaymentMethodRepository {
+	return &savedPaymentMethodRepository{db: db}
+}
+
+func (r *savedPaymentMethodRepository) Create(m models.SavedPaymentMethod) (models.SavedPaymentMethod, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var existing int64
+		if err := tx.Model(&models.SavedPaymentMethod{}).Where("user_id = ?", m.UserID).Count(&existing).Error; err != nil {
+			return err
+		}
+		// A user's first method is their default whether or not they asked.
+		if existing == 0 {
+			m.IsDefault = true
+		}
+		if m.IsDefault {
+			if err := clearDefault(tx, m.UserID); err != nil {
+				return err
+			}
+		}
+		return tx.Create(&m).Error
+	})
+	return m, err
+}
+
+func (r *savedPaymentMethodRepository) GetByID(id uint64) (models.SavedPaymentMethod, error) {
+	var m models.SavedPaymentMethod
+	err := r.db.First(&m, id).Error
+	return m, err
+}
+
+func (r *savedPaymentMethodRepository) ListByUser(userID uint64) ([]models.SavedPaymentMethod, error) {
+	var methods []models.SavedPaymentMethod
+	err := r.db.Where("user_id = ?", userID).Order("is_default DESC, created_at DESC").Find(&methods).Error
+	return methods, err
+}
+
+func (r *savedPaymentMethodRepository) SetDefault(userID, id uint64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := clearDefault(tx, userID); err != nil {
+			return err
+		}
+		res := tx.Model(&models.SavedPaymentMethod{}).Where("id = ? AND user_id = ?", id, userID).Update("is_default", true)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+func (r *savedPaymentMethodRepository) Delete(userID, id uint64) error {
+	res := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.SavedPaymentMethod{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func clearDefault(tx *gorm.DB, userID uint64) error {
+	return tx.Model(&models.SavedPaymentMethod{}).
+		Where("user_id = ? AND is_default", userID).
+		Update("is_default", false).Error
+}
@@ -0,0 +1,78 @@
+// Package risk scores a payment before it is created or authorized. Each
+// Rule adds points for what it finds; the total decides whether the payment
+// goes ahead, is held for manual review, or is declined.
+package risk
+
+import (
+	"context"
+	"fmt"
+	"payment-service/money"
+)
+
+const (
+	DecisionAllow  = "allow"
+	DecisionReview = "review"
+	DecisionDeny   = "deny"
+)
+
+// Input is what the rules know about a payment attempt.
+type Input struct {
+	UserID          uint64
+	IP              string
+	CardToken       string
+	Amount          money.Money
+	BaseAmount      money.Money
+	BillingCountry  string
+	ShippingCountry string
+}
+
+// Signal is one rule's finding. Rules that find nothing return no signal.
+type Signal struct {
+	Rule   string `json:"rule"`
+	Score  int    `json:"score"`
+	Reason string `json:"reason"`
+}
+
+type Rule interface {
+	Name() string
+	Evaluate(ctx context.Context, in Input) ([]Signal, error)
+}
+
+type Result struct {
+	Score    int      `json:"score"`
+	Decision string   `json:"decision"`
+	Signals  []Signal `json:"signals"`
+}
+
+// Pipeline runs every rule and compares the summed score with the review
+// and deny thresholds.
+type Pipeline struct {
+	Rules       []Rule
+	ReviewScore int
+	DenyScore   int
+}
+
+func (p *Pipeline) Evaluate(ctx context.Context, in Input) (Result, error) {
+	res := Result{Decision: DecisionAllow, Signals: []Signal{}}
+	if p == nil {
+		return res, nil
+	}
+	for _, rule := range p.Rules {
+		signals, err := rule.Evaluate(ctx, in)
+		if err != nil {
+			return res, fmt.Errorf("risk rule %s: %w", rule.Name(), err)
+		}
+		for _, s := range signals {
+			s.Rule = rule.Name()
+			res.Score += s.Score
+			res.Signals = append(res.Signals, s)
+		}
+	}
+	switch {
+	case res.Score >= p.DenyScore:
+		res.Decision = DecisionDeny
+	case res.Score >= p.ReviewScore:
+		res.Decision = DecisionReview
+	}
+	return res, nil
+}
@@ -0,0 +1,146 @@
+package risk
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	KeyUser      = "user"
+	KeyIP        = "ip"
+	KeyCardToken = "card_token"
+	KeyCountry   = "country"
+)
+
+// VelocityCounter counts payments attempted for a key (see the Key
+// constants) since a point in time.
+type VelocityCounter interface {
+	CountRecentPayments(kind, value string, since time.Time) (int64, error)
+}
+
+// VelocityRule flags users, IPs and cards that attempt more than Max*
+// payments within Window. A zero limit disables that check.
+type VelocityRule struct {
+	Counter    VelocityCounter
+	Window     time.Duration
+	MaxPerUser int64
+	MaxPerIP   int64
+	MaxPerCard int64
+	Score      int
+}
+
+func (r *VelocityRule) Name() string { return "velocity" }
+
+func (r *VelocityRule) Evaluate(ctx context.Context, in Input) ([]Signal, error) {
+	since := time.Now().Add(-r.Window)
+	checks := []struct {
+		kind, value string
+		max         int64
+	}{
+		{KeyUser, fmt.Sprint(in.UserID), r.MaxPerUser},
+		{KeyIP, in.IP, r.MaxPerIP},
+		{KeyCardToken, in.CardToken, r.MaxPerCard},
+	}
+	var signals []Signal
+	for _, c := range checks {
+		if c.max <= 0 || c.value == "" || c.value == "0" {
+			continue
+		}
+		n, err := r.Counter.CountRecentPayments(c.kind, c.value, since)
+		if err != nil {
+			return nil, err
+		}
+		if n >= c.max {
+			signals = append(signals, Signal{
+				Score:  r.Score,
+				Reason: fmt.Sprintf("%d payments by %s in the last %s", n, c.kind, r.Window),
+			})
+		}
+	}
+	return signals, nil
+}
+
+// AmountThreshold adds Score when the base-currency amount reaches Minor.
+type AmountThreshold struct {
+	Minor int64
+	Score int
+}
+
+// AmountRule scores large payments. Only the highest threshold reached
+// counts.
+type AmountRule struct {
+	Thresholds []AmountThreshold
+}
+
+func (r *AmountRule) Name() string { return "amount" }
+
+func (r *AmountRule) Evaluate(ctx context.Context, in Input) ([]Signal, error) {
+	var best *AmountThreshold
+	for i := range r.Thresholds {
+		t := &r.Thresholds[i]
+		if in.BaseAmount.Minor >= t.Minor && (best == nil || t.Minor > best.Minor) {
+			best = t
+		}
+	}
+	if best == nil {
+		return nil, nil
+	}
+	return []Signal{{Score: best.Score, Reason: fmt.Sprintf("amount %s at or above threshold", in.BaseAmount)}}, nil
+}
+
+// CountryMismatchRule scores payments whose billing and shipping countries
+// differ.
+type CountryMismatchRule struct {
+	Score int
+}
+
+func (r *CountryMismatchRule) Name() string { return "country_mismatch" }
+
+func (r *CountryMismatchRule) Evaluate(ctx context.Context, in Input) ([]Signal, error) {
+	if in.BillingCountry == "" || in.ShippingCountry == "" || strings.EqualFold(in.BillingCountry, in.ShippingCountry) {
+		return nil, nil
+	}
+	return []Signal{{
+		Score:  r.Score,
+		Reason: fmt.Sprintf("billing country %s differs from shipping country %s", in.BillingCountry, in.ShippingCountry),
+	}}, nil
+}
+
+// BlocklistStore reports whether a key (see the Key constants) is blocked.
+type BlocklistStore interface {
+	IsBlocked(kind, value string) (bool, error)
+}
+
+// BlocklistRule scores payments from blocked users, IPs, cards or billing
+// countries. Its score is normally set high enough to deny outright.
+type BlocklistRule struct {
+	Store BlocklistStore
+	Score int
+}
+
+func (r *BlocklistRule) Name() string { return "blocklist" }
+
+func (r *BlocklistRule) Evaluate(ctx context.Context, in Input) ([]Signal, error) {
+	keys := []struct{ kind, value string }{
+		{KeyUser, fmt.Sprint(in.UserID)},
+		{KeyIP, in.IP},
+		{KeyCardToken, in.CardToken},
+		{KeyCountry, strings.ToUpper(in.BillingCountry)},
+	}
+	var signals []Signal
+	for _, k := range keys {
+		if k.value == "" || k.value == "0" {
+			continue
+		}
+		blocked, err := r.Store.IsBlocked(k.kind, k.value)
+		if err != nil {
+			return nil, err
+		}
+		if blocked {
+			signals = append(signals, Signal{Score: r.Score, Reason: k.kind + " is blocklisted"})
+		}
+	}
+	return signals, nil
+}
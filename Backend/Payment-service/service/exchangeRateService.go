@@ -0,0 +1,183 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"payment-service/models"
+	"payment-service/money"
+	"payment-service/repository"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnsupportedCurrency = errors.New("currency is not accepted")
+	ErrRateNotFound        = errors.New("no exchange rate available for currency")
+)
+
+type ExchangeRateService interface {
+	BaseCurrency() string
+	SetRate(currency, rate, source string, effectiveAt time.Time) (models.ExchangeRate, error)
+	LoadFile(path string) error
+	LatestRates() ([]models.ExchangeRate, error)
+	History(currency string, limit int) ([]models.ExchangeRate, error)
+	// ToBase converts an amount in any accepted currency into the base
+	// currency, returning the rate used (nil when no conversion was needed).
+	ToBase(money.Money) (money.Money, *models.ExchangeRate, error)
+}
+
+type exchangeRateService struct {
+	repo       repository.ExchangeRateRepository
+	base       string
+	currencies map[string]bool
+}
+
+func NewExchangeRateService(r repository.ExchangeRateRepository, base string, currencies []string) ExchangeRateService {
+	s := &exchangeRateService{repo: r, base: base, currencies: map[string]bool{base: true}}
+	for _, c := range currencies {
+		if code, err := money.NormalizeCurrency(c); err == nil {
+			s.currencies[code] = true
+		}
+	}
+	return s
+}
+
+func (s *exchangeRateService) BaseCurrency() string {
+	return s.base
+}
+
+func (s *exchangeRateService) normalize(currency string) (string, error) {
+	code, err := money.NormalizeCurrency(currency)
+	if err != nil {
+		return "", err
+	}
+	if !s.currencies[code] {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedCurrency, code)
+	}
+	return code, nil
+}
+
+func (s *exchangeRateService) SetRate(currency, rate, source string, effectiveAt time.Time) (models.ExchangeRate, error) {
+	code, err := s.normalize(currency)
+	if err != nil {
+		return models.ExchangeRate{}, err
+	}
+	if code == s.base {
+		return models.ExchangeRate{}, fmt.Errorf("%w: %s is the base currency", ErrUnsupportedCurrency, code)
+	}
+	if _, err := money.ParseRate(rate); err != nil {
+		return models.ExchangeRate{}, err
+	}
+	if effectiveAt.IsZero() {
+		effectiveAt = time.Now()
+	}
+	return s.repo.Create(models.ExchangeRate{
+		BaseCurrency: s.base,
+		Currency:     code,
+		Rate:         rate,
+		Source:       source,
+		EffectiveAt:  effectiveAt.UTC(),
+	})
+}
+
+type ratesFile struct {
+	Base  string `json:"base"`
+	Rates []struct {
+		Currency    string      `json:"currency"`
+		Rate        json.Number `json:"rate"`
+		EffectiveAt time.Time   `json:"effective_at"`
+	} `json:"rates"`
+}
+
+// LoadFile imports rates from a JSON file of the form
+//
+//	{"base": "INR", "rates": [{"currency": "USD", "rate": "83.12", "effective_at": "2026-10-01T00:00:00Z"}]}
+//
+// Entries already present in the history are skipped, so the same file
+// can be loaded on every start. Entries without effective_at take effect
+// when the file was last modified.
+func (s *exchangeRateService) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	var f ratesFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	if f.Base != "" && !strings.EqualFold(strings.TrimSpace(f.Base), s.base) {
+		return fmt.Errorf("%s has base %s, expected %s", path, f.Base, s.base)
+	}
+	for _, entry := range f.Rates {
+		code, err := s.normalize(entry.Currency)
+		if err != nil {
+			return err
+		}
+		rate, err := money.ParseRate(entry.Rate.String())
+		if err != nil {
+			return fmt.Errorf("%s rate for %s: %w", path, code, err)
+		}
+		if entry.EffectiveAt.IsZero() {
+			entry.EffectiveAt = info.ModTime().UTC().Truncate(time.Second)
+		}
+		existing, err := s.repo.Latest(s.base, code, entry.EffectiveAt)
+		if err == nil && existing.EffectiveAt.Equal(entry.EffectiveAt) {
+			if current, err := money.ParseRate(existing.Rate); err == nil && current.Cmp(rate) == 0 {
+				continue
+			}
+		}
+		if _, err := s.SetRate(code, entry.Rate.String(), "file", entry.EffectiveAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *exchangeRateService) LatestRates() ([]models.ExchangeRate, error) {
+	return s.repo.LatestAll(s.base, time.Now())
+}
+
+func (s *exchangeRateService) History(currency string, limit int) ([]models.ExchangeRate, error) {
+	code, err := s.normalize(currency)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	return s.repo.History(s.base, code, limit)
+}
+
+func (s *exchangeRateService) ToBase(m money.Money) (money.Money, *models.ExchangeRate, error) {
+	code, err := s.normalize(m.Currency)
+	if err != nil {
+		return money.Money{}, nil, err
+	}
+	if code == s.base {
+		return m, nil, nil
+	}
+	rate, err := s.repo.Latest(s.base, code, time.Now())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return money.Money{}, nil, fmt.Errorf("%w %s", ErrRateNotFound, code)
+		}
+		return money.Money{}, nil, err
+	}
+	r, err := money.ParseRate(rate.Rate)
+	if err != nil {
+		return money.Money{}, nil, err
+	}
+	converted, err := m.Convert(r, s.base)
+	if err != nil {
+		return money.Money{}, nil, err
+	}
+	return converted, &rate, nil
+}
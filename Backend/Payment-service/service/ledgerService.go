@@ -0,0 +1,334 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"payment-service/models"
+	"payment-service/money"
+	"payment-service/repository"
+	"strings"
+	"time"
+)
+
+var (
+	ErrGiftCardNotFound    = errors.New("gift card not found")
+	ErrGiftCardExpired     = errors.New("gift card has expired")
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	errUnbalancedEntry     = errors.New("journal entry does not balance")
+)
+
+// GiftCardBalance is a gift card with its current balance.
+type GiftCardBalance struct {
+	models.GiftCard
+	Balance money.Money `json:"balance"`
+}
+
+// LedgerService keeps gift card and store credit balances in a
+// double-entry ledger. Every change is a journal entry; balances are
+// always derived from the entries.
+type LedgerService interface {
+	// IssueGiftCard creates a card loaded with amount. The returned code is
+	// not stored anywhere and cannot be shown again.
+	IssueGiftCard(amount money.Money, expiresAt *time.Time, issuedBy uint64) (GiftCardBalance, string, error)
+	GiftCardByCode(code string) (GiftCardBalance, error)
+	GiftCard(id uint64) (GiftCardBalance, error)
+	GiftCardHistory(id uint64, limit int) ([]models.JournalEntry, error)
+	StoreCredit(userID uint64) ([]money.Money, error)
+
+	// Redeem spends amount from a gift card, or from the user's store
+	// credit when giftCardID is nil. It fails with ErrInsufficientBalance
+	// rather than take a balance below zero.
+	Redeem(userID uint64, giftCardID *uint64, amount money.Money, reference string) (models.JournalEntry, error)
+	// CreditStoreCredit adds a refund to the user's store credit.
+	CreditStoreCredit(userID uint64, amount money.Money, reference string) (models.JournalEntry, error)
+	// Reverse undoes an entry by posting its opposite. An entry can only be
+	// reversed once, and reversals themselves cannot be reversed.
+	Reverse(entryID uint64, memo string) (models.JournalEntry, error)
+	// ExpireGiftCards writes off what is left on expired cards and returns
+	// how many cards were expired.
+	ExpireGiftCards(now time.Time) (int, error)
+}
+
+type ledgerService struct {
+	repo repository.LedgerRepository
+}
+
+func NewLedgerService(repo repository.LedgerRepository) LedgerService {
+	return &ledgerService{repo: repo}
+}
+
+func (s *ledgerService) IssueGiftCard(amount money.Money, expiresAt *time.Time, issuedBy uint64) (GiftCardBalance, string, error) {
+	if err := amount.Validate(); err != nil {
+		return GiftCardBalance{}, "", err
+	}
+	if !amount.IsPositive() {
+		return GiftCardBalance{}, "", ErrInvalidAmount
+	}
+	code, err := newGiftCardCode()
+	if err != nil {
+		return GiftCardBalance{}, "", err
+	}
+	normalized := normalizeGiftCardCode(code)
+	card, err := s.repo.CreateGiftCard(models.GiftCard{
+		CodeHash:      hashGiftCardCode(normalized),
+		Last4:         normalized[len(normalized)-4:],
+		InitialAmount: amount,
+		ExpiresAt:     expiresAt,
+		IssuedBy:      issuedBy,
+	})
+	if err != nil {
+		return GiftCardBalance{}, "", err
+	}
+	issued, err := s.repo.Account(models.AccountIssued, 0, amount.Currency)
+	if err != nil {
+		return GiftCardBalance{}, "", err
+	}
+	ref := fmt.Sprintf("gift_card:%d", card.ID)
+	if _, err := s.transfer(models.EntryIssue, issued.ID, card.AccountID, amount, ref); err != nil {
+		return GiftCardBalance{}, "", err
+	}
+	return GiftCardBalance{GiftCard: card, Balance: amount}, code, nil
+}
+
+func (s *ledgerService) GiftCardByCode(code string) (GiftCardBalance, error) {
+	card, err := s.repo.GetGiftCardByCodeHash(hashGiftCardCode(normalizeGiftCardCode(code)))
+	if err != nil {
+		return GiftCardBalance{}, notFoundAs(err, ErrGiftCardNotFound)
+	}
+	return s.withBalance(card)
+}
+
+func (s *ledgerService) GiftCard(id uint64) (GiftCardBalance, error) {
+	card, err := s.repo.GetGiftCard(id)
+	if err != nil {
+		return GiftCardBalance{}, notFoundAs(err, ErrGiftCardNotFound)
+	}
+	return s.withBalance(card)
+}
+
+func (s *ledgerService) withBalance(card models.GiftCard) (GiftCardBalance, error) {
+	b, err := s.repo.Balance(card.AccountID)
+	if err != nil {
+		return GiftCardBalance{}, err
+	}
+	return GiftCardBalance{GiftCard: card, Balance: money.Money{Minor: b, Currency: card.InitialAmount.Currency}}, nil
+}
+
+func (s *ledgerService) GiftCardHistory(id uint64, limit int) ([]models.JournalEntry, error) {
+	card, err := s.repo.GetGiftCard(id)
+	if err != nil {
+		return nil, notFoundAs(err, ErrGiftCardNotFound)
+	}
+	return s.repo.ListEntries(card.AccountID, limit)
+}
+
+func (s *ledgerService) StoreCredit(userID uint64) ([]money.Money, error) {
+	accounts, err := s.repo.ListAccounts(models.AccountStoreCredit, userID)
+	if err != nil {
+		return nil, err
+	}
+	balances := []money.Money{}
+	for _, a := range accounts {
+		b, err := s.repo.Balance(a.ID)
+		if err != nil {
+			return nil, err
+		}
+		balances = append(balances, money.Money{Minor: b, Currency: a.Currency})
+	}
+	return balances, nil
+}
+
+func (s *ledgerService) Redeem(userID uint64, giftCardID *uint64, amount money.Money, reference string) (models.JournalEntry, error) {
+	if !amount.IsPositive() {
+		return models.JournalEntry{}, ErrInvalidAmount
+	}
+	var from models.LedgerAccount
+	if giftCardID != nil {
+		card, err := s.repo.GetGiftCard(*giftCardID)
+		if err != nil {
+			return models.JournalEntry{}, notFoundAs(err, ErrGiftCardNotFound)
+		}
+		if card.Expired(time.Now()) {
+			return models.JournalEntry{}, ErrGiftCardExpired
+		}
+		if card.InitialAmount.Currency != amount.Currency {
+			return models.JournalEntry{}, fmt.Errorf("%w: gift card is in %s", money.ErrCurrencyMismatch, card.InitialAmount.Currency)
+		}
+		from = models.LedgerAccount{ID: card.AccountID}
+	} else {
+		var err error
+		if from, err = s.repo.Account(models.AccountStoreCredit, userID, amount.Currency); err != nil {
+			return models.JournalEntry{}, err
+		}
+	}
+	redeemed, err := s.repo.Account(models.AccountRedeemed, 0, amount.Currency)
+	if err != nil {
+		return models.JournalEntry{}, err
+	}
+	return s.transfer(models.EntryRedeem, from.ID, redeemed.ID, amount, reference)
+}
+
+func (s *ledgerService) CreditStoreCredit(userID uint64, amount money.Money, reference string) (models.JournalEntry, error) {
+	if !amount.IsPositive() {
+		return models.JournalEntry{}, ErrInvalidAmount
+	}
+	credit, err := s.repo.Account(models.AccountStoreCredit, userID, amount.Currency)
+	if err != nil {
+		return models.JournalEntry{}, err
+	}
+	refunded, err := s.repo.Account(models.AccountRefunded, 0, amount.Currency)
+	if err != nil {
+		return models.JournalEntry{}, err
+	}
+	return s.transfer(models.EntryRefundCredit, refunded.ID, credit.ID, amount, reference)
+}
+
+func (s *ledgerService) Reverse(entryID uint64, memo string) (models.JournalEntry, error) {
+	original, err := s.repo.GetEntry(entryID)
+	if err != nil {
+		return models.JournalEntry{}, err
+	}
+	if original.Kind == models.EntryReversal {
+		return models.JournalEntry{}, fmt.Errorf("%w: entry %d is a reversal", repository.ErrAlreadyReversed, entryID)
+	}
+	var ids []uint64
+	lines := make([]models.JournalLine, 0, len(original.Lines))
+	for _, l := range original.Lines {
+		ids = append(ids, l.AccountID)
+		lines = append(lines, models.JournalLine{AccountID: l.AccountID, Amount: -l.Amount, Currency: l.Currency})
+	}
+	entry := models.JournalEntry{Kind: models.EntryReversal, Reference: original.Reference, Memo: memo, ReversesID: &original.ID}
+	return s.post(entry, ids, func(map[uint64]repository.AccountBalance) []models.JournalLine { return lines })
+}
+
+func (s *ledgerService) ExpireGiftCards(now time.Time) (int, error) {
+	cards, err := s.repo.ListExpiredGiftCards(now, 100)
+	if err != nil {
+		return 0, err
+	}
+	expired := 0
+	for _, card := range cards {
+		left, err := s.repo.Balance(card.AccountID)
+		if err != nil {
+			return expired, err
+		}
+		if left == 0 {
+			if err := s.repo.MarkGiftCardExpired(card.ID, now); err != nil {
+				return expired, err
+			}
+			expired++
+			continue
+		}
+		breakage, err := s.repo.Account(models.AccountBreakage, 0, card.InitialAmount.Currency)
+		if err != nil {
+			return expired, err
+		}
+		entry := models.JournalEntry{Kind: models.EntryExpire, Reference: fmt.Sprintf("gift_card:%d", card.ID)}
+		_, err = s.post(entry, []uint64{card.AccountID, breakage.ID}, func(locked map[uint64]repository.AccountBalance) []models.JournalLine {
+			left := locked[card.AccountID].Balance
+			return []models.JournalLine{
+				{AccountID: card.AccountID, Amount: -left, Currency: card.InitialAmount.Currency},
+				{AccountID: breakage.ID, Amount: left, Currency: card.InitialAmount.Currency},
+			}
+		})
+		if err != nil {
+			return expired, fmt.Errorf("expire gift card %d: %w", card.ID, err)
+		}
+		if err := s.repo.MarkGiftCardExpired(card.ID, now); err != nil {
+			return expired, err
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+// transfer moves amount from one account to another.
+func (s *ledgerService) transfer(kind string, from, to uint64, amount money.Money, reference string) (models.JournalEntry, error) {
+	entry := models.JournalEntry{Kind: kind, Reference: reference}
+	return s.post(entry, []uint64{from, to}, func(map[uint64]repository.AccountBalance) []models.JournalLine {
+		return []models.JournalLine{
+			{AccountID: from, Amount: -amount.Minor, Currency: amount.Currency},
+			{AccountID: to, Amount: amount.Minor, Currency: amount.Currency},
+		}
+	})
+}
+
+// post records entry with the lines built from the locked balances,
+// refusing entries that do not balance or that would take a customer
+// account below zero.
+func (s *ledgerService) post(entry models.JournalEntry, accountIDs []uint64, build func(map[uint64]repository.AccountBalance) []models.JournalLine) (models.JournalEntry, error) {
+	return s.repo.PostLocked(accountIDs, func(locked map[uint64]repository.AccountBalance) (models.JournalEntry, error) {
+		entry.Lines = build(locked)
+		if !entry.Balanced() {
+			return entry, errUnbalancedEntry
+		}
+		for _, l := range entry.Lines {
+			a, ok := locked[l.AccountID]
+			if !ok || a.Currency != l.Currency {
+				return entry, fmt.Errorf("%w: bad line for account %d", errUnbalancedEntry, l.AccountID)
+			}
+			a.Balance += l.Amount
+			locked[l.AccountID] = a
+			if a.Customer() && a.Balance < 0 {
+				return entry, ErrInsufficientBalance
+			}
+		}
+		return entry, nil
+	})
+}
+
+// RunGiftCardExpiry writes off expired gift cards every interval until ctx
+// is cancelled.
+func RunGiftCardExpiry(ctx context.Context, svc LedgerService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			n, err := svc.ExpireGiftCards(now)
+			if err != nil {
+				log.Println("gift card expiry failed: ", err)
+				continue
+			}
+			if n > 0 {
+				log.Printf("expired %d gift cards", n)
+			}
+		}
+	}
+}
+
+// Gift card codes avoid characters that are easy to misread (0/O, 1/I).
+const giftCardAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// newGiftCardCode returns a random code like "K7QD-2MZP-XW4N-9HTA".
+func newGiftCardCode() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for i, c := range buf {
+		if i > 0 && i%4 == 0 {
+			b.WriteByte('-')
+		}
+		b.WriteByte(giftCardAlphabet[int(c)%len(giftCardAlphabet)])
+	}
+	return b.String(), nil
+}
+
+func normalizeGiftCardCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+func hashGiftCardCode(normalized string) string {
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"payment-service/models"
+	"payment-service/money"
+	"payment-service/risk"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTenderMismatch   = errors.New("tenders must add up to the order total")
+	ErrInvalidTender    = errors.New("unsupported payment method for a tender")
+	ErrOrderAlreadyPaid = errors.New("order already has payments")
+	ErrShareMismatch    = errors.New("seller shares must fit within the order total")
+)
+
+// OrderPaymentSummary is an order total with its tenders. The order is
+// fully paid only once every tender is settled.
+type OrderPaymentSummary struct {
+	models.OrderPayment
+	Paid        money.Money      `json:"paid"`
+	Outstanding money.Money      `json:"outstanding"`
+	FullyPaid   bool             `json:"fully_paid"`
+	Tenders     []models.Payment `json:"tenders"`
+}
+
+func (s *paymentService) PayOrder(order models.OrderPayment, tenders []models.Payment) (OrderPaymentSummary, error) {
+	if err := order.Total.Validate(); err != nil {
+		return OrderPaymentSummary{}, err
+	}
+	if !order.Total.IsPositive() {
+		return OrderPaymentSummary{}, ErrInvalidAmount
+	}
+	if len(tenders) == 0 {
+		return OrderPaymentSummary{}, fmt.Errorf("%w: no tenders given", ErrTenderMismatch)
+	}
+	if err := validateShares(order); err != nil {
+		return OrderPaymentSummary{}, err
+	}
+	if _, err := s.repo.GetOrderPayment(order.OrderID); err == nil {
+		return OrderPaymentSummary{}, ErrOrderAlreadyPaid
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return OrderPaymentSummary{}, err
+	}
+
+	sum := money.Money{Currency: order.Total.Currency}
+	decision := risk.DecisionAllow
+	for i := range tenders {
+		t := &tenders[i]
+		t.ID = 0
+		t.OrderID = order.OrderID
+		t.UserID = order.UserID
+		t.Intent = models.IntentSale
+		if err := s.prepare(t); err != nil {
+			return OrderPaymentSummary{}, fmt.Errorf("tender %d: %w", i+1, err)
+		}
+		if !tenderMethod(t.PaymentMethod) {
+			return OrderPaymentSummary{}, fmt.Errorf("%w: %q", ErrInvalidTender, t.PaymentMethod)
+		}
+		var err error
+		if sum, err = sum.Add(t.Amount); err != nil {
+			return OrderPaymentSummary{}, fmt.Errorf("tender %d: %w", i+1, err)
+		}
+		decision = stricterDecision(decision, t.RiskDecision)
+	}
+	if sum.Minor != order.Total.Minor {
+		return OrderPaymentSummary{}, fmt.Errorf("%w: tenders total %s, order total %s", ErrTenderMismatch, sum, order.Total)
+	}
+
+	// Risk applies to the order as a whole: one suspicious tender holds
+	// (or declines) all of them.
+	var redeemed []uint64
+	for i := range tenders {
+		switch decision {
+		case risk.DecisionDeny:
+			tenders[i].Status = models.StatusDeclined
+		case risk.DecisionReview:
+			tenders[i].Status = models.StatusOnHold
+		default:
+			entryID, err := s.start(&tenders[i])
+			if err != nil {
+				s.undoRedemptions(redeemed, order.OrderID)
+				return OrderPaymentSummary{}, fmt.Errorf("tender %d: %w", i+1, err)
+			}
+			redeemed = append(redeemed, entryID)
+		}
+	}
+	order, created, err := s.repo.CreateOrderPayment(order, tenders)
+	if err != nil {
+		s.undoRedemptions(redeemed, order.OrderID)
+		return OrderPaymentSummary{}, err
+	}
+	tenders = created
+	summary := summarizeOrder(order, tenders)
+	if decision == risk.DecisionDeny {
+		return summary, ErrPaymentDeclined
+	}
+	return summary, nil
+}
+
+// validateShares checks the sellers' shares of an order: each positive, in
+// the order currency, and together no more than the total. The remainder is
+// the platform's.
+func validateShares(order models.OrderPayment) error {
+	sum := money.Money{Currency: order.Total.Currency}
+	for i := range order.Shares {
+		share := &order.Shares[i]
+		share.ID = 0
+		share.OrderID = order.OrderID
+		share.PayoutID = nil
+		if share.SellerID == 0 {
+			return fmt.Errorf("%w: share %d has no seller", ErrShareMismatch, i+1)
+		}
+		if err := share.Amount.Validate(); err != nil {
+			return fmt.Errorf("share %d: %w", i+1, err)
+		}
+		if !share.Amount.IsPositive() {
+			return fmt.Errorf("share %d: %w", i+1, ErrInvalidAmount)
+		}
+		var err error
+		if sum, err = sum.Add(share.Amount); err != nil {
+			return fmt.Errorf("share %d: %w", i+1, err)
+		}
+	}
+	if sum.Minor > order.Total.Minor {
+		return fmt.Errorf("%w: shares total %s, order total %s", ErrShareMismatch, sum, order.Total)
+	}
+	return nil
+}
+
+func (s *paymentService) GetOrderPaymentSummary(orderID uint64) (OrderPaymentSummary, error) {
+	order, err := s.repo.GetOrderPayment(orderID)
+	if err != nil {
+		return OrderPaymentSummary{}, err
+	}
+	tenders, err := s.repo.ListByOrder(orderID)
+	if err != nil {
+		return OrderPaymentSummary{}, err
+	}
+	return summarizeOrder(order, tenders), nil
+}
+
+func (s *paymentService) CollectCashPayment(id, collectorID uint64) (models.Payment, error) {
+	return s.repo.UpdateLocked(id, func(p *models.Payment) error {
+		if p.PaymentMethod != models.MethodCOD || p.Status != models.StatusAwaitingCollection {
+			return fmt.Errorf("%w: payment is %s %s, not cash awaiting collection", ErrInvalidTransition, p.PaymentMethod, p.Status)
+		}
+		now := time.Now()
+		p.Status = models.StatusCollected
+		p.CollectedAt = &now
+		p.CollectedBy = &collectorID
+		return nil
+	})
+}
+
+func summarizeOrder(order models.OrderPayment, tenders []models.Payment) OrderPaymentSummary {
+	summary := OrderPaymentSummary{
+		OrderPayment: order,
+		Paid:         money.Money{Currency: order.Total.Currency},
+		Tenders:      tenders,
+		FullyPaid:    len(tenders) > 0,
+	}
+	for _, t := range tenders {
+		if t.Settled() && t.Amount.Currency == order.Total.Currency {
+			summary.Paid.Minor += t.SettledAmount().Minor
+		} else {
+			summary.FullyPaid = false
+		}
+	}
+	summary.Outstanding = money.Money{Minor: order.Total.Minor - summary.Paid.Minor, Currency: order.Total.Currency}
+	if summary.Outstanding.IsPositive() {
+		summary.FullyPaid = false
+	}
+	if summary.Tenders == nil {
+		summary.Tenders = []models.Payment{}
+	}
+	return summary
+}
+
+func (s *paymentService) undoRedemptions(entryIDs []uint64, orderID uint64) {
+	for _, id := range entryIDs {
+		s.undoRedemption(id, orderID)
+	}
+}
+
+// initialStatus is where an allowed payment starts: cash on delivery waits
+// for the courier, everything else for the processor (see start for gift
+// cards and store credit).
+func initialStatus(p models.Payment) string {
+	if p.PaymentMethod == models.MethodCOD {
+		return models.StatusAwaitingCollection
+	}
+	return models.StatusPending
+}
+
+func tenderMethod(method string) bool {
+	switch method {
+	case models.MethodCard, models.MethodUPI, models.MethodWallet, models.MethodGiftCard, models.MethodStoreCredit, models.MethodCOD:
+		return true
+	}
+	return false
+}
+
+func stricterDecision(a, b string) string {
+	rank := map[string]int{risk.DecisionAllow: 0, risk.DecisionReview: 1, risk.DecisionDeny: 2}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"payment-service/models"
+	"payment-service/money"
+	"time"
+)
+
+const expireBatchSize = 100
+
+func (s *paymentService) AuthorizePayment(p models.Payment) (models.Payment, error) {
+	p.Intent = models.IntentAuthorize
+	if p.PaymentMethod == models.MethodCOD || models.LedgerMethod(p.PaymentMethod) {
+		return p, fmt.Errorf("%w: %s payments cannot be authorized", ErrInvalidTender, p.PaymentMethod)
+	}
+	if err := s.prepare(&p); err != nil {
+		return p, err
+	}
+	if held, err := s.holdIfRisky(&p); held || err != nil {
+		return p, err
+	}
+	now := time.Now()
+	expires := now.Add(s.authTTL)
+	p.Status = models.StatusAuthorized
+	p.AuthorizedAt = &now
+	p.AuthExpiresAt = &expires
+	return s.repo.Create(p)
+}
+
+func (s *paymentService) CapturePayment(id uint64, amount *money.Money) (models.Payment, error) {
+	now := time.Now()
+	expired := false
+	payment, err := s.repo.UpdateLocked(id, func(p *models.Payment) error {
+		if p.Status != models.StatusAuthorized && p.Status != models.StatusPartiallyCaptured {
+			return fmt.Errorf("%w: cannot capture a %s payment", ErrInvalidTransition, p.Status)
+		}
+		if p.AuthExpiresAt != nil && !now.Before(*p.AuthExpiresAt) {
+			// Record the expiry rather than failing silently; the caller
+			// still gets ErrAuthorizationExpired below.
+			expired = true
+			voidRemainder(p, now, "expired")
+			return nil
+		}
+
+		remaining := p.Remaining()
+		capture := remaining
+		if amount != nil {
+			capture = *amount
+		}
+		if capture.Currency != remaining.Currency {
+			return fmt.Errorf("%w: %s and %s", money.ErrCurrencyMismatch, capture.Currency, remaining.Currency)
+		}
+		if !capture.IsPositive() {
+			return ErrInvalidAmount
+		}
+		if capture.Minor > remaining.Minor {
+			return fmt.Errorf("%w: %s remaining", ErrCaptureTooLarge, remaining)
+		}
+
+		p.Captured.Minor += capture.Minor
+		p.CapturedAt = &now
+		if p.Captured.Minor == p.Amount.Minor {
+			p.Status = models.StatusCaptured
+		} else {
+			p.Status = models.StatusPartiallyCaptured
+		}
+		return nil
+	})
+	if err == nil && expired {
+		return payment, ErrAuthorizationExpired
+	}
+	return payment, err
+}
+
+func (s *paymentService) VoidPayment(id uint64, reason string) (models.Payment, error) {
+	if reason == "" {
+		reason = "requested"
+	}
+	return s.repo.UpdateLocked(id, func(p *models.Payment) error {
+		if p.Status != models.StatusAuthorized && p.Status != models.StatusPartiallyCaptured {
+			return fmt.Errorf("%w: cannot void a %s payment", ErrInvalidTransition, p.Status)
+		}
+		voidRemainder(p, time.Now(), reason)
+		return nil
+	})
+}
+
+func (s *paymentService) ExpireAuthorizations(now time.Time) (int, error) {
+	expired := 0
+	for {
+		batch, err := s.repo.ListExpiredAuthorizations(now, expireBatchSize)
+		if err != nil {
+			return expired, err
+		}
+		for _, candidate := range batch {
+			voided := false
+			_, err := s.repo.UpdateLocked(candidate.ID, func(p *models.Payment) error {
+				// Re-check under the lock: it may have been captured meanwhile.
+				if (p.Status == models.StatusAuthorized || p.Status == models.StatusPartiallyCaptured) &&
+					p.AuthExpiresAt != nil && !now.Before(*p.AuthExpiresAt) {
+					voidRemainder(p, now, "expired")
+					voided = true
+				}
+				return nil
+			})
+			if err != nil {
+				return expired, err
+			}
+			if voided {
+				expired++
+			}
+		}
+		if len(batch) < expireBatchSize {
+			return expired, nil
+		}
+	}
+}
+
+// voidRemainder releases the uncaptured part of an authorization. A payment
+// that was partly captured keeps what was taken and ends up captured.
+func voidRemainder(p *models.Payment, now time.Time, reason string) {
+	p.VoidedAt = &now
+	p.VoidReason = reason
+	if p.Captured.Minor > 0 {
+		p.Status = models.StatusCaptured
+	} else {
+		p.Status = models.StatusVoided
+	}
+}
+
+// RunAuthorizationExpiry voids stale authorizations every interval until
+// ctx is cancelled.
+func RunAuthorizationExpiry(ctx context.Context, svc PaymentService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			n, err := svc.ExpireAuthorizations(now)
+			if err != nil {
+				log.Println("authorization expiry failed: ", err)
+				continue
+			}
+			if n > 0 {
+				log.Printf("voided %d expired authorizations", n)
+			}
+		}
+	}
+}
@@ -1,57 +1,411 @@
 package service
 
 import (
+	"context"
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"log"
 	"payment-service/models"
+	"payment-service/money"
 	"payment-service/repository"
+	"payment-service/risk"
+	"strconv"
+	"strings"
+	"time"
 )
 
+var (
+	ErrInvalidAmount        = errors.New("payment amount must be positive")
+	ErrInvalidTransition    = errors.New("payment is not in a state that allows this operation")
+	ErrAuthorizationExpired = errors.New("authorization has expired")
+	ErrCaptureTooLarge      = errors.New("capture amount exceeds the remaining authorization")
+	ErrInvalidCursor        = errors.New("invalid pagination cursor")
+	ErrPaymentDeclined      = errors.New("payment declined")
+)
+
+// processorTransitions are the status changes UpdatePaymentStatus may
+// record. Everything else (capture, void, refund, collection, review) has
+// its own operation with its own checks.
+var processorTransitions = map[string][]string{
+	models.StatusPending: {models.StatusSuccess, models.StatusDeclined},
+}
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// PaymentPage is one page of a payment listing. NextCursor is empty on the
+// last page.
+type PaymentPage struct {
+	Items      []models.Payment `json:"items"`
+	Total      int64            `json:"total"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
 type PaymentService interface {
 	CreatePayment(models.Payment) (models.Payment, error)
 	GetPaymentByID(uint64) (models.Payment, error)
-	ListPayments() ([]models.Payment, error)
-	UpdatePaymentStatus(uint64, string) (models.Payment, error)
+	// ListPayments pages through payments matching filter; cursor is the
+	// NextCursor of the previous page, or empty for the first page.
+	ListPayments(filter repository.PaymentFilter, cursor string) (PaymentPage, error)
+	// UpdatePaymentStatus records the processor's outcome; an empty
+	// providerReference leaves the stored one unchanged.
+	UpdatePaymentStatus(id uint64, status, providerReference string) (models.Payment, error)
+	// RefundPayment returns the money the way it came; gift card and store
+	// credit payments are refunded as store credit.
 	RefundPayment(uint64) (models.Payment, error)
+	// RefundToStoreCredit refunds any settled payment as store credit.
+	RefundToStoreCredit(uint64) (models.Payment, error)
+
+	// AuthorizePayment places a hold for the amount without taking it.
+	AuthorizePayment(models.Payment) (models.Payment, error)
+	// CapturePayment takes amount (the whole remainder when nil) from an
+	// authorization. Several partial captures are allowed.
+	CapturePayment(id uint64, amount *money.Money) (models.Payment, error)
+	// VoidPayment releases whatever part of an authorization is uncaptured.
+	VoidPayment(id uint64, reason string) (models.Payment, error)
+	// ExpireAuthorizations voids authorizations past their expiry and
+	// returns how many were voided.
+	ExpireAuthorizations(now time.Time) (int, error)
+
+	// ListHeldPayments returns the manual review queue, oldest first.
+	ListHeldPayments() ([]models.Payment, error)
+	// ReviewPayment approves or rejects a payment held by the risk checks.
+	ReviewPayment(id uint64, approve bool, reviewerID uint64, note string) (models.Payment, error)
+
+	// PayOrder splits an order total across several tenders (card, UPI,
+	// wallet, gift card or cash on delivery) that must add up to it exactly.
+	PayOrder(order models.OrderPayment, tenders []models.Payment) (OrderPaymentSummary, error)
+	GetOrderPaymentSummary(orderID uint64) (OrderPaymentSummary, error)
+	// CollectCashPayment is called by the delivery flow once the courier
+	// has the cash for a cash on delivery payment.
+	CollectCashPayment(id, collectorID uint64) (models.Payment, error)
 }
 
 type paymentService struct {
-	repo repository.PaymentRepository
+	repo    repository.PaymentRepository
+	rates   ExchangeRateService
+	methods SavedPaymentMethodService
+	risk    *risk.Pipeline
+	ledger  LedgerService
+	authTTL time.Duration
 }
 
-func NewPaymentService(r repository.PaymentRepository) PaymentService {
-	return &paymentService{repo: r}
+func NewPaymentService(r repository.PaymentRepository, rates ExchangeRateService, methods SavedPaymentMethodService, riskPipeline *risk.Pipeline, ledger LedgerService, authTTL time.Duration) PaymentService {
+	return &paymentService{repo: r, rates: rates, methods: methods, risk: riskPipeline, ledger: ledger, authTTL: authTTL}
 }
 
 func (s *paymentService) CreatePayment(p models.Payment) (models.Payment, error) {
-	p.Status = "pending"
-	return s.repo.Create(p)
+	p.Intent = models.IntentSale
+	if err := s.prepare(&p); err != nil {
+		return p, err
+	}
+	if held, err := s.holdIfRisky(&p); held || err != nil {
+		return p, err
+	}
+	redeemed, err := s.start(&p)
+	if err != nil {
+		return p, err
+	}
+	created, err := s.repo.Create(p)
+	if err != nil {
+		s.undoRedemption(redeemed, p.OrderID)
+	}
+	return created, err
 }
 
 func (s *paymentService) GetPaymentByID(id uint64) (models.Payment, error) {
 	return s.repo.GetByID(id)
 }
 
-func (s *paymentService) ListPayments() ([]models.Payment, error) {
-	return s.repo.List()
+func (s *paymentService) ListPayments(filter repository.PaymentFilter, cursor string) (PaymentPage, error) {
+	if cursor != "" {
+		after, err := decodeCursor(cursor)
+		if err != nil {
+			return PaymentPage{}, err
+		}
+		filter.After = &after
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultPageSize
+	}
+	if filter.Limit > maxPageSize {
+		filter.Limit = maxPageSize
+	}
+	pageSize := filter.Limit
+	filter.Limit++ // one extra row tells us whether there is a next page
+
+	payments, total, err := s.repo.List(filter)
+	if err != nil {
+		return PaymentPage{}, err
+	}
+	page := PaymentPage{Items: payments, Total: total}
+	if len(payments) > pageSize {
+		page.Items = payments[:pageSize]
+		last := page.Items[pageSize-1]
+		page.NextCursor = encodeCursor(repository.PageCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+	if page.Items == nil {
+		page.Items = []models.Payment{}
+	}
+	return page, nil
+}
+
+func encodeCursor(c repository.PageCursor) string {
+	raw := strconv.FormatInt(c.CreatedAt.UnixNano(), 10) + ":" + strconv.FormatUint(c.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
 }
 
-func (s *paymentService) UpdatePaymentStatus(id uint64, status string) (models.Payment, error) {
-	payment, err := s.repo.GetByID(id)
+func decodeCursor(s string) (repository.PageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
 	if err != nil {
-		return payment, err
+		return repository.PageCursor{}, ErrInvalidCursor
+	}
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return repository.PageCursor{}, ErrInvalidCursor
+	}
+	n, err1 := strconv.ParseInt(nanos, 10, 64)
+	i, err2 := strconv.ParseUint(id, 10, 64)
+	if err1 != nil || err2 != nil {
+		return repository.PageCursor{}, fmt.Errorf("%w: %s", ErrInvalidCursor, s)
 	}
-	payment.Status = status
-	return s.repo.Update(payment)
+	return repository.PageCursor{CreatedAt: time.Unix(0, n).UTC(), ID: i}, nil
+}
+
+func (s *paymentService) UpdatePaymentStatus(id uint64, status, providerReference string) (models.Payment, error) {
+	return s.repo.UpdateLocked(id, func(p *models.Payment) error {
+		allowed := false
+		for _, next := range processorTransitions[p.Status] {
+			allowed = allowed || next == status
+		}
+		if !allowed {
+			return fmt.Errorf("%w: cannot move a %s payment to %q", ErrInvalidTransition, p.Status, status)
+		}
+		p.Status = status
+		if providerReference != "" {
+			p.ProviderReference = providerReference
+		}
+		return nil
+	})
 }
 
 func (s *paymentService) RefundPayment(id uint64) (models.Payment, error) {
-	payment, err := s.repo.GetByID(id)
+	return s.refund(id, false)
+}
+
+func (s *paymentService) RefundToStoreCredit(id uint64) (models.Payment, error) {
+	return s.refund(id, true)
+}
+
+func (s *paymentService) refund(id uint64, toStoreCredit bool) (models.Payment, error) {
+	return s.repo.UpdateLocked(id, func(p *models.Payment) error {
+		if !p.Settled() {
+			return fmt.Errorf("%w: only successful payments can be refunded", ErrInvalidTransition)
+		}
+		if toStoreCredit || models.LedgerMethod(p.PaymentMethod) {
+			entry, err := s.ledger.CreditStoreCredit(p.UserID, p.SettledAmount(), fmt.Sprintf("payment:%d", p.ID))
+			if err != nil {
+				return err
+			}
+			p.RefundEntryID = &entry.ID
+		}
+		p.Status = models.StatusRefunded
+		return nil
+	})
+}
+
+// prepare validates a new payment and fills in the fields derived from it.
+func (s *paymentService) prepare(p *models.Payment) error {
+	if err := p.Amount.Validate(); err != nil {
+		return err
+	}
+	if !p.Amount.IsPositive() {
+		return ErrInvalidAmount
+	}
+	clearServerFields(p)
+	if p.PaymentMethod == models.MethodGiftCard {
+		if err := s.resolveGiftCard(p); err != nil {
+			return err
+		}
+	}
+	cardToken := ""
+	if p.SavedPaymentMethodID != nil {
+		method, err := s.methods.Resolve(p.UserID, *p.SavedPaymentMethodID)
+		if err != nil {
+			return err
+		}
+		p.PaymentMethod = method.Type
+		cardToken = method.GatewayToken
+	}
+	p.Captured = money.Money{Currency: p.Amount.Currency}
+	if err := s.snapshotBaseAmount(p); err != nil {
+		return err
+	}
+	return s.assessRisk(p, cardToken)
+}
+
+// clearServerFields resets what only the service may set on a new payment,
+// whatever the client sent. ClientIP is left alone: the handler fills it in
+// from the request.
+func clearServerFields(p *models.Payment) {
+	p.ID = 0
+	p.Status = ""
+	p.CreatedAt = time.Time{}
+	// Only the processor callback sets the reference; reconciliation
+	// trusts it to identify the payment.
+	p.ProviderReference = ""
+	p.AuthorizedAt, p.AuthExpiresAt, p.CapturedAt, p.VoidedAt, p.VoidReason = nil, nil, nil, nil, ""
+	p.RiskScore, p.RiskDecision, p.RiskSignals = 0, "", nil
+	p.ReviewedBy, p.ReviewedAt, p.ReviewNote = nil, nil, ""
+	p.CollectedAt, p.CollectedBy = nil, nil
+	p.LedgerEntryID, p.RefundEntryID = nil, nil
+	// Card IDs are sequential and cards have no owner: only the code
+	// proves the client holds the card.
+	p.GiftCardID = nil
+}
+
+// assessRisk runs the risk pipeline and records its outcome on p.
+func (s *paymentService) assessRisk(p *models.Payment, cardToken string) error {
+	p.BillingCountry = strings.ToUpper(p.BillingCountry)
+	p.ShippingCountry = strings.ToUpper(p.ShippingCountry)
+	result, err := s.risk.Evaluate(context.Background(), risk.Input{
+		UserID:          p.UserID,
+		IP:              p.ClientIP,
+		CardToken:       cardToken,
+		Amount:          p.Amount,
+		BaseAmount:      p.BaseAmount,
+		BillingCountry:  p.BillingCountry,
+		ShippingCountry: p.ShippingCountry,
+	})
 	if err != nil {
-		return payment, err
+		return err
+	}
+	p.RiskScore = result.Score
+	p.RiskDecision = result.Decision
+	p.RiskSignals = result.Signals
+	return nil
+}
+
+// holdIfRisky stores payments the risk pipeline did not allow: denied ones
+// as declined (returning ErrPaymentDeclined), reviewable ones on hold.
+func (s *paymentService) holdIfRisky(p *models.Payment) (bool, error) {
+	switch p.RiskDecision {
+	case risk.DecisionDeny:
+		p.Status = models.StatusDeclined
+	case risk.DecisionReview:
+		p.Status = models.StatusOnHold
+	default:
+		return false, nil
+	}
+	created, err := s.repo.Create(*p)
+	*p = created
+	if err == nil && p.Status == models.StatusDeclined {
+		err = ErrPaymentDeclined
+	}
+	return true, err
+}
+
+func (s *paymentService) ListHeldPayments() ([]models.Payment, error) {
+	return s.repo.ListByStatus(models.StatusOnHold, 200)
+}
+
+func (s *paymentService) ReviewPayment(id uint64, approve bool, reviewerID uint64, note string) (models.Payment, error) {
+	return s.repo.UpdateLocked(id, func(p *models.Payment) error {
+		if p.Status != models.StatusOnHold {
+			return fmt.Errorf("%w: payment is %s, not on hold", ErrInvalidTransition, p.Status)
+		}
+		now := time.Now()
+		p.ReviewedBy = &reviewerID
+		p.ReviewedAt = &now
+		p.ReviewNote = note
+		switch {
+		case !approve:
+			p.Status = models.StatusRejected
+		case p.Intent == models.IntentAuthorize:
+			// The hold starts when the authorization is actually granted.
+			expires := now.Add(s.authTTL)
+			p.Status = models.StatusAuthorized
+			p.AuthorizedAt = &now
+			p.AuthExpiresAt = &expires
+		default:
+			_, err := s.start(p)
+			return err
+		}
+		return nil
+	})
+}
+
+// snapshotBaseAmount records the charged amount in the base currency
+// together with the rate it was converted at.
+func (s *paymentService) snapshotBaseAmount(p *models.Payment) error {
+	base, rate, err := s.rates.ToBase(p.Amount)
+	if err != nil {
+		return err
+	}
+	p.BaseAmount = base
+	p.ExchangeRateID = nil
+	p.ExchangeRate = "1"
+	if rate != nil {
+		p.ExchangeRateID = &rate.ID
+		p.ExchangeRate = rate.Rate
+	}
+	return nil
+}
+
+// resolveGiftCard checks the gift card a payment is to be taken from and
+// swaps its code for the card ID.
+func (s *paymentService) resolveGiftCard(p *models.Payment) error {
+	if p.GiftCardCode == "" {
+		return ErrGiftCardNotFound
+	}
+	card, err := s.ledger.GiftCardByCode(p.GiftCardCode)
+	if err != nil {
+		return err
+	}
+	if card.Expired(time.Now()) {
+		return ErrGiftCardExpired
+	}
+	if card.Balance.Currency != p.Amount.Currency {
+		return fmt.Errorf("%w: gift card is in %s", money.ErrCurrencyMismatch, card.Balance.Currency)
+	}
+	p.GiftCardID = &card.ID
+	p.GiftCardCode = ""
+	return nil
+}
+
+// start moves a payment the risk checks allowed into its first status.
+// Gift card and store credit payments are redeemed on the spot, so they
+// are settled straight away; the redemption's entry ID is returned so the
+// caller can undo it if the payment is not saved.
+func (s *paymentService) start(p *models.Payment) (uint64, error) {
+	p.Status = initialStatus(*p)
+	if !models.LedgerMethod(p.PaymentMethod) {
+		return 0, nil
+	}
+	var giftCardID *uint64
+	if p.PaymentMethod == models.MethodGiftCard {
+		giftCardID = p.GiftCardID
+	}
+	entry, err := s.ledger.Redeem(p.UserID, giftCardID, p.Amount, fmt.Sprintf("order:%d", p.OrderID))
+	if err != nil {
+		return 0, err
+	}
+	p.LedgerEntryID = &entry.ID
+	p.Status = models.StatusSuccess
+	return entry.ID, nil
+}
+
+// undoRedemption gives back a redemption, as returned by start, whose
+// payment could not be saved. It never trusts an entry ID from the payment
+// itself.
+func (s *paymentService) undoRedemption(entryID, orderID uint64) {
+	if entryID == 0 {
+		return
 	}
-	if payment.Status != "success" {
-		return payment, errors.New("only successful payments can be refunded")
+	if _, err := s.ledger.Reverse(entryID, "payment not recorded"); err != nil {
+		log.Printf("failed to reverse ledger entry %d for order %d: %v", entryID, orderID, err)
 	}
-	payment.Status = "refunded"
-	return s.repo.Update(payment)
 }
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"errors"
+	"payment-service/models"
+	"payment-service/money"
+	"payment-service/repository"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidCommission = errors.New("commission must be between 0 and 10000 basis points")
+	ErrMissingReference  = errors.New("a transfer reference is required")
+)
+
+// SellerEarnings is what a seller has not been paid out yet in one
+// currency: Payable is from fully paid orders, Pending from orders still
+// waiting for (some of) their money.
+type SellerEarnings struct {
+	Currency string      `json:"currency"`
+	Payable  money.Money `json:"payable"`
+	Pending  money.Money `json:"pending"`
+}
+
+type PayoutService interface {
+	Earnings(sellerID uint64) ([]SellerEarnings, error)
+	// RunPayouts creates one payout per seller and currency for every
+	// share whose order is fully paid, less the platform's commission.
+	// Shares of orders that are refunded before then are never paid out;
+	// refunds after a payout are not clawed back from the seller.
+	RunPayouts() ([]models.SellerPayout, error)
+	ListPayouts(sellerID uint64) ([]models.SellerPayout, error)
+	MarkPayoutPaid(id uint64, reference string) (models.SellerPayout, error)
+}
+
+type payoutService struct {
+	repo          repository.PayoutRepository
+	payments      PaymentService
+	commissionBPS int
+}
+
+// NewPayoutService keeps commissionBPS basis points of every seller's
+// takings as the platform's commission.
+func NewPayoutService(r repository.PayoutRepository, payments PaymentService, commissionBPS int) (PayoutService, error) {
+	if commissionBPS < 0 || commissionBPS > 10000 {
+		return nil, ErrInvalidCommission
+	}
+	return &payoutService{repo: r, payments: payments, commissionBPS: commissionBPS}, nil
+}
+
+func (s *payoutService) Earnings(sellerID uint64) ([]SellerEarnings, error) {
+	shares, err := s.repo.UnpaidShares(sellerID)
+	if err != nil {
+		return nil, err
+	}
+	paid, err := s.paidOrders(shares)
+	if err != nil {
+		return nil, err
+	}
+	byCurrency := map[string]*SellerEarnings{}
+	for _, share := range shares {
+		e := byCurrency[share.Amount.Currency]
+		if e == nil {
+			e = &SellerEarnings{
+				Currency: share.Amount.Currency,
+				Payable:  money.Money{Currency: share.Amount.Currency},
+				Pending:  money.Money{Currency: share.Amount.Currency},
+			}
+			byCurrency[share.Amount.Currency] = e
+		}
+		if paid[share.OrderID] {
+			e.Payable.Minor += share.Amount.Minor
+		} else {
+			e.Pending.Minor += share.Amount.Minor
+		}
+	}
+	earnings := make([]SellerEarnings, 0, len(byCurrency))
+	for _, e := range byCurrency {
+		earnings = append(earnings, *e)
+	}
+	sort.Slice(earnings, func(i, j int) bool { return earnings[i].Currency < earnings[j].Currency })
+	return earnings, nil
+}
+
+func (s *payoutService) RunPayouts() ([]models.SellerPayout, error) {
+	shares, err := s.repo.UnpaidShares(0)
+	if err != nil {
+		return nil, err
+	}
+	paid, err := s.paidOrders(shares)
+	if err != nil {
+		return nil, err
+	}
+
+	type key struct {
+		sellerID uint64
+		currency string
+	}
+	batches := map[key][]models.SellerShare{}
+	var keys []key
+	for _, share := range shares {
+		if !paid[share.OrderID] {
+			continue
+		}
+		k := key{share.SellerID, share.Amount.Currency}
+		if batches[k] == nil {
+			keys = append(keys, k)
+		}
+		batches[k] = append(batches[k], share)
+	}
+
+	payouts := []models.SellerPayout{}
+	for _, k := range keys {
+		gross := money.Money{Currency: k.currency}
+		ids := make([]uint64, 0, len(batches[k]))
+		for _, share := range batches[k] {
+			if gross, err = gross.Add(share.Amount); err != nil {
+				return payouts, err
+			}
+			ids = append(ids, share.ID)
+		}
+		// Allocate keeps commission and net adding up to gross exactly.
+		parts, err := gross.Allocate(int64(s.commissionBPS), int64(10000-s.commissionBPS))
+		if err != nil {
+			return payouts, err
+		}
+		payout, err := s.repo.CreatePayout(models.SellerPayout{
+			SellerID:      k.sellerID,
+			Gross:         gross,
+			Commission:    parts[0],
+			Net:           parts[1],
+			CommissionBPS: s.commissionBPS,
+			Status:        models.PayoutPending,
+		}, ids)
+		if errors.Is(err, repository.ErrShareTaken) {
+			// A concurrent run got there first; it pays these out.
+			continue
+		}
+		if err != nil {
+			return payouts, err
+		}
+		payouts = append(payouts, payout)
+	}
+	return payouts, nil
+}
+
+func (s *payoutService) ListPayouts(sellerID uint64) ([]models.SellerPayout, error) {
+	return s.repo.ListPayouts(sellerID)
+}
+
+func (s *payoutService) MarkPayoutPaid(id uint64, reference string) (models.SellerPayout, error) {
+	reference = strings.TrimSpace(reference)
+	if reference == "" {
+		return models.SellerPayout{}, ErrMissingReference
+	}
+	return s.repo.MarkPayoutPaid(id, reference, time.Now())
+}
+
+// paidOrders reports, per order of the shares, whether it is fully paid.
+func (s *payoutService) paidOrders(shares []models.SellerShare) (map[uint64]bool, error) {
+	paid := map[uint64]bool{}
+	for _, share := range shares {
+		if _, seen := paid[share.OrderID]; seen {
+			continue
+		}
+		summary, err := s.payments.GetOrderPaymentSummary(share.OrderID)
+		if err != nil {
+			return nil, err
+		}
+		paid[share.OrderID] = summary.FullyPaid
+	}
+	return paid, nil
+}
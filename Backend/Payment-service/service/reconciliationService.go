@@ -0,0 +1,255 @@
+package service
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"payment-service/models"
+	"payment-service/money"
+	"payment-service/repository"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSettlementFile = errors.New("invalid settlement file")
+
+// SettlementRow is one transaction from a processor settlement report.
+type SettlementRow struct {
+	Line              int
+	ProviderReference string
+	Amount            money.Money
+}
+
+type ReconciliationService interface {
+	// Import reconciles a settlement CSV against the payments created on
+	// transactionDate (UTC) and stores the resulting report.
+	Import(fileName string, r io.Reader, transactionDate time.Time) (models.ReconciliationRun, error)
+	GetRun(id uint64) (models.ReconciliationRun, error)
+	ListRuns() ([]models.ReconciliationRun, error)
+}
+
+type reconciliationService struct {
+	repo     repository.ReconciliationRepository
+	payments repository.PaymentRepository
+}
+
+func NewReconciliationService(r repository.ReconciliationRepository, payments repository.PaymentRepository) ReconciliationService {
+	return &reconciliationService{repo: r, payments: payments}
+}
+
+func (s *reconciliationService) Import(fileName string, r io.Reader, transactionDate time.Time) (models.ReconciliationRun, error) {
+	rows, err := ParseSettlementCSV(r)
+	if err != nil {
+		return models.ReconciliationRun{}, err
+	}
+
+	from := time.Date(transactionDate.Year(), transactionDate.Month(), transactionDate.Day(), 0, 0, 0, 0, time.UTC)
+	expected, err := s.payments.ListSettledBetween(from, from.AddDate(0, 0, 1))
+	if err != nil {
+		return models.ReconciliationRun{}, err
+	}
+	refs := make([]string, 0, len(rows))
+	for _, row := range rows {
+		refs = append(refs, row.ProviderReference)
+	}
+	// Rows may settle payments made on other days, so look them up by
+	// reference as well as by date.
+	referenced, err := s.payments.FindByProviderReferences(refs)
+	if err != nil {
+		return models.ReconciliationRun{}, err
+	}
+
+	run := models.ReconciliationRun{
+		FileName:        fileName,
+		TransactionDate: from,
+		Rows:            len(rows),
+		Items:           Reconcile(append(expected, referenced...), rows),
+	}
+	for _, item := range run.Items {
+		switch item.Result {
+		case models.ReconMatched:
+			run.Matched++
+		case models.ReconMissingInternal:
+			run.MissingInternal++
+		case models.ReconMissingProvider:
+			run.MissingProvider++
+		case models.ReconAmountMismatch:
+			run.AmountMismatch++
+		}
+	}
+	return s.repo.CreateRun(run)
+}
+
+func (s *reconciliationService) GetRun(id uint64) (models.ReconciliationRun, error) {
+	return s.repo.GetRun(id)
+}
+
+func (s *reconciliationService) ListRuns() ([]models.ReconciliationRun, error) {
+	return s.repo.ListRuns(100)
+}
+
+// Reconcile matches settlement rows to payments by provider reference and
+// compares amounts. Payments with no row are reported as missing on the
+// provider side; rows with no payment as missing on ours.
+func Reconcile(payments []models.Payment, rows []SettlementRow) []models.ReconciliationItem {
+	byRef := make(map[string]models.Payment)
+	for _, p := range payments {
+		if p.ProviderReference != "" {
+			byRef[p.ProviderReference] = p
+		}
+	}
+
+	var items []models.ReconciliationItem
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		providerMinor := row.Amount.Minor
+		item := models.ReconciliationItem{
+			ProviderReference:   row.ProviderReference,
+			Currency:            row.Amount.Currency,
+			ProviderAmountMinor: &providerMinor,
+		}
+		p, ok := byRef[row.ProviderReference]
+		switch {
+		case seen[row.ProviderReference]:
+			item.Result = models.ReconAmountMismatch
+			item.Note = fmt.Sprintf("duplicate settlement row on line %d", row.Line)
+		case !ok:
+			item.Result = models.ReconMissingInternal
+		default:
+			id := p.ID
+			ours := p.SettledAmount()
+			item.PaymentID = &id
+			item.OurAmountMinor = &ours.Minor
+			switch {
+			case ours.Currency != row.Amount.Currency:
+				item.Result = models.ReconAmountMismatch
+				item.Note = fmt.Sprintf("currency %s, expected %s", row.Amount.Currency, ours.Currency)
+			case ours.Minor != row.Amount.Minor:
+				item.Result = models.ReconAmountMismatch
+				item.Note = fmt.Sprintf("difference %s", money.Money{Minor: row.Amount.Minor - ours.Minor, Currency: ours.Currency})
+			default:
+				item.Result = models.ReconMatched
+			}
+		}
+		seen[row.ProviderReference] = true
+		items = append(items, item)
+	}
+
+	var missing []models.ReconciliationItem
+	reported := make(map[uint64]bool)
+	for _, p := range payments {
+		if seen[p.ProviderReference] || reported[p.ID] || !expectsSettlement(p) {
+			continue
+		}
+		reported[p.ID] = true
+		id := p.ID
+		ours := p.SettledAmount()
+		item := models.ReconciliationItem{
+			Result:            models.ReconMissingProvider,
+			ProviderReference: p.ProviderReference,
+			PaymentID:         &id,
+			Currency:          ours.Currency,
+			OurAmountMinor:    &ours.Minor,
+		}
+		if p.ProviderReference == "" {
+			item.Note = "payment has no provider reference"
+		}
+		missing = append(missing, item)
+	}
+	sort.Slice(missing, func(i, j int) bool { return *missing[i].PaymentID < *missing[j].PaymentID })
+	return append(items, missing...)
+}
+
+func expectsSettlement(p models.Payment) bool {
+	switch p.Status {
+	case models.StatusSuccess, models.StatusCaptured, models.StatusPartiallyCaptured:
+		return true
+	}
+	return false
+}
+
+// ParseSettlementCSV reads a settlement report with a header row naming at
+// least a reference column (provider_reference, reference or
+// transaction_id), an amount column and a currency column. Amounts are
+// decimal strings in the currency's major unit.
+func ParseSettlementCSV(r io.Reader) ([]SettlementRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSettlementFile, err)
+	}
+	col := map[string]int{}
+	for i, name := range header {
+		col[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	refCol, ok := firstColumn(col, "provider_reference", "reference", "transaction_id")
+	amountCol, hasAmount := col["amount"]
+	currencyCol, hasCurrency := col["currency"]
+	if !ok || !hasAmount || !hasCurrency {
+		return nil, fmt.Errorf("%w: header must include provider_reference, amount and currency", ErrInvalidSettlementFile)
+	}
+
+	var rows []SettlementRow
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidSettlementFile, line, err)
+		}
+		ref := strings.TrimSpace(record[refCol])
+		if ref == "" {
+			return nil, fmt.Errorf("%w: line %d: empty reference", ErrInvalidSettlementFile, line)
+		}
+		amount, err := money.Parse(record[amountCol], record[currencyCol])
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidSettlementFile, line, err)
+		}
+		rows = append(rows, SettlementRow{Line: line, ProviderReference: ref, Amount: amount})
+	}
+	return rows, nil
+}
+
+func firstColumn(col map[string]int, names ...string) (int, bool) {
+	for _, name := range names {
+		if i, ok := col[name]; ok {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// WriteReportCSV writes a run's items in a spreadsheet-friendly form.
+func WriteReportCSV(w io.Writer, run models.ReconciliationRun) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"result", "provider_reference", "payment_id", "currency", "our_amount", "provider_amount", "note"})
+	for _, item := range run.Items {
+		paymentID := ""
+		if item.PaymentID != nil {
+			paymentID = strconv.FormatUint(*item.PaymentID, 10)
+		}
+		out.Write([]string{
+			item.Result,
+			item.ProviderReference,
+			paymentID,
+			item.Currency,
+			formatMinor(item.OurAmountMinor, item.Currency),
+			formatMinor(item.ProviderAmountMinor, item.Currency),
+			item.Note,
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func formatMinor(minor *int64, currency string) string {
+	if minor == nil {
+		return ""
+	}
+	return money.Money{Minor: *minor, Currency: currency}.Decimal()
+}
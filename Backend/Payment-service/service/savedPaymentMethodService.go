@@ -0,0 +1,160 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"payment-service/models"
+	"payment-service/repository"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidPaymentMethod  = errors.New("invalid payment method")
+	ErrRawCardData           = errors.New("raw card numbers must not be sent; tokenize the card with the gateway first")
+	ErrPaymentMethodNotFound = errors.New("payment method not found")
+)
+
+var (
+	last4Pattern = regexp.MustCompile(`^[0-9]{4}$`)
+	upiPattern   = regexp.MustCompile(`^[a-zA-Z0-9.\-_]{2,256}@[a-zA-Z]{2,64}$`)
+	panCandidate = regexp.MustCompile(`[0-9][0-9 \-]{11,22}[0-9]`)
+	// Gateway tokens are opaque; they may well be all digits, so only
+	// their shape is checked.
+	tokenPattern = regexp.MustCompile(`^[A-Za-z0-9_.:\-]{1,255}$`)
+)
+
+type SavedPaymentMethodService interface {
+	Save(models.SavedPaymentMethod) (models.SavedPaymentMethod, error)
+	List(userID uint64) ([]models.SavedPaymentMethod, error)
+	SetDefault(userID, id uint64) error
+	Delete(userID, id uint64) error
+	// Resolve returns a method that belongs to userID and can still be charged.
+	Resolve(userID, id uint64) (models.SavedPaymentMethod, error)
+}
+
+type savedPaymentMethodService struct {
+	repo repository.SavedPaymentMethodRepository
+}
+
+func NewSavedPaymentMethodService(r repository.SavedPaymentMethodRepository) SavedPaymentMethodService {
+	return &savedPaymentMethodService{repo: r}
+}
+
+func (s *savedPaymentMethodService) Save(m models.SavedPaymentMethod) (models.SavedPaymentMethod, error) {
+	m.ID = 0
+	m.Type = strings.ToLower(strings.TrimSpace(m.Type))
+	if err := validatePaymentMethod(&m, time.Now()); err != nil {
+		return m, err
+	}
+	return s.repo.Create(m)
+}
+
+func (s *savedPaymentMethodService) List(userID uint64) ([]models.SavedPaymentMethod, error) {
+	return s.repo.ListByUser(userID)
+}
+
+func (s *savedPaymentMethodService) SetDefault(userID, id uint64) error {
+	return notFoundAs(s.repo.SetDefault(userID, id), ErrPaymentMethodNotFound)
+}
+
+func (s *savedPaymentMethodService) Delete(userID, id uint64) error {
+	return notFoundAs(s.repo.Delete(userID, id), ErrPaymentMethodNotFound)
+}
+
+func (s *savedPaymentMethodService) Resolve(userID, id uint64) (models.SavedPaymentMethod, error) {
+	m, err := s.repo.GetByID(id)
+	if err != nil {
+		return m, notFoundAs(err, ErrPaymentMethodNotFound)
+	}
+	if m.UserID != userID {
+		return models.SavedPaymentMethod{}, ErrPaymentMethodNotFound
+	}
+	if m.Expired(time.Now()) {
+		return m, fmt.Errorf("%w: card ending %s has expired", ErrInvalidPaymentMethod, m.Last4)
+	}
+	return m, nil
+}
+
+func validatePaymentMethod(m *models.SavedPaymentMethod, now time.Time) error {
+	if m.UserID == 0 {
+		return fmt.Errorf("%w: user_id is required", ErrInvalidPaymentMethod)
+	}
+	for _, field := range []string{m.Label, m.Last4, m.Brand, m.UPIID, m.WalletProvider} {
+		if containsPAN(field) {
+			return ErrRawCardData
+		}
+	}
+	if m.GatewayToken != "" && !tokenPattern.MatchString(m.GatewayToken) {
+		return fmt.Errorf("%w: malformed gateway_token", ErrInvalidPaymentMethod)
+	}
+
+	switch m.Type {
+	case models.MethodTypeCard:
+		if m.GatewayToken == "" {
+			return fmt.Errorf("%w: gateway_token is required for cards", ErrInvalidPaymentMethod)
+		}
+		if !last4Pattern.MatchString(m.Last4) {
+			return fmt.Errorf("%w: last4 must be four digits", ErrInvalidPaymentMethod)
+		}
+		if m.ExpMonth < 1 || m.ExpMonth > 12 || m.ExpYear < 2000 {
+			return fmt.Errorf("%w: invalid card expiry", ErrInvalidPaymentMethod)
+		}
+		if m.Expired(now) {
+			return fmt.Errorf("%w: card has expired", ErrInvalidPaymentMethod)
+		}
+		m.UPIID, m.WalletProvider = "", ""
+	case models.MethodTypeUPI:
+		if !upiPattern.MatchString(m.UPIID) {
+			return fmt.Errorf("%w: invalid UPI ID", ErrInvalidPaymentMethod)
+		}
+		m.Brand, m.Last4, m.ExpMonth, m.ExpYear, m.WalletProvider = "", "", 0, 0, ""
+	case models.MethodTypeWallet:
+		if m.WalletProvider == "" || m.GatewayToken == "" {
+			return fmt.Errorf("%w: wallet_provider and gateway_token are required for wallets", ErrInvalidPaymentMethod)
+		}
+		m.Brand, m.Last4, m.ExpMonth, m.ExpYear, m.UPIID = "", "", 0, 0, ""
+	default:
+		return fmt.Errorf("%w: type must be card, upi or wallet", ErrInvalidPaymentMethod)
+	}
+	return nil
+}
+
+// containsPAN reports whether s contains a 13-19 digit sequence that
+// passes the Luhn check, i.e. something that looks like a card number.
+func containsPAN(s string) bool {
+	for _, candidate := range panCandidate.FindAllString(s, -1) {
+		digits := strings.NewReplacer(" ", "", "-", "").Replace(candidate)
+		if len(digits) >= 13 && len(digits) <= 19 && luhnValid(digits) {
+			return true
+		}
+	}
+	return false
+}
+
+func luhnValid(digits string) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+func notFoundAs(err, notFound error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return notFound
+	}
+	return err
+}
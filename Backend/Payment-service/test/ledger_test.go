@@ -0,0 +1,347 @@
+package test
+
+import (
+	"errors"
+	"payment-service/models"
+	"payment-service/repository"
+	"payment-service/service"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// memoryLedgerRepo serializes postings with a mutex, standing in for the
+// row locks the real repository takes.
+type memoryLedgerRepo struct {
+	mu       sync.Mutex
+	accounts []models.LedgerAccount
+	entries  []models.JournalEntry
+	cards    []models.GiftCard
+}
+
+func (r *memoryLedgerRepo) Account(accountType string, ownerID uint64, currency string) (models.LedgerAccount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.account(accountType, ownerID, currency), nil
+}
+
+func (r *memoryLedgerRepo) account(accountType string, ownerID uint64, currency string) models.LedgerAccount {
+	for _, a := range r.accounts {
+		if a.Type == accountType && a.OwnerID == ownerID && a.Currency == currency {
+			return a
+		}
+	}
+	a := models.LedgerAccount{ID: uint64(len(r.accounts) + 1), Type: accountType, OwnerID: ownerID, Currency: currency}
+	r.accounts = append(r.accounts, a)
+	return a
+}
+
+func (r *memoryLedgerRepo) ListAccounts(accountType string, ownerID uint64) ([]models.LedgerAccount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []models.LedgerAccount
+	for _, a := range r.accounts {
+		if a.Type == accountType && a.OwnerID == ownerID {
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+func (r *memoryLedgerRepo) Balance(accountID uint64) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.balance(accountID), nil
+}
+
+func (r *memoryLedgerRepo) balance(accountID uint64) int64 {
+	var sum int64
+	for _, e := range r.entries {
+		for _, l := range e.Lines {
+			if l.AccountID == accountID {
+				sum += l.Amount
+			}
+		}
+	}
+	return sum
+}
+
+func (r *memoryLedgerRepo) PostLocked(ids []uint64, fn func(map[uint64]repository.AccountBalance) (models.JournalEntry, error)) (models.JournalEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	locked := map[uint64]repository.AccountBalance{}
+	for _, id := range ids {
+		locked[id] = repository.AccountBalance{LedgerAccount: r.accounts[id-1], Balance: r.balance(id)}
+	}
+	entry, err := fn(locked)
+	if err != nil {
+		return entry, err
+	}
+	for _, e := range r.entries {
+		if entry.ReversesID != nil && e.ReversesID != nil && *e.ReversesID == *entry.ReversesID {
+			return entry, repository.ErrAlreadyReversed
+		}
+	}
+	entry.ID = uint64(len(r.entries) + 1)
+	r.entries = append(r.entries, entry)
+	return entry, nil
+}
+
+func (r *memoryLedgerRepo) GetEntry(id uint64) (models.JournalEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id == 0 || id > uint64(len(r.entries)) {
+		return models.JournalEntry{}, gorm.ErrRecordNotFound
+	}
+	return r.entries[id-1], nil
+}
+
+func (r *memoryLedgerRepo) ListEntries(accountID uint64, limit int) ([]models.JournalEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []models.JournalEntry
+	for i := len(r.entries) - 1; i >= 0 && len(out) < limit; i-- {
+		for _, l := range r.entries[i].Lines {
+			if l.AccountID == accountID {
+				out = append(out, r.entries[i])
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+func (r *memoryLedgerRepo) CreateGiftCard(card models.GiftCard) (models.GiftCard, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	card.ID = uint64(len(r.cards) + 1)
+	card.AccountID = r.account(models.AccountGiftCard, card.ID, card.InitialAmount.Currency).ID
+	r.cards = append(r.cards, card)
+	return card, nil
+}
+
+func (r *memoryLedgerRepo) GetGiftCard(id uint64) (models.GiftCard, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id == 0 || id > uint64(len(r.cards)) {
+		return models.GiftCard{}, gorm.ErrRecordNotFound
+	}
+	return r.cards[id-1], nil
+}
+
+func (r *memoryLedgerRepo) GetGiftCardByCodeHash(hash string) (models.GiftCard, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.cards {
+		if c.CodeHash == hash {
+			return c, nil
+		}
+	}
+	return models.GiftCard{}, gorm.ErrRecordNotFound
+}
+
+func (r *memoryLedgerRepo) ListExpiredGiftCards(now time.Time, limit int) ([]models.GiftCard, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []models.GiftCard
+	for _, c := range r.cards {
+		if c.Expired(now) && c.ExpiredAt == nil {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+func (r *memoryLedgerRepo) MarkGiftCardExpired(id uint64, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cards[id-1].ExpiredAt = &at
+	return nil
+}
+
+// assertLedgerBalanced checks the double-entry invariant over the whole
+// journal: every entry sums to zero.
+func assertLedgerBalanced(t *testing.T, repo *memoryLedgerRepo) {
+	t.Helper()
+	for _, e := range repo.entries {
+		if !e.Balanced() {
+			t.Errorf("entry %d (%s) does not balance: %+v", e.ID, e.Kind, e.Lines)
+		}
+	}
+}
+
+func TestGiftCardIssueRedeemAndExpire(t *testing.T) {
+	repo := &memoryLedgerRepo{}
+	ledger := service.NewLedgerService(repo)
+
+	expires := time.Now().Add(time.Hour)
+	card, code, err := ledger.IssueGiftCard(rupees(50000), &expires, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(code) != 19 || card.Balance.Minor != 50000 || card.Last4 != code[len(code)-4:] {
+		t.Fatalf("issued %+v with code %q", card, code)
+	}
+
+	if _, err := ledger.Redeem(2, &card.ID, rupees(20000), "order:1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ledger.Redeem(2, &card.ID, rupees(30001), "order:2"); !errors.Is(err, service.ErrInsufficientBalance) {
+		t.Errorf("expected overdraw to fail, got %v", err)
+	}
+	found, err := ledger.GiftCardByCode(" " + code[:4] + code[5:])
+	if err != nil || found.Balance.Minor != 30000 {
+		t.Fatalf("lookup by code = %+v, %v", found, err)
+	}
+
+	if n, err := ledger.ExpireGiftCards(expires); err != nil || n != 1 {
+		t.Fatalf("expire = %d, %v", n, err)
+	}
+	if found, _ = ledger.GiftCard(card.ID); found.Balance.Minor != 0 {
+		t.Errorf("expired card still holds %s", found.Balance)
+	}
+	if n, _ := ledger.ExpireGiftCards(expires); n != 0 {
+		t.Errorf("card expired twice")
+	}
+	history, _ := ledger.GiftCardHistory(card.ID, 10)
+	if len(history) != 3 || history[0].Kind != models.EntryExpire || history[2].Kind != models.EntryIssue {
+		t.Errorf("unexpected history %+v", history)
+	}
+	assertLedgerBalanced(t, repo)
+}
+
+func TestConcurrentRedemptionsNeverOverdraw(t *testing.T) {
+	repo := &memoryLedgerRepo{}
+	ledger := service.NewLedgerService(repo)
+	card, _, _ := ledger.IssueGiftCard(rupees(10000), nil, 1)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := ledger.Redeem(2, &card.ID, rupees(1500), "order:1"); err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	card, _ = ledger.GiftCard(card.ID)
+	if succeeded != 6 || card.Balance.Minor != 1000 {
+		t.Errorf("%d redemptions succeeded, balance %s", succeeded, card.Balance)
+	}
+}
+
+func TestGiftCardPaymentRefundsToStoreCredit(t *testing.T) {
+	ledgerRepo := &memoryLedgerRepo{}
+	svc, ledger := newTestPaymentServiceWithLedger(newMemoryPaymentRepo(), ledgerRepo)
+	card, code, _ := ledger.IssueGiftCard(rupees(30000), nil, 1)
+
+	p, err := svc.CreatePayment(models.Payment{OrderID: 3, UserID: 2, PaymentMethod: models.MethodGiftCard, GiftCardCode: code, Amount: rupees(25000)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Status != models.StatusSuccess || p.GiftCardID == nil || *p.GiftCardID != card.ID || p.GiftCardCode != "" || p.LedgerEntryID == nil {
+		t.Fatalf("unexpected gift card payment %+v", p)
+	}
+	if _, err := svc.CreatePayment(models.Payment{OrderID: 4, UserID: 2, PaymentMethod: models.MethodGiftCard, GiftCardCode: code, Amount: rupees(5001)}); !errors.Is(err, service.ErrInsufficientBalance) {
+		t.Errorf("expected second payment to overdraw, got %v", err)
+	}
+
+	if p, err = svc.RefundPayment(p.ID); err != nil || p.Status != models.StatusRefunded || p.RefundEntryID == nil {
+		t.Fatalf("refund = %+v, %v", p, err)
+	}
+	credit, _ := ledger.StoreCredit(2)
+	if len(credit) != 1 || credit[0].Minor != 25000 {
+		t.Fatalf("store credit = %v", credit)
+	}
+
+	// The credit can pay for part of a split order.
+	summary, err := svc.PayOrder(models.OrderPayment{OrderID: 9, UserID: 2, Total: rupees(40000)}, []models.Payment{
+		{PaymentMethod: models.MethodStoreCredit, Amount: rupees(25000)},
+		{PaymentMethod: models.MethodCOD, Amount: rupees(15000)},
+	})
+	if err != nil || summary.Paid.Minor != 25000 || summary.FullyPaid {
+		t.Fatalf("split order = %+v, %v", summary, err)
+	}
+	if credit, _ = ledger.StoreCredit(2); credit[0].Minor != 0 {
+		t.Errorf("store credit left %s", credit[0])
+	}
+	assertLedgerBalanced(t, ledgerRepo)
+}
+
+func TestFailedSplitTenderGivesRedemptionsBack(t *testing.T) {
+	ledgerRepo := &memoryLedgerRepo{}
+	svc, ledger := newTestPaymentServiceWithLedger(newMemoryPaymentRepo(), ledgerRepo)
+	_, code, _ := ledger.IssueGiftCard(rupees(10000), nil, 1)
+
+	_, err := svc.PayOrder(models.OrderPayment{OrderID: 9, UserID: 2, Total: rupees(20000)}, []models.Payment{
+		{PaymentMethod: models.MethodGiftCard, GiftCardCode: code, Amount: rupees(10000)},
+		{PaymentMethod: models.MethodStoreCredit, Amount: rupees(10000)},
+	})
+	if !errors.Is(err, service.ErrInsufficientBalance) {
+		t.Fatalf("expected empty store credit to fail, got %v", err)
+	}
+	if card, _ := ledger.GiftCardByCode(code); card.Balance.Minor != 10000 {
+		t.Errorf("gift card balance %s, want it restored", card.Balance)
+	}
+	assertLedgerBalanced(t, ledgerRepo)
+}
+
+func TestFailedTenderNeverReversesForeignEntries(t *testing.T) {
+	ledgerRepo := &memoryLedgerRepo{}
+	svc, ledger := newTestPaymentServiceWithLedger(newMemoryPaymentRepo(), ledgerRepo)
+	_, code, _ := ledger.IssueGiftCard(rupees(30000), nil, 1)
+	paid, err := svc.CreatePayment(models.Payment{OrderID: 3, UserID: 2, PaymentMethod: models.MethodGiftCard, GiftCardCode: code, Amount: rupees(25000)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The client names the earlier redemption and makes the order fail.
+	_, err = svc.PayOrder(models.OrderPayment{OrderID: 9, UserID: 2, Total: rupees(20000)}, []models.Payment{
+		{PaymentMethod: models.MethodCOD, Amount: rupees(10000), LedgerEntryID: paid.LedgerEntryID},
+		{PaymentMethod: models.MethodStoreCredit, Amount: rupees(10000)},
+	})
+	if !errors.Is(err, service.ErrInsufficientBalance) {
+		t.Fatalf("expected empty store credit to fail, got %v", err)
+	}
+	if card, _ := ledger.GiftCardByCode(code); card.Balance.Minor != 5000 {
+		t.Errorf("gift card balance %s, want the earlier redemption kept", card.Balance)
+	}
+
+	if _, err := ledger.Reverse(*paid.LedgerEntryID, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ledger.Reverse(*paid.LedgerEntryID, "test"); !errors.Is(err, repository.ErrAlreadyReversed) {
+		t.Errorf("expected a second reversal to fail, got %v", err)
+	}
+	assertLedgerBalanced(t, ledgerRepo)
+}
+
+func TestGiftCardCannotBeRedeemedByID(t *testing.T) {
+	ledgerRepo := &memoryLedgerRepo{}
+	svc, ledger := newTestPaymentServiceWithLedger(newMemoryPaymentRepo(), ledgerRepo)
+	card, code, _ := ledger.IssueGiftCard(rupees(30000), nil, 1)
+
+	// Another customer guesses the card's ID but does not have the code.
+	_, err := svc.CreatePayment(models.Payment{OrderID: 3, UserID: 8, PaymentMethod: models.MethodGiftCard, GiftCardID: &card.ID, Amount: rupees(10000)})
+	if !errors.Is(err, service.ErrGiftCardNotFound) {
+		t.Errorf("expected a card ID without its code to fail, got %v", err)
+	}
+	_, err = svc.PayOrder(models.OrderPayment{OrderID: 4, UserID: 8, Total: rupees(10000)}, []models.Payment{
+		{PaymentMethod: models.MethodGiftCard, GiftCardID: &card.ID, Amount: rupees(10000)},
+	})
+	if !errors.Is(err, service.ErrGiftCardNotFound) {
+		t.Errorf("expected a tender naming only the card ID to fail, got %v", err)
+	}
+	if balance, _ := ledger.GiftCardByCode(code); balance.Balance.Minor != 30000 {
+		t.Errorf("gift card balance %s, want it untouched", balance.Balance)
+	}
+}
@@ -0,0 +1,54 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"payment-service/middleware"
+	"testing"
+	"time"
+
+	"github.com/gajare/BAJAR-App/Backend/pkg/authz"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func signedToken(t *testing.T, secret, subject, role string) string {
+	t.Helper()
+	tok := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":  subject,
+		"role": role,
+		"exp":  time.Now().Add(time.Hour).Unix(),
+	})
+	s, err := tok.SignedString([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestJWTAuth(t *testing.T) {
+	var got middleware.Identity
+	handler := middleware.JWTAuth(authz.NewHMACVerifier("secret"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = middleware.IdentityFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest("GET", "/payments", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("missing token: status %d", w.Code)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+signedToken(t, "other", "7", ""))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong secret: status %d", w.Code)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+signedToken(t, "secret", "7", "admin"))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || got.UserID != 7 || !got.IsAdmin() {
+		t.Errorf("valid token: status %d, identity %+v", w.Code, got)
+	}
+}
@@ -0,0 +1,119 @@
+package test
+
+import (
+	"encoding/json"
+	"errors"
+	"payment-service/money"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	cases := []struct {
+		in       string
+		currency string
+		minor    int64
+		err      error
+	}{
+		{"1499.50", "INR", 149950, nil},
+		{"0.1", "inr", 10, nil},
+		{"10", "JPY", 10, nil},
+		{"1.234", "KWD", 1234, nil},
+		{"2.500", "USD", 250, nil},
+		{"-3.05", "EUR", -305, nil},
+		{"1.005", "INR", 0, money.ErrTooPrecise},
+		{"abc", "INR", 0, money.ErrInvalidAmount},
+		{"1.", "INR", 0, money.ErrInvalidAmount},
+		{"1.00", "XYZ", 0, money.ErrInvalidCurrency},
+	}
+	for _, c := range cases {
+		m, err := money.Parse(c.in, c.currency)
+		if c.err != nil {
+			if !errors.Is(err, c.err) {
+				t.Errorf("Parse(%q, %q) error = %v, want %v", c.in, c.currency, err, c.err)
+			}
+			continue
+		}
+		if err != nil || m.Minor != c.minor {
+			t.Errorf("Parse(%q, %q) = %v, %v; want %d minor units", c.in, c.currency, m, err, c.minor)
+		}
+	}
+}
+
+func TestMoneyDecimal(t *testing.T) {
+	cases := map[string]money.Money{
+		"1499.50": {Minor: 149950, Currency: "INR"},
+		"0.05":    {Minor: 5, Currency: "USD"},
+		"-0.05":   {Minor: -5, Currency: "USD"},
+		"120":     {Minor: 120, Currency: "JPY"},
+		"0.001":   {Minor: 1, Currency: "BHD"},
+	}
+	for want, m := range cases {
+		if got := m.Decimal(); got != want {
+			t.Errorf("%#v.Decimal() = %q, want %q", m, got, want)
+		}
+	}
+}
+
+func TestMoneyAllocateKeepsEveryPaisa(t *testing.T) {
+	m := money.Money{Minor: 1000, Currency: "INR"}
+	parts, err := m.Allocate(1, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sum int64
+	for _, p := range parts {
+		sum += p.Minor
+	}
+	if sum != 1000 || parts[0].Minor != 334 || parts[1].Minor != 333 {
+		t.Errorf("unexpected allocation %v", parts)
+	}
+}
+
+func TestMoneyJSONRoundTrip(t *testing.T) {
+	var m money.Money
+	if err := json.Unmarshal([]byte(`{"value": 19.99, "currency": "usd"}`), &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Minor != 1999 || m.Currency != "USD" {
+		t.Fatalf("unexpected %#v", m)
+	}
+	out, _ := json.Marshal(m)
+	if string(out) != `{"value":"19.99","currency":"USD","minor_units":1999}` {
+		t.Errorf("unexpected JSON %s", out)
+	}
+	if err := json.Unmarshal([]byte(`{"value": "1", "currency": "???"}`), &m); !errors.Is(err, money.ErrInvalidCurrency) {
+		t.Errorf("expected invalid currency, got %v", err)
+	}
+}
+
+func TestConvertRoundsHalfToEven(t *testing.T) {
+	rate, err := money.ParseRate("0.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[int64]int64{1: 0, 3: 2, 5: 2, -3: -2, 250: 125}
+	for in, want := range cases {
+		got, err := money.Money{Minor: in, Currency: "USD"}.Convert(rate, "EUR")
+		if err != nil || got.Minor != want || got.Currency != "EUR" {
+			t.Errorf("Convert(%d) = %v, %v; want %d EUR", in, got, err, want)
+		}
+	}
+
+	usd, _ := money.ParseRate("83.12")
+	got, _ := money.Money{Minor: 1999, Currency: "USD"}.Convert(usd, "INR")
+	if got.Minor != 166157 {
+		t.Errorf("19.99 USD at 83.12 = %v, want 1661.57 INR", got)
+	}
+	if _, err := money.ParseRate("-1"); !errors.Is(err, money.ErrInvalidRate) {
+		t.Errorf("expected invalid rate, got %v", err)
+	}
+	// numeric(20,10) would round these when stored.
+	for _, tooPrecise := range []string{"0.01234567891", "12345678901"} {
+		if _, err := money.ParseRate(tooPrecise); !errors.Is(err, money.ErrInvalidRate) {
+			t.Errorf("expected %s to be rejected, got %v", tooPrecise, err)
+		}
+	}
+	if _, err := money.ParseRate("0.01234567890000"); err != nil {
+		t.Errorf("trailing zeros rejected: %v", err)
+	}
+}
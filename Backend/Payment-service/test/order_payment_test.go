@@ -0,0 +1,66 @@
+package test
+
+import (
+	"errors"
+	"payment-service/models"
+	"payment-service/service"
+	"testing"
+)
+
+func TestSplitTenderMustMatchOrderTotal(t *testing.T) {
+	svc := newTestPaymentService(newMemoryPaymentRepo())
+	order := models.OrderPayment{OrderID: 5, UserID: 2, Total: rupees(100000)}
+
+	_, err := svc.PayOrder(order, []models.Payment{
+		{PaymentMethod: models.MethodWallet, Amount: rupees(30000)},
+		{PaymentMethod: models.MethodCOD, Amount: rupees(60000)},
+	})
+	if !errors.Is(err, service.ErrTenderMismatch) {
+		t.Errorf("expected short tenders to fail, got %v", err)
+	}
+
+	_, err = svc.PayOrder(order, []models.Payment{
+		{PaymentMethod: "cheque", Amount: rupees(100000)},
+	})
+	if !errors.Is(err, service.ErrInvalidTender) {
+		t.Errorf("expected unknown method to fail, got %v", err)
+	}
+}
+
+func TestOrderPaidOnlyWhenAllTendersSettled(t *testing.T) {
+	svc := newTestPaymentService(newMemoryPaymentRepo())
+	order := models.OrderPayment{OrderID: 5, UserID: 2, Total: rupees(100000)}
+
+	summary, err := svc.PayOrder(order, []models.Payment{
+		{PaymentMethod: models.MethodWallet, Amount: rupees(40000)},
+		{PaymentMethod: models.MethodCOD, Amount: rupees(60000)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wallet, cod := summary.Tenders[0], summary.Tenders[1]
+	if wallet.Status != models.StatusPending || cod.Status != models.StatusAwaitingCollection || summary.FullyPaid {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+	if _, err := svc.PayOrder(order, []models.Payment{{PaymentMethod: models.MethodCard, Amount: rupees(100000)}}); !errors.Is(err, service.ErrOrderAlreadyPaid) {
+		t.Errorf("expected second payment for the order to fail, got %v", err)
+	}
+
+	svc.UpdatePaymentStatus(wallet.ID, models.StatusSuccess, "wl-1")
+	summary, _ = svc.GetOrderPaymentSummary(5)
+	if summary.FullyPaid || summary.Paid.Minor != 40000 || summary.Outstanding.Minor != 60000 {
+		t.Fatalf("after wallet card: %+v", summary)
+	}
+
+	if _, err := svc.CollectCashPayment(wallet.ID, 9); !errors.Is(err, service.ErrInvalidTransition) {
+		t.Errorf("expected collecting a wallet card to fail, got %v", err)
+	}
+	cod, err = svc.CollectCashPayment(cod.ID, 9)
+	if err != nil || cod.Status != models.StatusCollected || *cod.CollectedBy != 9 {
+		t.Fatalf("collect = %+v, %v", cod, err)
+	}
+	summary, _ = svc.GetOrderPaymentSummary(5)
+	if !summary.FullyPaid || summary.Outstanding.Minor != 0 {
+		t.Errorf("expected order fully paid, got %+v", summary)
+	}
+}
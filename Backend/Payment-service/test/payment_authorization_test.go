@@ -0,0 +1,270 @@
+package test
+
+import (
+	"errors"
+	"payment-service/models"
+	"payment-service/money"
+	"payment-service/repository"
+	"payment-service/risk"
+	"payment-service/service"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type memoryPaymentRepo struct {
+	payments map[uint64]models.Payment
+	orders   map[uint64]models.OrderPayment
+	nextID   uint64
+}
+
+func newMemoryPaymentRepo() *memoryPaymentRepo {
+	return &memoryPaymentRepo{payments: map[uint64]models.Payment{}, orders: map[uint64]models.OrderPayment{}}
+}
+
+func (r *memoryPaymentRepo) Create(p models.Payment) (models.Payment, error) {
+	r.nextID++
+	p.ID = r.nextID
+	p.CreatedAt = time.Now()
+	r.payments[p.ID] = p
+	return p, nil
+}
+
+func (r *memoryPaymentRepo) GetByID(id uint64) (models.Payment, error) {
+	p, ok := r.payments[id]
+	if !ok {
+		return p, gorm.ErrRecordNotFound
+	}
+	return p, nil
+}
+
+func (r *memoryPaymentRepo) List(f repository.PaymentFilter) ([]models.Payment, int64, error) {
+	var out []models.Payment
+	for id := uint64(r.nextID); id > 0; id-- {
+		p, ok := r.payments[id]
+		if !ok || (f.UserID != nil && p.UserID != *f.UserID) {
+			continue
+		}
+		if f.After != nil && id >= f.After.ID {
+			continue
+		}
+		out = append(out, p)
+	}
+	total := int64(len(out))
+	if len(out) > f.Limit {
+		out = out[:f.Limit]
+	}
+	return out, total, nil
+}
+
+func (r *memoryPaymentRepo) Update(p models.Payment) (models.Payment, error) {
+	r.payments[p.ID] = p
+	return p, nil
+}
+
+func (r *memoryPaymentRepo) UpdateLocked(id uint64, fn func(*models.Payment) error) (models.Payment, error) {
+	p, err := r.GetByID(id)
+	if err != nil {
+		return p, err
+	}
+	if err := fn(&p); err != nil {
+		return p, err
+	}
+	return r.Update(p)
+}
+
+func (r *memoryPaymentRepo) ListExpiredAuthorizations(now time.Time, limit int) ([]models.Payment, error) {
+	var out []models.Payment
+	for _, p := range r.payments {
+		if (p.Status == models.StatusAuthorized || p.Status == models.StatusPartiallyCaptured) &&
+			p.AuthExpiresAt != nil && !now.Before(*p.AuthExpiresAt) {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+func (r *memoryPaymentRepo) FindByProviderReferences(refs []string) ([]models.Payment, error) {
+	var out []models.Payment
+	for _, p := range r.payments {
+		for _, ref := range refs {
+			if p.ProviderReference == ref {
+				out = append(out, p)
+			}
+		}
+	}
+	return out, nil
+}
+
+func (r *memoryPaymentRepo) ListSettledBetween(from, to time.Time) ([]models.Payment, error) {
+	var out []models.Payment
+	for _, p := range r.payments {
+		if !p.CreatedAt.Before(from) && p.CreatedAt.Before(to) {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+func (r *memoryPaymentRepo) ListByStatus(status string, limit int) ([]models.Payment, error) {
+	var out []models.Payment
+	for id := uint64(1); id <= r.nextID; id++ {
+		if p, ok := r.payments[id]; ok && p.Status == status {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+func (r *memoryPaymentRepo) CreateOrderPayment(order models.OrderPayment, tenders []models.Payment) (models.OrderPayment, []models.Payment, error) {
+	r.orders[order.OrderID] = order
+	for i := range tenders {
+		tenders[i], _ = r.Create(tenders[i])
+	}
+	return order, tenders, nil
+}
+
+func (r *memoryPaymentRepo) GetOrderPayment(orderID uint64) (models.OrderPayment, error) {
+	order, ok := r.orders[orderID]
+	if !ok {
+		return order, gorm.ErrRecordNotFound
+	}
+	return order, nil
+}
+
+func (r *memoryPaymentRepo) ListByOrder(orderID uint64) ([]models.Payment, error) {
+	var out []models.Payment
+	for id := uint64(1); id <= r.nextID; id++ {
+		if p, ok := r.payments[id]; ok && p.OrderID == orderID {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+func newTestPaymentService(repo *memoryPaymentRepo) service.PaymentService {
+	return newTestPaymentServiceWithRisk(repo, nil)
+}
+
+func newTestPaymentServiceWithRisk(repo *memoryPaymentRepo, pipeline *risk.Pipeline) service.PaymentService {
+	rates := service.NewExchangeRateService(nil, "INR", []string{"INR"})
+	methods := service.NewSavedPaymentMethodService(newMemoryMethodRepo())
+	ledger := service.NewLedgerService(&memoryLedgerRepo{})
+	return service.NewPaymentService(repo, rates, methods, pipeline, ledger, time.Hour)
+}
+
+func newTestPaymentServiceWithLedger(repo *memoryPaymentRepo, ledgerRepo *memoryLedgerRepo) (service.PaymentService, service.LedgerService) {
+	rates := service.NewExchangeRateService(nil, "INR", []string{"INR"})
+	methods := service.NewSavedPaymentMethodService(newMemoryMethodRepo())
+	ledger := service.NewLedgerService(ledgerRepo)
+	return service.NewPaymentService(repo, rates, methods, nil, ledger, time.Hour), ledger
+}
+
+func rupees(minor int64) money.Money {
+	return money.Money{Minor: minor, Currency: "INR"}
+}
+
+func TestAuthorizeAndCaptureInParts(t *testing.T) {
+	svc := newTestPaymentService(newMemoryPaymentRepo())
+	p, err := svc.AuthorizePayment(models.Payment{OrderID: 1, UserID: 2, Amount: rupees(100000)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Status != models.StatusAuthorized || p.AuthExpiresAt == nil {
+		t.Fatalf("unexpected authorization %+v", p)
+	}
+
+	part := rupees(40000)
+	if p, err = svc.CapturePayment(p.ID, &part); err != nil || p.Status != models.StatusPartiallyCaptured {
+		t.Fatalf("partial capture = %s, %v", p.Status, err)
+	}
+	tooMuch := rupees(60001)
+	if _, err := svc.CapturePayment(p.ID, &tooMuch); !errors.Is(err, service.ErrCaptureTooLarge) {
+		t.Errorf("expected over-capture to fail, got %v", err)
+	}
+	if p, err = svc.CapturePayment(p.ID, nil); err != nil || p.Status != models.StatusCaptured || p.Captured.Minor != 100000 {
+		t.Fatalf("final capture = %+v, %v", p, err)
+	}
+	if _, err := svc.VoidPayment(p.ID, ""); !errors.Is(err, service.ErrInvalidTransition) {
+		t.Errorf("expected void after capture to fail, got %v", err)
+	}
+}
+
+func TestUpdatePaymentStatusFollowsTransitions(t *testing.T) {
+	svc := newTestPaymentService(newMemoryPaymentRepo())
+	p, err := svc.CreatePayment(models.Payment{OrderID: 1, UserID: 2, PaymentMethod: models.MethodCard, Amount: rupees(5000)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, err = svc.UpdatePaymentStatus(p.ID, models.StatusSuccess, "ch-1"); err != nil || p.Status != models.StatusSuccess {
+		t.Fatalf("pending to success = %+v, %v", p, err)
+	}
+	if _, err := svc.RefundPayment(p.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.UpdatePaymentStatus(p.ID, models.StatusSuccess, ""); !errors.Is(err, service.ErrInvalidTransition) {
+		t.Errorf("expected refunded back to success to fail, got %v", err)
+	}
+}
+
+func TestVoidAfterPartialCaptureKeepsCapturedAmount(t *testing.T) {
+	svc := newTestPaymentService(newMemoryPaymentRepo())
+	p, _ := svc.AuthorizePayment(models.Payment{OrderID: 1, UserID: 2, Amount: rupees(5000)})
+	part := rupees(2000)
+	svc.CapturePayment(p.ID, &part)
+
+	p, err := svc.VoidPayment(p.ID, "backorder cancelled")
+	if err != nil || p.Status != models.StatusCaptured || p.Captured.Minor != 2000 || p.VoidedAt == nil {
+		t.Errorf("void = %+v, %v", p, err)
+	}
+}
+
+func TestExpireAuthorizations(t *testing.T) {
+	repo := newMemoryPaymentRepo()
+	svc := newTestPaymentService(repo)
+	stale, _ := svc.AuthorizePayment(models.Payment{OrderID: 1, UserID: 2, Amount: rupees(5000)})
+	fresh, _ := svc.AuthorizePayment(models.Payment{OrderID: 2, UserID: 2, Amount: rupees(5000)})
+	past := time.Now().Add(-time.Minute)
+	stale.AuthExpiresAt = &past
+	repo.Update(stale)
+
+	n, err := svc.ExpireAuthorizations(time.Now())
+	if err != nil || n != 1 {
+		t.Fatalf("ExpireAuthorizations = %d, %v", n, err)
+	}
+	if p, _ := repo.GetByID(stale.ID); p.Status != models.StatusVoided || p.VoidReason != "expired" {
+		t.Errorf("stale authorization not voided: %+v", p)
+	}
+	if p, _ := repo.GetByID(fresh.ID); p.Status != models.StatusAuthorized {
+		t.Errorf("fresh authorization changed: %+v", p)
+	}
+	if _, err := svc.CapturePayment(stale.ID, nil); !errors.Is(err, service.ErrInvalidTransition) {
+		t.Errorf("expected capture of voided payment to fail, got %v", err)
+	}
+}
+
+func TestListPaymentsPagesWithCursor(t *testing.T) {
+	repo := newMemoryPaymentRepo()
+	svc := newTestPaymentService(repo)
+	for i := 0; i < 5; i++ {
+		svc.CreatePayment(models.Payment{OrderID: uint64(i), UserID: 1, Amount: rupees(100)})
+	}
+	svc.CreatePayment(models.Payment{OrderID: 9, UserID: 2, Amount: rupees(100)})
+
+	user := uint64(1)
+	first, err := svc.ListPayments(repository.PaymentFilter{UserID: &user, Limit: 3}, "")
+	if err != nil || len(first.Items) != 3 || first.Total != 5 || first.NextCursor == "" {
+		t.Fatalf("first page = %+v, %v", first, err)
+	}
+	second, err := svc.ListPayments(repository.PaymentFilter{UserID: &user, Limit: 3}, first.NextCursor)
+	if err != nil || len(second.Items) != 2 || second.NextCursor != "" {
+		t.Fatalf("second page = %+v, %v", second, err)
+	}
+	if second.Items[0].ID >= first.Items[2].ID {
+		t.Errorf("pages overlap: %d after %d", second.Items[0].ID, first.Items[2].ID)
+	}
+	if _, err := svc.ListPayments(repository.PaymentFilter{}, "not-a-cursor"); !errors.Is(err, service.ErrInvalidCursor) {
+		t.Errorf("expected invalid cursor, got %v", err)
+	}
+}
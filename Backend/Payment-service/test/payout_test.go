@@ -0,0 +1,156 @@
+package test
+
+import (
+	"errors"
+	"payment-service/models"
+	"payment-service/repository"
+	"payment-service/service"
+	"sort"
+	"testing"
+	"time"
+)
+
+type memoryPayoutRepo struct {
+	shares  []models.SellerShare
+	payouts map[uint64]models.SellerPayout
+}
+
+func newMemoryPayoutRepo() *memoryPayoutRepo {
+	return &memoryPayoutRepo{payouts: map[uint64]models.SellerPayout{}}
+}
+
+// addShares stands in for the shares being stored with the order.
+func (r *memoryPayoutRepo) addShares(shares []models.SellerShare) {
+	for _, share := range shares {
+		share.ID = uint64(len(r.shares) + 1)
+		r.shares = append(r.shares, share)
+	}
+}
+
+func (r *memoryPayoutRepo) UnpaidShares(sellerID uint64) ([]models.SellerShare, error) {
+	var out []models.SellerShare
+	for _, share := range r.shares {
+		if share.PayoutID == nil && (sellerID == 0 || share.SellerID == sellerID) {
+			out = append(out, share)
+		}
+	}
+	return out, nil
+}
+
+func (r *memoryPayoutRepo) CreatePayout(payout models.SellerPayout, shareIDs []uint64) (models.SellerPayout, error) {
+	for _, id := range shareIDs {
+		if r.shares[id-1].PayoutID != nil {
+			return models.SellerPayout{}, repository.ErrShareTaken
+		}
+	}
+	payout.ID = uint64(len(r.payouts) + 1)
+	payout.CreatedAt = time.Now()
+	r.payouts[payout.ID] = payout
+	for _, id := range shareIDs {
+		r.shares[id-1].PayoutID = &payout.ID
+	}
+	return payout, nil
+}
+
+func (r *memoryPayoutRepo) GetPayout(id uint64) (models.SellerPayout, error) {
+	return r.payouts[id], nil
+}
+
+func (r *memoryPayoutRepo) ListPayouts(sellerID uint64) ([]models.SellerPayout, error) {
+	var out []models.SellerPayout
+	for _, p := range r.payouts {
+		if sellerID == 0 || p.SellerID == sellerID {
+			out = append(out, p)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID > out[j].ID })
+	return out, nil
+}
+
+func (r *memoryPayoutRepo) MarkPayoutPaid(id uint64, reference string, at time.Time) (models.SellerPayout, error) {
+	p := r.payouts[id]
+	if p.Status != models.PayoutPending {
+		return p, repository.ErrPayoutNotPending
+	}
+	p.Status, p.Reference, p.PaidAt = models.PayoutPaid, reference, &at
+	r.payouts[id] = p
+	return p, nil
+}
+
+func TestOrderSharesMustFitTotal(t *testing.T) {
+	svc := newTestPaymentService(newMemoryPaymentRepo())
+	order := models.OrderPayment{OrderID: 5, UserID: 2, Total: rupees(100000), Shares: []models.SellerShare{
+		{SellerID: 7, Amount: rupees(60000)},
+		{SellerID: 8, Amount: rupees(50000)},
+	}}
+	_, err := svc.PayOrder(order, []models.Payment{{PaymentMethod: models.MethodCOD, Amount: rupees(100000)}})
+	if !errors.Is(err, service.ErrShareMismatch) {
+		t.Errorf("expected shares over the total to fail, got %v", err)
+	}
+
+	order.Shares = []models.SellerShare{{Amount: rupees(60000)}}
+	_, err = svc.PayOrder(order, []models.Payment{{PaymentMethod: models.MethodCOD, Amount: rupees(100000)}})
+	if !errors.Is(err, service.ErrShareMismatch) {
+		t.Errorf("expected a share without a seller to fail, got %v", err)
+	}
+}
+
+func TestPayoutsOnlyForPaidOrders(t *testing.T) {
+	payments := newTestPaymentService(newMemoryPaymentRepo())
+	repo := newMemoryPayoutRepo()
+	payouts, err := service.NewPayoutService(repo, payments, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pay := func(orderID uint64, shares ...models.SellerShare) models.Payment {
+		summary, err := payments.PayOrder(models.OrderPayment{OrderID: orderID, UserID: 2, Total: rupees(100000), Shares: shares},
+			[]models.Payment{{PaymentMethod: models.MethodCOD, Amount: rupees(100000)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		repo.addShares(summary.Shares)
+		return summary.Tenders[0]
+	}
+	cod := pay(1, models.SellerShare{SellerID: 7, Amount: rupees(60005)}, models.SellerShare{SellerID: 8, Amount: rupees(30000)})
+	pay(2, models.SellerShare{SellerID: 7, Amount: rupees(20000)})
+
+	earnings, _ := payouts.Earnings(7)
+	if len(earnings) != 1 || earnings[0].Payable.Minor != 0 || earnings[0].Pending.Minor != 80005 {
+		t.Fatalf("earnings before payment = %+v", earnings)
+	}
+	if run, err := payouts.RunPayouts(); err != nil || len(run) != 0 {
+		t.Fatalf("run before payment = %+v, %v", run, err)
+	}
+
+	if _, err := payments.CollectCashPayment(cod.ID, 9); err != nil {
+		t.Fatal(err)
+	}
+	earnings, _ = payouts.Earnings(7)
+	if earnings[0].Payable.Minor != 60005 || earnings[0].Pending.Minor != 20000 {
+		t.Fatalf("earnings after payment = %+v", earnings)
+	}
+
+	run, err := payouts.RunPayouts()
+	if err != nil || len(run) != 2 {
+		t.Fatalf("run = %+v, %v", run, err)
+	}
+	first := run[0]
+	if first.SellerID != 7 || first.Gross.Minor != 60005 || first.Commission.Minor+first.Net.Minor != 60005 || first.Net.Minor != 54004 {
+		t.Errorf("unexpected payout %+v", first)
+	}
+	if again, _ := payouts.RunPayouts(); len(again) != 0 {
+		t.Errorf("shares paid out twice: %+v", again)
+	}
+
+	if _, err := payouts.MarkPayoutPaid(first.ID, ""); !errors.Is(err, service.ErrMissingReference) {
+		t.Errorf("expected a reference to be required, got %v", err)
+	}
+	paid, err := payouts.MarkPayoutPaid(first.ID, "UTR1")
+	if err != nil || paid.Status != models.PayoutPaid {
+		t.Fatalf("mark paid = %+v, %v", paid, err)
+	}
+	if _, err := payouts.MarkPayoutPaid(first.ID, "UTR2"); !errors.Is(err, repository.ErrPayoutNotPending) {
+		t.Errorf("expected paying twice to fail, got %v", err)
+	}
+}
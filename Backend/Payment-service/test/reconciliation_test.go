@@ -0,0 +1,72 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"payment-service/models"
+	"payment-service/service"
+	"strings"
+	"testing"
+)
+
+const settlementCSV = `provider_reference,amount,currency,settled_at
+pay_A,499.00,INR,2026-10-18
+pay_B,120.50,INR,2026-10-18
+pay_X,10.00,INR,2026-10-18
+`
+
+func TestReconcileClassifiesRows(t *testing.T) {
+	rows, err := service.ParseSettlementCSV(strings.NewReader(settlementCSV))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payments := []models.Payment{
+		{ID: 1, ProviderReference: "pay_A", Amount: rupees(49900), Status: models.StatusSuccess},
+		{ID: 2, ProviderReference: "pay_B", Amount: rupees(20000), Captured: rupees(12000), Status: models.StatusCaptured},
+		{ID: 3, ProviderReference: "pay_C", Amount: rupees(1000), Status: models.StatusSuccess},
+	}
+
+	got := map[string]string{}
+	for _, item := range service.Reconcile(payments, rows) {
+		got[item.ProviderReference] = item.Result
+	}
+	want := map[string]string{
+		"pay_A": models.ReconMatched,
+		"pay_B": models.ReconAmountMismatch,
+		"pay_X": models.ReconMissingInternal,
+		"pay_C": models.ReconMissingProvider,
+	}
+	for ref, result := range want {
+		if got[ref] != result {
+			t.Errorf("%s: got %q, want %q", ref, got[ref], result)
+		}
+	}
+}
+
+func TestParseSettlementCSVRejectsBadRows(t *testing.T) {
+	for _, in := range []string{
+		"reference,amount\npay_A,1.00\n",
+		"reference,amount,currency\npay_A,1.005,INR\n",
+		"reference,amount,currency\n,1.00,INR\n",
+	} {
+		if _, err := service.ParseSettlementCSV(strings.NewReader(in)); !errors.Is(err, service.ErrInvalidSettlementFile) {
+			t.Errorf("expected %q to be rejected, got %v", in, err)
+		}
+	}
+}
+
+func TestWriteReportCSV(t *testing.T) {
+	ours, theirs := int64(12000), int64(12050)
+	id := uint64(2)
+	run := models.ReconciliationRun{Items: []models.ReconciliationItem{{
+		Result: models.ReconAmountMismatch, ProviderReference: "pay_B", PaymentID: &id,
+		Currency: "INR", OurAmountMinor: &ours, ProviderAmountMinor: &theirs,
+	}}}
+	var buf bytes.Buffer
+	if err := service.WriteReportCSV(&buf, run); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "amount_mismatch,pay_B,2,INR,120.00,120.50,") {
+		t.Errorf("unexpected report:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,106 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"payment-service/models"
+	"payment-service/risk"
+	"payment-service/service"
+	"testing"
+	"time"
+)
+
+type fakeBlocklist map[string]bool
+
+func (b fakeBlocklist) IsBlocked(kind, value string) (bool, error) {
+	return b[kind+":"+value], nil
+}
+
+type fakeVelocity map[string]int64
+
+func (v fakeVelocity) CountRecentPayments(kind, value string, since time.Time) (int64, error) {
+	return v[kind+":"+value], nil
+}
+
+func testPipeline(blocked fakeBlocklist) *risk.Pipeline {
+	return &risk.Pipeline{
+		ReviewScore: 50,
+		DenyScore:   80,
+		Rules: []risk.Rule{
+			&risk.BlocklistRule{Store: blocked, Score: 100},
+			&risk.VelocityRule{Counter: fakeVelocity{"ip:10.0.0.9": 25}, Window: time.Hour, MaxPerIP: 20, Score: 30},
+			&risk.AmountRule{Thresholds: []risk.AmountThreshold{{Minor: 5000000, Score: 25}, {Minor: 20000000, Score: 50}}},
+			&risk.CountryMismatchRule{Score: 25},
+		},
+	}
+}
+
+func TestRiskPipelineDecisions(t *testing.T) {
+	pipeline := testPipeline(fakeBlocklist{"country:KP": true})
+	cases := []struct {
+		name  string
+		in    risk.Input
+		score int
+		want  string
+	}{
+		{"small domestic", risk.Input{UserID: 1, BaseAmount: rupees(10000), BillingCountry: "IN", ShippingCountry: "in"}, 0, risk.DecisionAllow},
+		{"large and mismatched", risk.Input{UserID: 1, BaseAmount: rupees(6000000), BillingCountry: "IN", ShippingCountry: "US"}, 50, risk.DecisionReview},
+		{"very large only", risk.Input{UserID: 1, BaseAmount: rupees(25000000)}, 50, risk.DecisionReview},
+		{"busy ip", risk.Input{UserID: 1, IP: "10.0.0.9", BaseAmount: rupees(100)}, 30, risk.DecisionAllow},
+		{"blocked country", risk.Input{UserID: 1, BaseAmount: rupees(100), BillingCountry: "kp"}, 100, risk.DecisionDeny},
+	}
+	for _, c := range cases {
+		res, err := pipeline.Evaluate(context.Background(), c.in)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if res.Score != c.score || res.Decision != c.want {
+			t.Errorf("%s: got score %d decision %s, want %d %s (%+v)", c.name, res.Score, res.Decision, c.score, c.want, res.Signals)
+		}
+	}
+}
+
+func TestNilPipelineAllows(t *testing.T) {
+	var pipeline *risk.Pipeline
+	res, err := pipeline.Evaluate(context.Background(), risk.Input{UserID: 1})
+	if err != nil || res.Decision != risk.DecisionAllow {
+		t.Errorf("nil pipeline = %+v, %v", res, err)
+	}
+}
+
+func TestRiskyPaymentIsHeldUntilReviewed(t *testing.T) {
+	repo := newMemoryPaymentRepo()
+	svc := newTestPaymentServiceWithRisk(repo, testPipeline(fakeBlocklist{}))
+
+	p, err := svc.AuthorizePayment(models.Payment{OrderID: 1, UserID: 2, Amount: rupees(6000000), BillingCountry: "IN", ShippingCountry: "GB"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Status != models.StatusOnHold || p.AuthExpiresAt != nil || len(p.RiskSignals) != 2 {
+		t.Fatalf("expected payment on hold, got %+v", p)
+	}
+	if held, _ := svc.ListHeldPayments(); len(held) != 1 {
+		t.Errorf("expected one held payment, got %d", len(held))
+	}
+
+	p, err = svc.ReviewPayment(p.ID, true, 99, "called the customer")
+	if err != nil || p.Status != models.StatusAuthorized || p.AuthExpiresAt == nil || *p.ReviewedBy != 99 {
+		t.Fatalf("approve = %+v, %v", p, err)
+	}
+	if _, err := svc.ReviewPayment(p.ID, false, 99, ""); !errors.Is(err, service.ErrInvalidTransition) {
+		t.Errorf("expected second review to fail, got %v", err)
+	}
+}
+
+func TestBlocklistedPaymentIsDeclined(t *testing.T) {
+	repo := newMemoryPaymentRepo()
+	svc := newTestPaymentServiceWithRisk(repo, testPipeline(fakeBlocklist{"user:7": true}))
+
+	p, err := svc.CreatePayment(models.Payment{OrderID: 1, UserID: 7, Amount: rupees(1000)})
+	if !errors.Is(err, service.ErrPaymentDeclined) {
+		t.Fatalf("expected decline, got %v", err)
+	}
+	if stored, _ := repo.GetByID(p.ID); stored.Status != models.StatusDeclined {
+		t.Errorf("declined payment stored as %q", stored.Status)
+	}
+}
@@ -0,0 +1,122 @@
+package test
+
+import (
+	"errors"
+	"payment-service/models"
+	"payment-service/service"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type memoryMethodRepo struct {
+	methods map[uint64]models.SavedPaymentMethod
+	nextID  uint64
+}
+
+func newMemoryMethodRepo() *memoryMethodRepo {
+	return &memoryMethodRepo{methods: map[uint64]models.SavedPaymentMethod{}}
+}
+
+func (r *memoryMethodRepo) Create(m models.SavedPaymentMethod) (models.SavedPaymentMethod, error) {
+	r.nextID++
+	m.ID = r.nextID
+	r.methods[m.ID] = m
+	return m, nil
+}
+
+func (r *memoryMethodRepo) GetByID(id uint64) (models.SavedPaymentMethod, error) {
+	m, ok := r.methods[id]
+	if !ok {
+		return m, gorm.ErrRecordNotFound
+	}
+	return m, nil
+}
+
+func (r *memoryMethodRepo) ListByUser(userID uint64) ([]models.SavedPaymentMethod, error) {
+	var out []models.SavedPaymentMethod
+	for _, m := range r.methods {
+		if m.UserID == userID {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+func (r *memoryMethodRepo) SetDefault(userID, id uint64) error { return nil }
+func (r *memoryMethodRepo) Delete(userID, id uint64) error     { return nil }
+
+func validCard() models.SavedPaymentMethod {
+	return models.SavedPaymentMethod{
+		UserID:       7,
+		Type:         "card",
+		GatewayToken: "tok_1NkD0s2eZvKYlo2C",
+		Brand:        "visa",
+		Last4:        "4242",
+		ExpMonth:     12,
+		ExpYear:      time.Now().Year() + 2,
+	}
+}
+
+func TestSavePaymentMethodRejectsRawCardNumbers(t *testing.T) {
+	svc := service.NewSavedPaymentMethodService(newMemoryMethodRepo())
+
+	card := validCard()
+	card.Label = "my card 4111-1111-1111-1111"
+	if _, err := svc.Save(card); !errors.Is(err, service.ErrRawCardData) {
+		t.Errorf("expected PAN in label to be rejected, got %v", err)
+	}
+
+	if _, err := svc.Save(validCard()); err != nil {
+		t.Errorf("tokenized card rejected: %v", err)
+	}
+}
+
+func TestSavePaymentMethodChecksTokenShape(t *testing.T) {
+	svc := service.NewSavedPaymentMethodService(newMemoryMethodRepo())
+
+	// Some gateways issue numeric tokens that happen to pass the Luhn check.
+	card := validCard()
+	card.GatewayToken = "4242424242424242"
+	if _, err := svc.Save(card); err != nil {
+		t.Errorf("numeric token rejected: %v", err)
+	}
+
+	card.GatewayToken = "4242 4242 4242 4242"
+	if _, err := svc.Save(card); !errors.Is(err, service.ErrInvalidPaymentMethod) {
+		t.Errorf("expected malformed token to be rejected, got %v", err)
+	}
+}
+
+func TestSavePaymentMethodValidation(t *testing.T) {
+	svc := service.NewSavedPaymentMethodService(newMemoryMethodRepo())
+
+	expired := validCard()
+	expired.ExpYear = 2020
+	upi := models.SavedPaymentMethod{UserID: 7, Type: "upi", UPIID: "not-a-vpa"}
+	for _, m := range []models.SavedPaymentMethod{expired, upi, {UserID: 7, Type: "cheque"}} {
+		if _, err := svc.Save(m); !errors.Is(err, service.ErrInvalidPaymentMethod) {
+			t.Errorf("expected %+v to be invalid, got %v", m, err)
+		}
+	}
+
+	upi.UPIID = "ravi.k@okaxis"
+	if _, err := svc.Save(upi); err != nil {
+		t.Errorf("valid UPI ID rejected: %v", err)
+	}
+}
+
+func TestResolvePaymentMethodChecksOwner(t *testing.T) {
+	svc := service.NewSavedPaymentMethodService(newMemoryMethodRepo())
+	saved, err := svc.Save(validCard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Resolve(8, saved.ID); !errors.Is(err, service.ErrPaymentMethodNotFound) {
+		t.Errorf("another user's method resolved: %v", err)
+	}
+	if m, err := svc.Resolve(7, saved.ID); err != nil || m.Type != "card" {
+		t.Errorf("Resolve = %+v, %v", m, err)
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/gajare/BAJAR-App/Backend/Product-Catalog-Service/Category/internal/config"
 	"github.com/gajare/BAJAR-App/Backend/Product-Catalog-Service/Category/internal/handlers"
@@ -12,6 +13,7 @@ import (
 	"github.com/gajare/BAJAR-App/Backend/Product-Catalog-Service/database"
 	"github.com/gajare/BAJAR-App/Backend/Product-Catalog-Service/middleware"
 
+	"github.com/gajare/BAJAR-App/Backend/pkg/authz"
 	"github.com/gorilla/mux"
 	_ "github.com/jinzhu/gorm/dialects/postgres"
 	"go.uber.org/zap"
@@ -62,12 +64,18 @@ func main() {
 	// API routes
 	api := router.PathPrefix("/api/v1").Subrouter()
 
+	// Writes need a token from the user service with catalog:write.
+	authenticated := authz.Authenticate(authz.NewJWKSVerifier(cfg.JWKSURL, 10*time.Minute))
+	canWrite := func(h http.HandlerFunc) http.Handler {
+		return authenticated(authz.Require(authz.PermCatalogWrite)(h))
+	}
+
 	// Category routes
 	api.HandleFunc("/categories", categoryHandler.GetAllCategories).Methods("GET")
 	api.HandleFunc("/categories/{id}", categoryHandler.GetCategory).Methods("GET")
-	api.HandleFunc("/categories", categoryHandler.CreateCategory).Methods("POST")
-	api.HandleFunc("/categories/{id}", categoryHandler.UpdateCategory).Methods("PUT")
-	api.HandleFunc("/categories/{id}", categoryHandler.DeleteCategory).Methods("DELETE")
+	api.Handle("/categories", canWrite(categoryHandler.CreateCategory)).Methods("POST")
+	api.Handle("/categories/{id}", canWrite(categoryHandler.UpdateCategory)).Methods("PUT")
+	api.Handle("/categories/{id}", canWrite(categoryHandler.DeleteCategory)).Methods("DELETE")
 
 	logger.Info("Category Service starting on :8081")
 	if err := http.ListenAndServe(":8081", router); err != nil {
@@ -5,6 +5,7 @@ import "os"
 type Config struct {
 	DatabaseURL string
 	Port        string
+	JWKSURL     string
 }
 
 func LoadConfig() (*Config, error) {
@@ -18,8 +19,14 @@ func LoadConfig() (*Config, error) {
 		port = "8081"
 	}
 
+	jwksURL := os.Getenv("JWKS_URL")
+	if jwksURL == "" {
+		jwksURL = "http://localhost:8080/.well-known/jwks.json"
+	}
+
 	return &Config{
 		DatabaseURL: databaseURL,
 		Port:        port,
+		JWKSURL:     jwksURL,
 	}, nil
 }
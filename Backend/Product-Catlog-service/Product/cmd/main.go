@@ -3,6 +3,7 @@ package main
 import (
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/gajare/BAJAR-App/Backend/Product-Catalog-Service/Product/internal/config"
 	"github.com/gajare/BAJAR-App/Backend/Product-Catalog-Service/Product/internal/handlers"
@@ -12,7 +13,9 @@ import (
 
 	"github.com/gajare/BAJAR-App/Backend/Product-Catalog-Service/database"
 	"github.com/gajare/BAJAR-App/Backend/Product-Catalog-Service/middleware"
+	"github.com/gajare/BAJAR-App/Backend/Product-Catalog-Service/pkg/currency"
 
+	"github.com/gajare/BAJAR-App/Backend/pkg/authz"
 	"github.com/gorilla/mux"
 	_ "github.com/jinzhu/gorm/dialects/postgres"
 	"go.uber.org/zap"
@@ -54,7 +57,8 @@ func main() {
 
 	// Initialize repository, service and handler
 	productRepo := repository.NewProductRepository(db)
-	productService := services.NewProductService(productRepo)
+	rates := currency.NewClient(cfg.ExchangeRatesURL, cfg.BaseCurrency, 5*time.Minute)
+	productService := services.NewProductService(productRepo, rates)
 	productHandler := handlers.NewProductHandler(productService, logger)
 
 	// Initialize router
@@ -67,15 +71,21 @@ func main() {
 	// API routes
 	api := router.PathPrefix("/api/v1").Subrouter()
 
+	// Writes need a token from the user service. Catalog staff (with
+	// catalog:write) may change any product; marketplace sellers only their
+	// own, which the handlers check.
+	authenticated := authz.Authenticate(authz.NewJWKSVerifier(cfg.JWKSURL, 10*time.Minute))
+
 	// Product routes
 	api.HandleFunc("/products", productHandler.GetAllProducts).Methods("GET")
 	api.HandleFunc("/products/{id}", productHandler.GetProduct).Methods("GET")
-	api.HandleFunc("/products", productHandler.CreateProduct).Methods("POST")
-	api.HandleFunc("/products/{id}", productHandler.UpdateProduct).Methods("PUT")
-	api.HandleFunc("/products/{id}", productHandler.DeleteProduct).Methods("DELETE")
+	api.Handle("/products", authenticated(http.HandlerFunc(productHandler.CreateProduct))).Methods("POST")
+	api.Handle("/products/{id}", authenticated(http.HandlerFunc(productHandler.UpdateProduct))).Methods("PUT")
+	api.Handle("/products/{id}", authenticated(http.HandlerFunc(productHandler.DeleteProduct))).Methods("DELETE")
 	api.HandleFunc("/products/search", productHandler.SearchProducts).Methods("GET")
 	api.HandleFunc("/products/{id}/images", productHandler.GetProductImages).Methods("GET")
 	api.HandleFunc("/products/{id}/variants", productHandler.GetProductVariants).Methods("GET")
+	api.HandleFunc("/sellers/{sellerID}/products", productHandler.GetSellerProducts).Methods("GET")
 
 	logger.Info("Product Service starting on :8082")
 	if err := http.ListenAndServe(":8082", router); err != nil {
@@ -3,8 +3,11 @@ package config
 import "os"
 
 type Config struct {
-	DatabaseURL string
-	Port        string
+	DatabaseURL      string
+	Port             string
+	BaseCurrency     string
+	ExchangeRatesURL string
+	JWKSURL          string
 }
 
 func LoadConfig() (*Config, error) {
@@ -18,8 +21,26 @@ func LoadConfig() (*Config, error) {
 		port = "8082"
 	}
 
+	baseCurrency := os.Getenv("BASE_CURRENCY")
+	if baseCurrency == "" {
+		baseCurrency = "INR"
+	}
+
+	exchangeRatesURL := os.Getenv("EXCHANGE_RATES_URL")
+	if exchangeRatesURL == "" {
+		exchangeRatesURL = "http://localhost:8080/exchange-rates"
+	}
+
+	jwksURL := os.Getenv("JWKS_URL")
+	if jwksURL == "" {
+		jwksURL = "http://localhost:8080/.well-known/jwks.json"
+	}
+
 	return &Config{
-		DatabaseURL: databaseURL,
-		Port:        port,
+		DatabaseURL:      databaseURL,
+		Port:             port,
+		BaseCurrency:     baseCurrency,
+		ExchangeRatesURL: exchangeRatesURL,
+		JWKSURL:          jwksURL,
 	}, nil
 }
@@ -2,13 +2,16 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gajare/BAJAR-App/Backend/Product-Catalog-Service/Product/internal/models"
 	"github.com/gajare/BAJAR-App/Backend/Product-Catalog-Service/Product/internal/services"
+	"github.com/gajare/BAJAR-App/Backend/Product-Catalog-Service/pkg/currency"
 	"github.com/gajare/BAJAR-App/Backend/Product-Catalog-Service/pkg/response"
 
+	"github.com/gajare/BAJAR-App/Backend/pkg/authz"
 	"github.com/gorilla/mux"
 	"go.uber.org/zap"
 )
@@ -33,6 +36,7 @@ func NewProductHandler(service services.ProductService, logger *zap.Logger) *Pro
 // @Param page query int false "Page number"
 // @Param limit query int false "Number of items per page"
 // @Param status query string false "Filter by status"
+// @Param currency query string false "Also return prices in this currency (e.g. USD)"
 // @Success 200 {array} models.Product
 // @Router /products [get]
 func (h *ProductHandler) GetAllProducts(w http.ResponseWriter, r *http.Request) {
@@ -61,6 +65,10 @@ func (h *ProductHandler) GetAllProducts(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
+	if !h.localize(w, products, r.URL.Query().Get("currency")) {
+		return
+	}
+
 	response.JSON(w, products, http.StatusOK)
 }
 
@@ -70,6 +78,7 @@ func (h *ProductHandler) GetAllProducts(w http.ResponseWriter, r *http.Request)
 // @Tags products
 // @Produce json
 // @Param id path int true "Product ID"
+// @Param currency query string false "Also return prices in this currency (e.g. USD)"
 // @Success 200 {object} models.Product
 // @Router /products/{id} [get]
 func (h *ProductHandler) GetProduct(w http.ResponseWriter, r *http.Request) {
@@ -87,7 +96,12 @@ func (h *ProductHandler) GetProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	response.JSON(w, product, http.StatusOK)
+	localized := []models.Product{*product}
+	if !h.localize(w, localized, r.URL.Query().Get("currency")) {
+		return
+	}
+
+	response.JSON(w, localized[0], http.StatusOK)
 }
 
 // CreateProduct godoc
@@ -111,6 +125,17 @@ func (h *ProductHandler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Catalog staff may list products for any seller; sellers only for
+	// themselves.
+	caller, _ := authz.FromContext(r.Context())
+	if !caller.Can(authz.PermCatalogWrite) {
+		request.SellerID = caller.SellerID
+	}
+	if !caller.CanForSeller(request.SellerID, authz.PermCatalogWrite) {
+		response.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
 	product, err := h.service.CreateProduct(request)
 	if err != nil {
 		h.logger.Error("Failed to create product", zap.Error(err))
@@ -145,6 +170,10 @@ func (h *ProductHandler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !h.canManage(w, r, uint(id)) {
+		return
+	}
+
 	product, err := h.service.UpdateProduct(uint(id), request)
 	if err != nil {
 		h.logger.Error("Failed to update product", zap.Error(err))
@@ -170,6 +199,10 @@ func (h *ProductHandler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !h.canManage(w, r, uint(id)) {
+		return
+	}
+
 	if err := h.service.DeleteProduct(uint(id)); err != nil {
 		h.logger.Error("Failed to delete product", zap.Error(err))
 		response.Error(w, "Failed to delete product", http.StatusInternalServerError)
@@ -190,6 +223,7 @@ func (h *ProductHandler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
 // @Param status query string false "Product status"
 // @Param page query int false "Page number"
 // @Param limit query int false "Number of items per page"
+// @Param currency query string false "Also return prices in this currency (e.g. USD)"
 // @Success 200 {array} models.Product
 // @Router /products/search [get]
 func (h *ProductHandler) SearchProducts(w http.ResponseWriter, r *http.Request) {
@@ -207,6 +241,10 @@ func (h *ProductHandler) SearchProducts(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if !h.localize(w, products, r.URL.Query().Get("currency")) {
+		return
+	}
+
 	response.JSON(w, products, http.StatusOK)
 }
 
@@ -261,3 +299,69 @@ func (h *ProductHandler) GetProductVariants(w http.ResponseWriter, r *http.Reque
 
 	response.JSON(w, variants, http.StatusOK)
 }
+
+// GetSellerProducts godoc
+// @Summary Get a seller's products
+// @Description Get every product offered by a marketplace seller
+// @Tags products
+// @Produce json
+// @Param sellerID path int true "Seller ID"
+// @Param currency query string false "Also return prices in this currency (e.g. USD)"
+// @Success 200 {array} models.Product
+// @Router /sellers/{sellerID}/products [get]
+func (h *ProductHandler) GetSellerProducts(w http.ResponseWriter, r *http.Request) {
+	sellerID, err := strconv.ParseUint(mux.Vars(r)["sellerID"], 10, 64)
+	if err != nil {
+		response.Error(w, "Invalid seller ID", http.StatusBadRequest)
+		return
+	}
+
+	products, err := h.service.GetSellerProducts(sellerID)
+	if err != nil {
+		h.logger.Error("Failed to get seller products", zap.Error(err))
+		response.Error(w, "Failed to get seller products", http.StatusInternalServerError)
+		return
+	}
+
+	if !h.localize(w, products, r.URL.Query().Get("currency")) {
+		return
+	}
+
+	response.JSON(w, products, http.StatusOK)
+}
+
+// canManage reports whether the caller may change product id: catalog
+// staff may change any product, sellers only their own. Otherwise it
+// writes an error response.
+func (h *ProductHandler) canManage(w http.ResponseWriter, r *http.Request, id uint) bool {
+	product, err := h.service.GetProduct(id)
+	if err != nil {
+		response.Error(w, "Product not found", http.StatusNotFound)
+		return false
+	}
+	caller, _ := authz.FromContext(r.Context())
+	if !caller.CanForSeller(product.SellerID, authz.PermCatalogWrite) {
+		response.Error(w, "Forbidden", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// localize adds prices in the requested currency, writing an error
+// response and returning false if that is not possible.
+func (h *ProductHandler) localize(w http.ResponseWriter, products []models.Product, currencyCode string) bool {
+	err := h.service.LocalizePrices(products, currencyCode)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, currency.ErrUnsupportedCurrency):
+		response.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, currency.ErrRatesUnavailable):
+		h.logger.Error("Exchange rates unavailable", zap.Error(err))
+		response.Error(w, "Prices are not available in the requested currency right now", http.StatusServiceUnavailable)
+	default:
+		h.logger.Error("Failed to convert prices", zap.Error(err))
+		response.Error(w, "Failed to convert prices", http.StatusInternalServerError)
+	}
+	return false
+}
@@ -6,7 +6,10 @@ import (
 )
 
 type Product struct {
-	ID            uint        `json:"id"`
+	ID uint `json:"id"`
+	// SellerID is the marketplace seller offering the product; 0 means
+	// BAJAR sells it itself.
+	SellerID      uint64      `json:"seller_id" gorm:"index"`
 	Name          string      `json:"name" binding:"required"`
 	Description   string      `json:"description"`
 	Price         float64     `json:"price" binding:"required"`
@@ -22,6 +25,21 @@ type Product struct {
 	Images        []Image     `json:"images,omitempty"`
 	Variants      []Variant   `json:"variants,omitempty"`
 	Attributes    []Attribute `json:"attributes,omitempty"`
+
+	// LocalizedPrice is filled in when a request asks for prices in a
+	// currency other than the catalog's base currency; it is never stored.
+	LocalizedPrice *LocalizedPrice `json:"localized_price,omitempty" gorm:"-"`
+}
+
+// LocalizedPrice is Price/DiscountPrice converted with the exchange-rate
+// snapshot identified by RateID.
+type LocalizedPrice struct {
+	Currency        string    `json:"currency"`
+	Price           string    `json:"price"`
+	DiscountPrice   string    `json:"discount_price,omitempty"`
+	ExchangeRate    string    `json:"exchange_rate"`
+	RateID          uint64    `json:"rate_id,omitempty"`
+	RateEffectiveAt time.Time `json:"rate_effective_at,omitempty"`
 }
 
 type Dimensions struct {
@@ -55,7 +73,10 @@ type Attribute struct {
 	Value     string `json:"value" binding:"required"` // e.g., "Cotton"
 }
 
+// CreateProductRequest.SellerID is only honoured for catalog staff;
+// sellers always create products of their own.
 type CreateProductRequest struct {
+	SellerID      uint64      `json:"seller_id"`
 	Name          string      `json:"name" binding:"required"`
 	Description   string      `json:"description"`
 	Price         float64     `json:"price" binding:"required"`
@@ -86,6 +107,7 @@ type UpdateProductRequest struct {
 
 type ProductResponse struct {
 	ID            uint        `json:"id"`
+	SellerID      uint64      `json:"seller_id"`
 	Name          string      `json:"name"`
 	Description   string      `json:"description"`
 	Price         float64     `json:"price"`
@@ -14,6 +14,7 @@ type ProductRepository interface {
 	Delete(id uint) error
 	FindBySlug(slug string) (*models.Product, error)
 	FindBySKU(sku string) (*models.Product, error)
+	FindBySeller(sellerID uint64) ([]models.Product, error)
 	Search(query string, minPrice, maxPrice float64, status string, page, limit int) ([]models.Product, error)
 	GetProductImages(productID uint) ([]models.Image, error)
 	GetProductVariants(productID uint) ([]models.Variant, error)
@@ -67,6 +68,13 @@ func (r *productRepository) FindBySKU(sku string) (*models.Product, error) {
 	return &product, err
 }
 
+func (r *productRepository) FindBySeller(sellerID uint64) ([]models.Product, error) {
+	var products []models.Product
+	err := r.db.Preload("Images").Preload("Variants").Preload("Attributes").
+		Where("seller_id = ?", sellerID).Order("id").Find(&products).Error
+	return products, err
+}
+
 func (r *productRepository) Search(query string, minPrice, maxPrice float64, status string, page, limit int) ([]models.Product, error) {
 	var products []models.Product
 	db := r.db.Preload("Images").Preload("Variants").Preload("Attributes")
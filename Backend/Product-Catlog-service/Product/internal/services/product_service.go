@@ -1,8 +1,11 @@
 package services
 
 import (
+	"strings"
+
 	"github.com/gajare/BAJAR-App/Backend/Product-Catalog-Service/Product/internal/models"
 	"github.com/gajare/BAJAR-App/Backend/Product-Catalog-Service/Product/internal/repository"
+	"github.com/gajare/BAJAR-App/Backend/Product-Catalog-Service/pkg/currency"
 )
 
 type ProductService interface {
@@ -15,14 +18,17 @@ type ProductService interface {
 	GetProductImages(productID uint) ([]models.Image, error)
 	GetProductVariants(productID uint) ([]models.Variant, error)
 	GetProductBySlug(slug string) (*models.Product, error)
+	GetSellerProducts(sellerID uint64) ([]models.Product, error)
+	LocalizePrices(products []models.Product, currencyCode string) error
 }
 
 type productService struct {
-	repo repository.ProductRepository
+	repo      repository.ProductRepository
+	converter currency.Converter
 }
 
-func NewProductService(repo repository.ProductRepository) ProductService {
-	return &productService{repo: repo}
+func NewProductService(repo repository.ProductRepository, converter currency.Converter) ProductService {
+	return &productService{repo: repo, converter: converter}
 }
 
 func (s *productService) GetAllProducts() ([]models.Product, error) {
@@ -35,6 +41,7 @@ func (s *productService) GetProduct(id uint) (*models.Product, error) {
 
 func (s *productService) CreateProduct(request models.CreateProductRequest) (*models.Product, error) {
 	product := &models.Product{
+		SellerID:      request.SellerID,
 		Name:          request.Name,
 		Description:   request.Description,
 		Price:         request.Price,
@@ -128,3 +135,37 @@ func (s *productService) GetProductVariants(productID uint) ([]models.Variant, e
 func (s *productService) GetProductBySlug(slug string) (*models.Product, error) {
 	return s.repo.FindBySlug(slug)
 }
+
+func (s *productService) GetSellerProducts(sellerID uint64) ([]models.Product, error) {
+	return s.repo.FindBySeller(sellerID)
+}
+
+// LocalizePrices attaches prices in the requested currency to each product.
+// Asking for the base currency (or none) leaves the products untouched.
+func (s *productService) LocalizePrices(products []models.Product, currencyCode string) error {
+	if currencyCode == "" || strings.EqualFold(currencyCode, s.converter.BaseCurrency()) {
+		return nil
+	}
+	for i := range products {
+		price, err := s.converter.Convert(products[i].Price, currencyCode)
+		if err != nil {
+			return err
+		}
+		localized := &models.LocalizedPrice{
+			Currency:        price.Currency,
+			Price:           price.Amount,
+			ExchangeRate:    price.Rate.Rate,
+			RateID:          price.Rate.ID,
+			RateEffectiveAt: price.Rate.EffectiveAt,
+		}
+		if products[i].DiscountPrice > 0 {
+			discount, err := s.converter.Convert(products[i].DiscountPrice, currencyCode)
+			if err != nil {
+				return err
+			}
+			localized.DiscountPrice = discount.Amount
+		}
+		products[i].LocalizedPrice = localized
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ module github.com/gajare/BAJAR-App/Backend/Product-Catalog-Service
 go 1.25.1
 
 require (
+	github.com/gajare/BAJAR-App/Backend/pkg v0.0.0
 	github.com/gorilla/mux v1.8.1
 	github.com/jinzhu/gorm v1.9.16
 	github.com/lib/pq v1.10.9
@@ -10,6 +11,9 @@ require (
 )
 
 require (
+	github.com/golang-jwt/jwt/v4 v4.5.2 // indirect
 	github.com/jinzhu/inflection v1.0.0 // indirect
 	go.uber.org/multierr v1.10.0 // indirect
 )
+
+replace github.com/gajare/BAJAR-App/Backend/pkg => ../pkg
@@ -0,0 +1,212 @@
+// Package currency converts catalog prices, which are kept in the base
+// currency, into the currency a shopper asks for. Rates come from the
+// Payment-service exchange-rate store so both services price identically.
+package currency
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUnsupportedCurrency = errors.New("unsupported currency")
+	ErrRatesUnavailable    = errors.New("exchange rates unavailable")
+)
+
+// minorUnits lists the currencies the catalog can display prices in.
+var minorUnits = map[string]int{"INR": 2, "USD": 2, "EUR": 2, "GBP": 2, "AED": 2, "JPY": 0}
+
+type Rate struct {
+	ID          uint64    `json:"id"`
+	Currency    string    `json:"currency"`
+	Rate        string    `json:"rate"`
+	EffectiveAt time.Time `json:"effective_at"`
+}
+
+// Converted is a price expressed in another currency together with the
+// rate snapshot used, so a later payment can be checked against it.
+type Converted struct {
+	Currency string
+	Amount   string
+	Rate     Rate
+}
+
+type Converter interface {
+	BaseCurrency() string
+	Convert(amount float64, to string) (Converted, error)
+}
+
+// retryAfter is how long the client waits after a failed refresh before
+// it asks the Payment-service again.
+const retryAfter = 30 * time.Second
+
+// Client fetches the latest rates from the Payment-service and caches
+// them for ttl.
+type Client struct {
+	url  string
+	base string
+	ttl  time.Duration
+	http *http.Client
+
+	mu      sync.Mutex
+	rates   map[string]Rate // replaced, never modified
+	fetched time.Time
+	// After a failed refresh, retryAt holds the next attempt back and
+	// lastErr says what went wrong.
+	retryAt time.Time
+	lastErr error
+	// refreshing is closed when the refresh in flight ends.
+	refreshing chan struct{}
+}
+
+func NewClient(url, base string, ttl time.Duration) *Client {
+	return &Client{
+		url:  url,
+		base: strings.ToUpper(base),
+		ttl:  ttl,
+		http: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (c *Client) BaseCurrency() string {
+	return c.base
+}
+
+func (c *Client) Convert(amount float64, to string) (Converted, error) {
+	to = strings.ToUpper(strings.TrimSpace(to))
+	digits, ok := minorUnits[to]
+	if !ok {
+		return Converted{}, fmt.Errorf("%w: %s", ErrUnsupportedCurrency, to)
+	}
+
+	// Go through the shortest decimal representation of the float so a
+	// price stored as 499.99 is treated as exactly 499.99.
+	value, ok := new(big.Rat).SetString(strconv.FormatFloat(amount, 'f', -1, 64))
+	if !ok {
+		return Converted{}, fmt.Errorf("invalid price %v", amount)
+	}
+
+	rate := Rate{Currency: to, Rate: "1"}
+	if to != c.base {
+		var err error
+		if rate, err = c.rate(to); err != nil {
+			return Converted{}, err
+		}
+		r, ok := new(big.Rat).SetString(rate.Rate)
+		if !ok || r.Sign() <= 0 {
+			return Converted{}, fmt.Errorf("%w: bad rate %q for %s", ErrRatesUnavailable, rate.Rate, to)
+		}
+		// Rates are base units per unit of the foreign currency.
+		value.Quo(value, r)
+	}
+	return Converted{Currency: to, Amount: formatHalfEven(value, digits), Rate: rate}, nil
+}
+
+func (c *Client) rate(currency string) (Rate, error) {
+	rates, err := c.current()
+	if err != nil {
+		return Rate{}, err
+	}
+	rate, ok := rates[currency]
+	if !ok {
+		return Rate{}, fmt.Errorf("%w: no rate for %s", ErrRatesUnavailable, currency)
+	}
+	return rate, nil
+}
+
+// current returns the cached rates, refreshing them first once they are
+// stale. Only one caller refreshes at a time, and not while holding mu:
+// the others carry on with the stale rates, or wait if there are none
+// yet. On failure the previous rates are kept so a Payment-service outage
+// does not take pricing down with it.
+func (c *Client) current() (map[string]Rate, error) {
+	c.mu.Lock()
+	now := time.Now()
+	if (c.rates != nil && now.Sub(c.fetched) <= c.ttl) || now.Before(c.retryAt) {
+		rates, err := c.rates, c.lastErr
+		c.mu.Unlock()
+		if rates == nil {
+			return nil, fmt.Errorf("%w: %v", ErrRatesUnavailable, err)
+		}
+		return rates, nil
+	}
+	if wait := c.refreshing; wait != nil {
+		rates := c.rates
+		c.mu.Unlock()
+		if rates != nil {
+			return rates, nil
+		}
+		<-wait
+		return c.current()
+	}
+	done := make(chan struct{})
+	c.refreshing = done
+	c.mu.Unlock()
+
+	fetched, err := c.fetch()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err == nil {
+		c.rates, c.fetched, c.retryAt, c.lastErr = fetched, time.Now(), time.Time{}, nil
+	} else {
+		c.retryAt, c.lastErr = time.Now().Add(retryAfter), err
+	}
+	c.refreshing = nil
+	close(done)
+	if c.rates == nil {
+		return nil, fmt.Errorf("%w: %v", ErrRatesUnavailable, err)
+	}
+	return c.rates, nil
+}
+
+// fetch gets the latest rates from the Payment-service.
+func (c *Client) fetch() (map[string]Rate, error) {
+	resp, err := c.http.Get(c.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", c.url, resp.Status)
+	}
+	var body struct {
+		Base  string `json:"base"`
+		Rates []Rate `json:"rates"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(body.Base, c.base) {
+		return nil, fmt.Errorf("rates are based on %s, catalog prices are in %s", body.Base, c.base)
+	}
+	rates := make(map[string]Rate, len(body.Rates))
+	for _, r := range body.Rates {
+		rates[strings.ToUpper(r.Currency)] = r
+	}
+	return rates, nil
+}
+
+// formatHalfEven rounds v to the given number of decimals, half to even,
+// and formats it without going through float64.
+func formatHalfEven(v *big.Rat, digits int) string {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(digits)), nil)
+	scaled := new(big.Rat).Mul(v, new(big.Rat).SetInt(scale))
+	q, rem := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+	twice := new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2))
+	if c := twice.Cmp(scaled.Denom()); c > 0 || (c == 0 && q.Bit(0) == 1) {
+		if scaled.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return new(big.Rat).SetFrac(q, scale).FloatString(digits)
+}
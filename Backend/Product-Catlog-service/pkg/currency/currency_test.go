@@ -0,0 +1,156 @@
+package currency_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gajare/BAJAR-App/Backend/Product-Catalog-Service/pkg/currency"
+)
+
+// ratesServer serves the Payment-service's latest-rates response and
+// counts the requests; fail makes it answer 503.
+type ratesServer struct {
+	*httptest.Server
+	hits  atomic.Int32
+	fail  atomic.Bool
+	delay time.Duration
+}
+
+func newRatesServer(t *testing.T, body string) *ratesServer {
+	s := &ratesServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.hits.Add(1)
+		time.Sleep(s.delay)
+		if s.fail.Load() {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+const inrRates = `{"base":"INR","rates":[{"id":1,"currency":"USD","rate":"2"},{"id":2,"currency":"JPY","rate":"0.5"}]}`
+
+func TestConvertRoundsHalfToEven(t *testing.T) {
+	srv := newRatesServer(t, inrRates)
+	c := currency.NewClient(srv.URL, "inr", time.Minute)
+
+	cases := []struct {
+		amount float64
+		to     string
+		want   string
+	}{
+		{100, "USD", "50.00"},
+		{0.01, "usd", "0.00"}, // 0.005 rounds to the even 0.00
+		{0.03, "USD", "0.02"}, // 0.015 rounds to the even 0.02
+		{1.25, "JPY", "2"},    // 2.5
+		{1.75, "JPY", "4"},    // 3.5
+		{499.99, "INR", "499.99"},
+	}
+	for _, tc := range cases {
+		got, err := c.Convert(tc.amount, tc.to)
+		if err != nil {
+			t.Fatalf("Convert(%v, %s): %v", tc.amount, tc.to, err)
+		}
+		if got.Amount != tc.want {
+			t.Errorf("Convert(%v, %s) = %s, want %s", tc.amount, tc.to, got.Amount, tc.want)
+		}
+	}
+	if _, err := c.Convert(1, "XYZ"); !errors.Is(err, currency.ErrUnsupportedCurrency) {
+		t.Errorf("expected unsupported currency, got %v", err)
+	}
+	if _, err := c.Convert(1, "GBP"); !errors.Is(err, currency.ErrRatesUnavailable) {
+		t.Errorf("expected a missing rate to be unavailable, got %v", err)
+	}
+}
+
+func TestRatesAreCached(t *testing.T) {
+	srv := newRatesServer(t, inrRates)
+	c := currency.NewClient(srv.URL, "INR", time.Minute)
+
+	for i := 0; i < 3; i++ {
+		got, err := c.Convert(10, "USD")
+		if err != nil || got.Rate.ID != 1 {
+			t.Fatalf("Convert = %+v, %v", got, err)
+		}
+	}
+	if _, err := c.Convert(10, "INR"); err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.hits.Load(); n != 1 {
+		t.Errorf("%d fetches, want 1", n)
+	}
+}
+
+func TestConcurrentRequestsShareOneRefresh(t *testing.T) {
+	srv := newRatesServer(t, inrRates)
+	srv.delay = 50 * time.Millisecond
+	c := currency.NewClient(srv.URL, "INR", time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.Convert(10, "USD"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := srv.hits.Load(); n != 1 {
+		t.Errorf("%d fetches, want 1", n)
+	}
+}
+
+func TestFailedRefreshBacksOff(t *testing.T) {
+	srv := newRatesServer(t, inrRates)
+	srv.fail.Store(true)
+	c := currency.NewClient(srv.URL, "INR", time.Minute)
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.Convert(10, "USD"); !errors.Is(err, currency.ErrRatesUnavailable) {
+			t.Fatalf("expected rates to be unavailable, got %v", err)
+		}
+	}
+	if n := srv.hits.Load(); n != 1 {
+		t.Errorf("%d fetches while backing off, want 1", n)
+	}
+}
+
+func TestStaleRatesOutliveAnOutage(t *testing.T) {
+	srv := newRatesServer(t, inrRates)
+	c := currency.NewClient(srv.URL, "INR", time.Millisecond)
+	if _, err := c.Convert(10, "USD"); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.fail.Store(true)
+	time.Sleep(5 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		got, err := c.Convert(10, "USD")
+		if err != nil || got.Amount != "5.00" {
+			t.Fatalf("Convert during outage = %+v, %v", got, err)
+		}
+	}
+	if n := srv.hits.Load(); n != 2 {
+		t.Errorf("%d fetches, want one failed refresh and then a back-off", n)
+	}
+}
+
+func TestRatesInAnotherBaseAreRejected(t *testing.T) {
+	srv := newRatesServer(t, `{"base":"USD","rates":[{"currency":"INR","rate":"0.012"}]}`)
+	c := currency.NewClient(srv.URL, "INR", time.Minute)
+	if _, err := c.Convert(10, "USD"); !errors.Is(err, currency.ErrRatesUnavailable) {
+		t.Errorf("expected a base mismatch to be unavailable, got %v", err)
+	}
+}
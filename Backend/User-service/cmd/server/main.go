@@ -1,33 +1,158 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
+	"os"
+	"time"
 
+	"github.com/gajare/BAJAR-App/Backend/pkg/authz"
 	"github.com/gorilla/mux"
 	"user-service/config"
 	"user-service/controller"
 	"user-service/db"
+	"user-service/events"
+	"user-service/mailer"
 	"user-service/middleware"
+	"user-service/oidc"
+	"user-service/ratelimit"
+	"user-service/service"
+	"user-service/utils"
 )
 
 func main() {
 
 	cfg := config.Load()
 	db.InitDB(cfg.DatabaseDSN)
+	if cfg.BootstrapAdminEmail != "" {
+		db.PromoteToAdmin(cfg.BootstrapAdminEmail)
+	}
+	if err := service.RotateSigningKeys(cfg, time.Now()); err != nil {
+		log.Fatalf("failed to load signing keys: %v", err)
+	}
+	go service.RunKeyRotation(context.Background(), cfg, cfg.KeyCheckInterval)
+	if err := utils.SetPasswordHashing(cfg.PasswordHash, cfg.BcryptCost); err != nil {
+		log.Fatalf("invalid password hash settings: %v", err)
+	}
+	if cfg.BreachedPasswordsDir != "" {
+		if _, err := os.Stat(cfg.BreachedPasswordsDir); err != nil {
+			log.Fatalf("breached password list: %v", err)
+		}
+	}
+	controller.Init(cfg)
+	mailer.Default = mailer.New(cfg.Mailer, cfg.MailDir)
+	events.Default = events.New(cfg.EventPublisher, cfg.EventWebhookURLs, cfg.EventWebhookSecret)
+	go service.RunOutboxRelay(context.Background(), cfg.EventRelayInterval)
+
+	if err := utils.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("invalid TRUSTED_PROXIES: %v", err)
+	}
+	policies, err := ratelimit.ParsePolicies(cfg.RateLimits)
+	if err != nil {
+		log.Fatalf("invalid RATE_LIMITS: %v", err)
+	}
+	var store ratelimit.Store
+	if cfg.RateLimitStore == "redis" {
+		if store, err = ratelimit.NewRedisStore(cfg.RedisURL); err != nil {
+			log.Fatalf("invalid REDIS_URL: %v", err)
+		}
+	} else {
+		memory := ratelimit.NewMemoryStore()
+		go memory.Run(context.Background(), time.Minute)
+		store = memory
+	}
+	middleware.SetRateLimits(store, policies)
 
 	r := mux.NewRouter()
 	r.Use(middleware.RateLimitMiddleware)
 
+	for _, p := range cfg.OIDCProviders {
+		controller.InitOIDC(oidc.NewProvider(p.Name, p.Issuer, p.ClientID, p.ClientSecret, cfg.AppBaseURL+"/oidc/"+p.Name+"/callback"))
+	}
+	if cfg.OIDCDevProvider {
+		dev, err := oidc.NewDevProvider(cfg.AppBaseURL + "/dev-oidc")
+		if err != nil {
+			log.Fatalf("failed to start dev oidc provider: %v", err)
+		}
+		r.PathPrefix("/dev-oidc/").Handler(http.StripPrefix("/dev-oidc", dev))
+		controller.InitOIDC(oidc.NewProvider("dev", dev.Issuer, "user-service", "", cfg.AppBaseURL+"/oidc/dev/callback"))
+		log.Printf("dev OIDC provider enabled; anyone can sign in as anyone")
+	}
+
 	// public
+	r.HandleFunc("/.well-known/jwks.json", controller.JWKS).Methods("GET")
 	r.HandleFunc("/register", controller.Register).Methods("POST")
 	r.HandleFunc("/login", controller.Login).Methods("POST")
+	r.HandleFunc("/login/2fa", controller.LoginMFA).Methods("POST")
+	r.HandleFunc("/verify-email", controller.VerifyEmail).Methods("GET")
+	r.HandleFunc("/confirm-email-change", controller.ConfirmEmailChange).Methods("GET")
+	r.HandleFunc("/resend-verification", controller.ResendVerification).Methods("POST")
+	r.HandleFunc("/password/forgot", controller.ForgotPassword).Methods("POST")
+	r.HandleFunc("/password/reset", controller.ResetPassword).Methods("POST")
+	r.HandleFunc("/token/refresh", controller.RefreshToken).Methods("POST")
+	r.HandleFunc("/oidc/{provider}/login", controller.OIDCLogin).Methods("GET")
+	r.HandleFunc("/oidc/{provider}/callback", controller.OIDCCallback).Methods("GET")
+	r.Handle("/logout", middleware.JwtAuthMiddleware(http.HandlerFunc(controller.Logout))).Methods("POST")
+	r.HandleFunc("/oauth/token", controller.ServiceToken).Methods("POST")
+
+	// services as well as users; registered before /api so it matches first
+	r.Handle("/api/users/{id:[0-9]+}/addresses/{addressID:[0-9]+}/snapshot", middleware.ServiceOrUserAuth(http.HandlerFunc(controller.GetAddressSnapshot))).Methods("GET")
 
 	// protected
 	auth := r.PathPrefix("/api").Subrouter()
 	auth.Use(middleware.JwtAuthMiddleware)
+	if cfg.RequireVerifiedEmail {
+		auth.Use(middleware.RequireVerifiedEmail)
+	}
 	auth.HandleFunc("/me", controller.GetProfile).Methods("GET")
-	auth.HandleFunc("/users", controller.ListUsers).Methods("GET")
+	auth.HandleFunc("/me", controller.UpdateProfile).Methods("PATCH")
+	auth.HandleFunc("/me", controller.DeleteAccount).Methods("DELETE")
+	auth.HandleFunc("/me/password", controller.ChangePassword).Methods("POST")
+	auth.HandleFunc("/me/email", controller.ChangeEmail).Methods("POST")
+	auth.HandleFunc("/me/sessions", controller.ListSessions).Methods("GET")
+	auth.HandleFunc("/me/sessions", controller.SignOutEverywhere).Methods("DELETE")
+	auth.HandleFunc("/me/sessions/{id:[0-9]+}", controller.RevokeSession).Methods("DELETE")
+	auth.HandleFunc("/me/logins", controller.ListLogins).Methods("GET")
+	auth.HandleFunc("/me/addresses", controller.ListAddresses).Methods("GET")
+	auth.HandleFunc("/me/addresses", controller.CreateAddress).Methods("POST")
+	auth.HandleFunc("/me/addresses/{id:[0-9]+}", controller.GetAddress).Methods("GET")
+	auth.HandleFunc("/me/addresses/{id:[0-9]+}", controller.UpdateAddress).Methods("PUT")
+	auth.HandleFunc("/me/addresses/{id:[0-9]+}", controller.DeleteAddress).Methods("DELETE")
+	auth.HandleFunc("/me/seller", controller.GetMySeller).Methods("GET")
+	auth.HandleFunc("/sellers", controller.CreateSeller).Methods("POST")
+	auth.Handle("/sellers", authz.Require(authz.PermSellersReview)(http.HandlerFunc(controller.ListSellers))).Methods("GET")
+	auth.HandleFunc("/sellers/{id:[0-9]+}", controller.GetSeller).Methods("GET")
+	auth.HandleFunc("/sellers/{id:[0-9]+}", controller.UpdateSeller).Methods("PUT")
+	auth.HandleFunc("/sellers/{id:[0-9]+}/members", controller.AddSellerMember).Methods("POST")
+	auth.HandleFunc("/sellers/{id:[0-9]+}/members/{userID:[0-9]+}", controller.UpdateSellerMember).Methods("PUT")
+	auth.HandleFunc("/sellers/{id:[0-9]+}/members/{userID:[0-9]+}", controller.RemoveSellerMember).Methods("DELETE")
+	auth.Handle("/sellers/{id:[0-9]+}/kyc", authz.Require(authz.PermSellersReview)(http.HandlerFunc(controller.ReviewSeller))).Methods("POST")
+	auth.Handle("/sellers/{id:[0-9]+}/suspend", authz.Require(authz.PermSellersReview)(http.HandlerFunc(controller.SuspendSeller))).Methods("POST")
+	auth.Handle("/sellers/{id:[0-9]+}/reactivate", authz.Require(authz.PermSellersReview)(http.HandlerFunc(controller.ReactivateSeller))).Methods("POST")
+	auth.HandleFunc("/identities", controller.ListIdentities).Methods("GET")
+	auth.HandleFunc("/identities/{id:[0-9]+}", controller.DeleteIdentity).Methods("DELETE")
+	auth.HandleFunc("/oidc/{provider}/link", controller.OIDCLink).Methods("POST")
+	auth.HandleFunc("/2fa/totp", controller.EnrollTOTP).Methods("POST")
+	auth.HandleFunc("/2fa/totp/confirm", controller.ConfirmTOTP).Methods("POST")
+	auth.HandleFunc("/2fa/totp/disable", controller.DisableTOTP).Methods("POST")
+	auth.HandleFunc("/2fa/recovery-codes", controller.RegenerateRecoveryCodes).Methods("POST")
+	auth.Handle("/users", authz.Require(authz.PermUsersRead)(http.HandlerFunc(controller.ListUsers))).Methods("GET")
+	auth.Handle("/users/{id:[0-9]+}", authz.Require(authz.PermUsersRead)(http.HandlerFunc(controller.GetUser))).Methods("GET")
+	auth.Handle("/users/{id:[0-9]+}/sessions", authz.Require(authz.PermUsersRead)(http.HandlerFunc(controller.ListUserSessions))).Methods("GET")
+	auth.Handle("/users/{id:[0-9]+}/logins", authz.Require(authz.PermUsersRead)(http.HandlerFunc(controller.ListUserLogins))).Methods("GET")
+	auth.Handle("/users/{id:[0-9]+}/role", authz.Require(authz.PermUsersWrite)(http.HandlerFunc(controller.UpdateUserRole))).Methods("PUT")
+	auth.Handle("/users/{id:[0-9]+}/mfa-required", authz.Require(authz.PermUsersWrite)(http.HandlerFunc(controller.SetMFARequired))).Methods("PUT")
+	auth.Handle("/users/{id:[0-9]+}/2fa", authz.Require(authz.PermUsersWrite)(http.HandlerFunc(controller.ResetTOTP))).Methods("DELETE")
+	auth.Handle("/users/{id:[0-9]+}/unlock", authz.Require(authz.PermUsersWrite)(http.HandlerFunc(controller.UnlockUser))).Methods("POST")
+	auth.Handle("/users/{id:[0-9]+}/suspend", authz.Require(authz.PermUsersWrite)(http.HandlerFunc(controller.SuspendUser))).Methods("POST")
+	auth.Handle("/users/{id:[0-9]+}/reactivate", authz.Require(authz.PermUsersWrite)(http.HandlerFunc(controller.ReactivateUser))).Methods("POST")
+	auth.Handle("/users/{id:[0-9]+}/password-reset", authz.Require(authz.PermUsersWrite)(http.HandlerFunc(controller.ForcePasswordReset))).Methods("POST")
+	auth.Handle("/service-clients", authz.Require(authz.PermClientsWrite)(http.HandlerFunc(controller.ListServiceClients))).Methods("GET")
+	auth.Handle("/service-clients", authz.Require(authz.PermClientsWrite)(http.HandlerFunc(controller.CreateServiceClient))).Methods("POST")
+	auth.Handle("/service-clients/{id:[0-9]+}/secret", authz.Require(authz.PermClientsWrite)(http.HandlerFunc(controller.RotateServiceClientSecret))).Methods("POST")
+	auth.Handle("/service-clients/{id:[0-9]+}", authz.Require(authz.PermClientsWrite)(http.HandlerFunc(controller.RevokeServiceClient))).Methods("DELETE")
+	auth.Handle("/audit-log", authz.Require(authz.PermAuditRead)(http.HandlerFunc(controller.ListAuditLog))).Methods("GET")
 
 	log.Printf("Server running on :%s", cfg.Port)
 	log.Fatal(http.ListenAndServe(":"+cfg.Port, r))
@@ -1,19 +1,199 @@
 package config
 
-import "os"
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type Config struct {
 	DatabaseDSN string
-	JWTSecret   string
 	Port        string
+
+	// JWTAlgorithm is RS256 or EdDSA. Keys are rotated every
+	// KeyRotationInterval; a new key is published KeyPublishAhead before it
+	// starts signing, which must be longer than the JWKS cache of the other
+	// services. KeyCheckInterval is how often rotation runs.
+	JWTAlgorithm        string
+	KeyRotationInterval time.Duration
+	KeyPublishAhead     time.Duration
+	KeyCheckInterval    time.Duration
+
+	// AccessTokenTTL is the lifetime of access tokens; RefreshTokenTTL that
+	// of the refresh tokens used to get new ones. ServiceTokenTTL is the
+	// lifetime of tokens issued to service clients.
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	ServiceTokenTTL time.Duration
+
+	// AppBaseURL is where links in emails point, e.g. the verification link.
+	AppBaseURL string
+	// VerificationTTL is how long an email verification link stays valid.
+	VerificationTTL time.Duration
+	// RequireVerifiedEmail makes login and the protected routes reject
+	// users who have not verified their email address yet.
+	RequireVerifiedEmail bool
+	// PasswordResetTTL is how long a password reset link stays valid.
+	// PasswordResetURL is the frontend page the link opens; the token is
+	// added as ?token=, and the page posts it to /password/reset.
+	PasswordResetTTL time.Duration
+	PasswordResetURL string
+
+	// MFARequiredRoles must use two-factor authentication; until they do,
+	// their tokens carry none of the role's permissions. MFAChallengeTTL is
+	// how long Login's second-step challenge stays valid. TOTPIssuer names
+	// the service in authenticator apps.
+	MFARequiredRoles []string
+	MFAChallengeTTL  time.Duration
+	TOTPIssuer       string
+
+	// ReauthMaxAge is how recent the session's sign-in must be for users
+	// without a password to change their email or password or delete the
+	// account; users with one confirm it instead.
+	ReauthMaxAge time.Duration
+
+	// New passwords need PasswordMinLength characters from at least
+	// PasswordMinClasses character classes (lowercase, uppercase, digits,
+	// symbols) and must not contain the user's name or email. When
+	// BreachedPasswordsDir is set they are also looked up in the breached
+	// password range files there (see utils.BreachList).
+	PasswordMinLength    int
+	PasswordMinClasses   int
+	BreachedPasswordsDir string
+	// PasswordHash is bcrypt or argon2id, BcryptCost the cost of new
+	// bcrypt hashes. Hashes made otherwise are redone at the next login.
+	PasswordHash string
+	BcryptCost   int
+
+	// LoginLockAfter failed logins for one email lock it for LoginLockout;
+	// from the third failure on, each attempt has to wait longer. Client
+	// addresses get five times the allowance, as many users can share one.
+	LoginLockAfter int
+	LoginLockout   time.Duration
+
+	// RateLimits are the request rate policies, "METHOD PATH N/period
+	// [ip|user|apikey]" separated by ";" (see ratelimit.ParsePolicies);
+	// apikey counts by the client of a verified service token.
+	// RateLimitStore is "memory", per instance, or "redis", shared by all
+	// instances through RedisURL. X-Forwarded-For is only believed from
+	// TrustedProxies (CIDRs or addresses).
+	RateLimits     string
+	RateLimitStore string
+	RedisURL       string
+	TrustedProxies []string
+
+	// Mailer is "console" (log messages) or "file" (write them to MailDir).
+	Mailer  string
+	MailDir string
+
+	// EventPublisher is "log" or "webhook" (POST to EventWebhookURLs,
+	// signed with EventWebhookSecret). The outbox relay runs every
+	// EventRelayInterval.
+	EventPublisher     string
+	EventWebhookURLs   []string
+	EventWebhookSecret string
+	EventRelayInterval time.Duration
+
+	// OIDCProviders are the external sign-in providers, configured with
+	// OIDC_PROVIDERS=google,... and OIDC_<NAME>_ISSUER, _CLIENT_ID and
+	// _CLIENT_SECRET. OIDCDevProvider serves a stand-in provider named
+	// "dev" under /dev-oidc that signs in anyone; development only.
+	OIDCProviders   []OIDCProvider
+	OIDCDevProvider bool
+
+	// BootstrapAdminEmail, when set, names an existing account that is
+	// promoted to admin at startup so the first admin can be created.
+	BootstrapAdminEmail string
 }
 
 func Load() *Config {
 	return &Config{
 		DatabaseDSN: getEnv("DATABASE_DSN", "host=localhost user=postgres password=1234 dbname=users port=5432 sslmode=disable TimeZone=UTC"),
-		JWTSecret:   getEnv("JWT_SECRET", "supersecret"),
 		Port:        getEnv("PORT", "8080"),
+
+		JWTAlgorithm:        getEnv("JWT_ALGORITHM", "RS256"),
+		KeyRotationInterval: getDuration("KEY_ROTATION_INTERVAL", 30*24*time.Hour),
+		KeyPublishAhead:     getDuration("KEY_PUBLISH_AHEAD", time.Hour),
+		KeyCheckInterval:    getDuration("KEY_CHECK_INTERVAL", 5*time.Minute),
+
+		AccessTokenTTL:  getDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL: getDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		ServiceTokenTTL: getDuration("SERVICE_TOKEN_TTL", 5*time.Minute),
+
+		AppBaseURL:           getEnv("APP_BASE_URL", "http://localhost:8080"),
+		VerificationTTL:      getDuration("VERIFICATION_TTL", 24*time.Hour),
+		RequireVerifiedEmail: getBool("REQUIRE_VERIFIED_EMAIL", false),
+		PasswordResetTTL:     getDuration("PASSWORD_RESET_TTL", time.Hour),
+		PasswordResetURL:     getEnv("PASSWORD_RESET_URL", "http://localhost:3000/reset-password"),
+
+		MFARequiredRoles: strings.Split(getEnv("MFA_REQUIRED_ROLES", "admin,support,catalog_manager,finance"), ","),
+		MFAChallengeTTL:  getDuration("MFA_CHALLENGE_TTL", 5*time.Minute),
+		ReauthMaxAge:     getDuration("REAUTH_MAX_AGE", 5*time.Minute),
+		TOTPIssuer:       getEnv("TOTP_ISSUER", "BAJAR"),
+
+		PasswordMinLength:    getInt("PASSWORD_MIN_LENGTH", 8),
+		PasswordMinClasses:   getInt("PASSWORD_MIN_CLASSES", 1),
+		BreachedPasswordsDir: os.Getenv("BREACHED_PASSWORDS_DIR"),
+		PasswordHash:         getEnv("PASSWORD_HASH", "bcrypt"),
+		BcryptCost:           getInt("BCRYPT_COST", 12),
+
+		LoginLockAfter: getInt("LOGIN_LOCK_AFTER", 10),
+		LoginLockout:   getDuration("LOGIN_LOCKOUT", 15*time.Minute),
+
+		RateLimits:     getEnv("RATE_LIMITS", "* * 5/5s ip; * /api/* 120/m user"),
+		RateLimitStore: getEnv("RATE_LIMIT_STORE", "memory"),
+		RedisURL:       getEnv("REDIS_URL", "redis://localhost:6379/0"),
+		TrustedProxies: splitList(os.Getenv("TRUSTED_PROXIES")),
+
+		Mailer:  getEnv("MAILER", "console"),
+		MailDir: getEnv("MAIL_DIR", "mail"),
+
+		EventPublisher:     getEnv("EVENT_PUBLISHER", "log"),
+		EventWebhookURLs:   splitList(os.Getenv("EVENT_WEBHOOK_URLS")),
+		EventWebhookSecret: os.Getenv("EVENT_WEBHOOK_SECRET"),
+		EventRelayInterval: getDuration("EVENT_RELAY_INTERVAL", 5*time.Second),
+
+		OIDCProviders:   oidcProviders(),
+		OIDCDevProvider: getBool("OIDC_DEV_PROVIDER", false),
+
+		BootstrapAdminEmail: os.Getenv("BOOTSTRAP_ADMIN_EMAIL"),
+	}
+}
+
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
 	}
+	return out
+}
+
+func oidcProviders() []OIDCProvider {
+	var providers []OIDCProvider
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		providers = append(providers, OIDCProvider{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+		})
+	}
+	return providers
 }
 
 func getEnv(key, fallback string) string {
@@ -22,3 +202,24 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+func getDuration(key string, fallback time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return d
+	}
+	return fallback
+}
+
+func getInt(key string, fallback int) int {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return n
+	}
+	return fallback
+}
+
+func getBool(key string, fallback bool) bool {
+	if b, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return b
+	}
+	return fallback
+}
@@ -0,0 +1,239 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"user-service/db"
+	"user-service/models"
+	"user-service/utils"
+
+	"github.com/gajare/BAJAR-App/Backend/pkg/authz"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const maxAddresses = 20
+
+var errTooManyAddresses = errors.New("address book is full")
+
+type addressInput struct {
+	Label           string `json:"label"`
+	RecipientName   string `json:"recipient_name"`
+	Phone           string `json:"phone"`
+	Line1           string `json:"line1"`
+	Line2           string `json:"line2"`
+	City            string `json:"city"`
+	State           string `json:"state"`
+	PostalCode      string `json:"postal_code"`
+	Country         string `json:"country"`
+	DefaultShipping bool   `json:"default_shipping"`
+	DefaultBilling  bool   `json:"default_billing"`
+}
+
+// apply validates in and copies it onto a.
+func (in addressInput) apply(a *models.Address) error {
+	trim := strings.TrimSpace
+	if trim(in.RecipientName) == "" || trim(in.Line1) == "" || trim(in.City) == "" {
+		return errors.New("recipient_name, line1 and city are required")
+	}
+	country := in.Country
+	if country == "" {
+		country = "IN"
+	}
+	country, err := utils.NormalizeCountry(country)
+	if err != nil {
+		return err
+	}
+	postal, err := utils.NormalizePostalCode(country, in.PostalCode)
+	if err != nil {
+		return err
+	}
+	phone, err := utils.NormalizePhone(in.Phone)
+	if err != nil {
+		return err
+	}
+	a.Label = truncate(trim(in.Label), 50)
+	a.RecipientName = truncate(trim(in.RecipientName), 100)
+	a.Phone = phone
+	a.Line1 = truncate(trim(in.Line1), 200)
+	a.Line2 = truncate(trim(in.Line2), 200)
+	a.City = truncate(trim(in.City), 100)
+	a.State = truncate(trim(in.State), 100)
+	a.PostalCode = postal
+	a.Country = country
+	return nil
+}
+
+// saveAddress writes a and keeps the defaults consistent: at most one
+// default of each kind, and the first address is both.
+func saveAddress(tx *gorm.DB, a *models.Address, shipping, billing bool) error {
+	// Serialise address book changes per user.
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.User{}, a.UserID).Error; err != nil {
+		return err
+	}
+	if a.ID == 0 {
+		var count int64
+		if err := tx.Model(&models.Address{}).Where("user_id = ?", a.UserID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count >= maxAddresses {
+			return errTooManyAddresses
+		}
+		if count == 0 {
+			shipping, billing = true, true
+		}
+	}
+	if shipping && !a.DefaultShipping {
+		if err := tx.Model(&models.Address{}).Where("user_id = ? AND default_shipping", a.UserID).Update("default_shipping", false).Error; err != nil {
+			return err
+		}
+		a.DefaultShipping = true
+	}
+	if billing && !a.DefaultBilling {
+		if err := tx.Model(&models.Address{}).Where("user_id = ? AND default_billing", a.UserID).Update("default_billing", false).Error; err != nil {
+			return err
+		}
+		a.DefaultBilling = true
+	}
+	return tx.Save(a).Error
+}
+
+func userAddress(r *http.Request) (models.Address, error) {
+	var a models.Address
+	err := db.DB.Where("id = ? AND user_id = ?", mux.Vars(r)["id"], r.Context().Value("userID")).First(&a).Error
+	return a, err
+}
+
+func writeAddressError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errTooManyAddresses):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// ListAddresses returns the current user's address book, defaults first.
+func ListAddresses(w http.ResponseWriter, r *http.Request) {
+	var addresses []models.Address
+	db.DB.Where("user_id = ?", r.Context().Value("userID")).
+		Order("default_shipping DESC, default_billing DESC, id").Find(&addresses)
+	json.NewEncoder(w).Encode(addresses)
+}
+
+func GetAddress(w http.ResponseWriter, r *http.Request) {
+	a, err := userAddress(r)
+	if err != nil {
+		http.Error(w, "address not found", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(a)
+}
+
+func CreateAddress(w http.ResponseWriter, r *http.Request) {
+	var in addressInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	user, err := currentUser(r)
+	if err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	a := models.Address{UserID: user.ID}
+	if err := in.apply(&a); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		return saveAddress(tx, &a, in.DefaultShipping, in.DefaultBilling)
+	})
+	if err != nil {
+		writeAddressError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(a)
+}
+
+// UpdateAddress replaces an address. Defaults can be moved to it but not
+// cleared; set another address as default instead.
+func UpdateAddress(w http.ResponseWriter, r *http.Request) {
+	var in addressInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	a, err := userAddress(r)
+	if err != nil {
+		http.Error(w, "address not found", http.StatusNotFound)
+		return
+	}
+	if err := in.apply(&a); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		return saveAddress(tx, &a, in.DefaultShipping, in.DefaultBilling)
+	})
+	if err != nil {
+		writeAddressError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(a)
+}
+
+// DeleteAddress removes an address. If it was a default, the most recent
+// remaining address takes over.
+func DeleteAddress(w http.ResponseWriter, r *http.Request) {
+	a, err := userAddress(r)
+	if err != nil {
+		http.Error(w, "address not found", http.StatusNotFound)
+		return
+	}
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&a).Error; err != nil {
+			return err
+		}
+		if !a.DefaultShipping && !a.DefaultBilling {
+			return nil
+		}
+		var next models.Address
+		err := tx.Where("user_id = ?", a.UserID).Order("id DESC").First(&next).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return saveAddress(tx, &next, a.DefaultShipping, a.DefaultBilling)
+	})
+	if err != nil {
+		writeAddressError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetAddressSnapshot lets the order service copy an address, including
+// one deleted since it was chosen. It takes the customer's own token
+// (forwarded at checkout), or a staff or service token with orders:read.
+func GetAddressSnapshot(w http.ResponseWriter, r *http.Request) {
+	caller, _ := authz.FromContext(r.Context())
+	if fmt.Sprint(caller.UserID) != mux.Vars(r)["id"] && !caller.Can(authz.PermOrdersRead) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	var a models.Address
+	err := db.DB.Unscoped().Where("id = ? AND user_id = ?", mux.Vars(r)["addressID"], mux.Vars(r)["id"]).First(&a).Error
+	if err != nil {
+		http.Error(w, "address not found", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(a.Snapshot())
+}
@@ -0,0 +1,239 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"user-service/db"
+	"user-service/models"
+
+	"github.com/gajare/BAJAR-App/Backend/pkg/authz"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// Admin actions written to the audit log.
+const (
+	auditRoleChanged        = "user.role_changed"
+	auditSuspended          = "user.suspended"
+	auditReactivated        = "user.reactivated"
+	auditPasswordResetForce = "user.password_reset_forced"
+	auditMFARequired        = "user.mfa_required_changed"
+	auditTOTPReset          = "user.2fa_reset"
+	auditUnlocked           = "user.unlocked"
+)
+
+var errSelfAction = errors.New("you cannot do this to your own account")
+
+// audit records an admin action on the target user within tx.
+func audit(tx *gorm.DB, r *http.Request, action string, targetID uint, details map[string]interface{}) error {
+	caller, _ := authz.FromContext(r.Context())
+	entry := models.AdminAuditLog{ActorID: uint(caller.UserID), Action: action, TargetUserID: targetID, IP: remoteIP(r)}
+	if len(details) > 0 {
+		b, err := json.Marshal(details)
+		if err != nil {
+			return err
+		}
+		entry.Details = string(b)
+	}
+	return tx.Create(&entry).Error
+}
+
+// pageParams reads ?page (from 1) and ?limit (default 20, at most 100).
+func pageParams(r *http.Request) (page, limit int) {
+	page, _ = strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	return page, limit
+}
+
+// likePattern matches s anywhere, with LIKE wildcards in s taken literally.
+func likePattern(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(strings.TrimSpace(s))
+	return "%" + strings.ToLower(s) + "%"
+}
+
+// targetUser loads the user named by the {id} route variable, writing a
+// 404 if there is none.
+func targetUser(w http.ResponseWriter, r *http.Request) (models.User, bool) {
+	var user models.User
+	if err := db.DB.First(&user, mux.Vars(r)["id"]).Error; err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return user, false
+	}
+	return user, true
+}
+
+func isSelf(r *http.Request, user models.User) bool {
+	caller, _ := authz.FromContext(r.Context())
+	return caller.UserID == uint64(user.ID)
+}
+
+// GetUser shows one user with where they are signed in, their linked
+// providers and their recent sign-in attempts.
+func GetUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := targetUser(w, r)
+	if !ok {
+		return
+	}
+	sessions, err := activeSessions(user.ID, 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var identities []models.UserIdentity
+	db.DB.Where("user_id = ?", user.ID).Order("id").Find(&identities)
+	var logins []models.LoginEvent
+	db.DB.Where("user_id = ?", user.ID).Order("id DESC").Limit(20).Find(&logins)
+	var lockedUntil *time.Time
+	var throttle models.LoginThrottle
+	if db.DB.Where("key = ?", emailThrottleKey(user.Email)).First(&throttle).Error == nil &&
+		throttle.LockedUntil != nil && time.Now().Before(*throttle.LockedUntil) {
+		lockedUntil = throttle.LockedUntil
+	}
+	user.Password = ""
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"user":          user,
+		"locked_until":  lockedUntil,
+		"identities":    identities,
+		"sessions":      sessions,
+		"recent_logins": logins,
+	})
+}
+
+// SuspendUser stops a user from signing in and signs them out everywhere
+// until they are reactivated.
+func SuspendUser(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	input.Reason = strings.TrimSpace(input.Reason)
+	if input.Reason == "" {
+		http.Error(w, "reason is required", http.StatusBadRequest)
+		return
+	}
+	user, ok := targetUser(w, r)
+	if !ok {
+		return
+	}
+	if isSelf(r, user) {
+		http.Error(w, errSelfAction.Error(), http.StatusConflict)
+		return
+	}
+	if user.SuspendedAt != nil {
+		http.Error(w, "user is already suspended", http.StatusConflict)
+		return
+	}
+	now := time.Now()
+	reason := truncate(input.Reason, 255)
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{"suspended_at": now, "suspended_reason": reason}).Error; err != nil {
+			return err
+		}
+		if err := signOutEverywhere(tx, user.ID, now); err != nil {
+			return err
+		}
+		return audit(tx, r, auditSuspended, user.ID, map[string]interface{}{"reason": reason})
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	user.Password = ""
+	json.NewEncoder(w).Encode(user)
+}
+
+// ReactivateUser lifts a suspension.
+func ReactivateUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := targetUser(w, r)
+	if !ok {
+		return
+	}
+	if user.SuspendedAt == nil {
+		http.Error(w, "user is not suspended", http.StatusConflict)
+		return
+	}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{"suspended_at": nil, "suspended_reason": ""}).Error; err != nil {
+			return err
+		}
+		return audit(tx, r, auditReactivated, user.ID, nil)
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	user.Password = ""
+	json.NewEncoder(w).Encode(user)
+}
+
+// ForcePasswordReset signs the user out everywhere and stops their
+// current password from working; they are mailed a reset link to set a
+// new one.
+func ForcePasswordReset(w http.ResponseWriter, r *http.Request) {
+	user, ok := targetUser(w, r)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("password_reset_required", true).Error; err != nil {
+			return err
+		}
+		if err := signOutEverywhere(tx, user.ID, now); err != nil {
+			return err
+		}
+		return audit(tx, r, auditPasswordResetForce, user.ID, nil)
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := sendPasswordReset(user); err != nil {
+		// The user can still ask for a link through /password/forgot.
+		log.Printf("forced password reset mail to user %d failed: %v", user.ID, err)
+		http.Error(w, "password reset required, but the reset email could not be sent", http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ListAuditLog shows admin actions, newest first, optionally only those
+// on ?user_id, by ?actor_id or of one ?action.
+func ListAuditLog(w http.ResponseWriter, r *http.Request) {
+	page, limit := pageParams(r)
+	q := db.DB.Model(&models.AdminAuditLog{})
+	if v := r.URL.Query().Get("user_id"); v != "" {
+		q = q.Where("target_user_id = ?", v)
+	}
+	if v := r.URL.Query().Get("actor_id"); v != "" {
+		q = q.Where("actor_id = ?", v)
+	}
+	if v := r.URL.Query().Get("action"); v != "" {
+		q = q.Where("action = ?", v)
+	}
+	var total int64
+	var entries []models.AdminAuditLog
+	if err := q.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := q.Order("id DESC").Offset((page - 1) * limit).Limit(limit).Find(&entries).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"entries": entries, "total": total, "page": page, "limit": limit})
+}
@@ -2,6 +2,7 @@ package controller
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
 	"time"
 	"user-service/db"
@@ -19,15 +20,39 @@ func Register(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "invalid json", http.StatusBadRequest)
 		return
 	}
-	hash, _ := utils.HashPassword(input.Password)
-	user := models.User{Name: input.Name, Email: input.Email, Password: hash}
+	email, err := utils.NormalizeEmail(input.Email)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkNewPassword(input.Password, email, input.Name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var existing int64
+	db.DB.Model(&models.User{}).Where("email = ?", email).Count(&existing)
+	if existing > 0 {
+		http.Error(w, "email already registered", http.StatusConflict)
+		return
+	}
+	hash, err := utils.HashPassword(input.Password)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	user := models.User{Name: input.Name, Email: email, Password: hash}
 	if err := db.DB.Create(&user).Error; err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if err := sendVerificationEmail(user); err != nil {
+		// The account exists either way; the user can ask for a new link.
+		log.Printf("verification email to user %d failed: %v", user.ID, err)
+	}
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"id":    user.ID,
-		"email": user.Email,
+		"id":             user.ID,
+		"email":          user.Email,
+		"email_verified": user.EmailVerified,
 	})
 }
 
@@ -40,17 +65,47 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "invalid json", http.StatusBadRequest)
 		return
 	}
+	email, _ := utils.NormalizeEmail(input.Email)
+	ip, now := remoteIP(r), time.Now()
+	if wait, locked := loginRetryAfter(email, ip, now); wait > 0 {
+		loginFailed(r, nil, email, loginMethodPassword, "throttled")
+		writeRetryAfter(w, wait, locked)
+		return
+	}
 	var user models.User
-	if err := db.DB.Where("email = ?", input.Email).First(&user).Error; err != nil {
+	if err := db.DB.Where("email = ?", email).First(&user).Error; err != nil {
+		recordLoginFailure(email, ip, nil, now)
+		loginFailed(r, nil, email, loginMethodPassword, "unknown account")
 		http.Error(w, "invalid credentials", http.StatusUnauthorized)
 		return
 	}
 	if err := utils.CheckPassword(user.Password, input.Password); err != nil {
+		recordLoginFailure(email, ip, &user, now)
+		loginFailed(r, &user, email, loginMethodPassword, "wrong password")
 		http.Error(w, "invalid credentials", http.StatusUnauthorized)
 		return
 	}
-	token, _ := utils.CreateToken(user.ID, 24*time.Hour)
-	json.NewEncoder(w).Encode(map[string]string{
-		"token": token,
-	})
+	clearLoginFailures(email)
+	if utils.NeedsRehash(user.Password) {
+		// The password is known right now, so move it to the current
+		// hash settings.
+		if hash, err := utils.HashPassword(input.Password); err == nil {
+			db.DB.Model(&user).Update("password", hash)
+		}
+	}
+	if user.PasswordResetRequired {
+		loginFailed(r, &user, email, loginMethodPassword, "password reset required")
+		http.Error(w, "password reset required; use the link sent to your email", http.StatusForbidden)
+		return
+	}
+	if cfg.RequireVerifiedEmail && !user.EmailVerified {
+		loginFailed(r, &user, email, loginMethodPassword, "email not verified")
+		http.Error(w, "email not verified", http.StatusForbidden)
+		return
+	}
+	if user.TOTPEnabled {
+		writeMFAChallenge(w, user)
+		return
+	}
+	startSession(w, r, user, false, loginMethodPassword)
 }
@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+	"user-service/db"
+	"user-service/mailer"
+	"user-service/models"
+	"user-service/utils"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func emailThrottleKey(email string) string { return "email:" + email }
+func ipThrottleKey(ip string) string       { return "ip:" + ip }
+
+func emailLoginPolicy() utils.LoginPolicy {
+	return utils.LoginPolicy{DelayAfter: 3, MaxDelay: 30 * time.Second, LockAfter: cfg.LoginLockAfter, LockFor: cfg.LoginLockout, Window: time.Hour}
+}
+
+func ipLoginPolicy() utils.LoginPolicy {
+	return utils.LoginPolicy{DelayAfter: 15, MaxDelay: 30 * time.Second, LockAfter: 5 * cfg.LoginLockAfter, LockFor: cfg.LoginLockout, Window: time.Hour}
+}
+
+// loginRetryAfter reports how long a login for email from ip has to wait
+// because of earlier failures, and whether the account is locked.
+func loginRetryAfter(email, ip string, now time.Time) (time.Duration, bool) {
+	var wait time.Duration
+	var locked bool
+	for key, policy := range map[string]utils.LoginPolicy{emailThrottleKey(email): emailLoginPolicy(), ipThrottleKey(ip): ipLoginPolicy()} {
+		var t models.LoginThrottle
+		if err := db.DB.Where("key = ?", key).First(&t).Error; err != nil {
+			continue
+		}
+		if d, l := policy.RetryAfter(t, now); d > wait {
+			wait, locked = d, l
+		}
+	}
+	return wait, locked
+}
+
+func writeRetryAfter(w http.ResponseWriter, wait time.Duration, locked bool) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	msg := "too many failed logins, try again later"
+	if locked {
+		msg = "account temporarily locked after too many failed logins"
+	}
+	http.Error(w, msg, http.StatusTooManyRequests)
+}
+
+// recordThrottleFailure counts a failed login against key and reports
+// whether that locked it.
+func recordThrottleFailure(key string, policy utils.LoginPolicy, now time.Time) (bool, error) {
+	var locked bool
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		t := models.LoginThrottle{Key: key}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&t).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).First(&t).Error; err != nil {
+			return err
+		}
+		locked = policy.Fail(&t, now)
+		return tx.Save(&t).Error
+	})
+	return locked, err
+}
+
+// recordLoginFailure counts a failed login for email from ip. When it
+// locks an existing account, the owner is told by email.
+func recordLoginFailure(email, ip string, user *models.User, now time.Time) {
+	if _, err := recordThrottleFailure(ipThrottleKey(ip), ipLoginPolicy(), now); err != nil {
+		log.Printf("login throttle for %s: %v", ip, err)
+	}
+	if email == "" {
+		return
+	}
+	locked, err := recordThrottleFailure(emailThrottleKey(email), emailLoginPolicy(), now)
+	if err != nil {
+		log.Printf("login throttle for %s: %v", email, err)
+		return
+	}
+	if locked && user != nil {
+		body := fmt.Sprintf("Hi %s,\n\nThere were %d failed attempts to sign in to your account, so sign-in is locked for %s. If it was not you, consider resetting your password.\n",
+			user.Name, cfg.LoginLockAfter, cfg.LoginLockout)
+		if err := mailer.Send(user.Email, "Your account is temporarily locked", body); err != nil {
+			log.Printf("lockout notice to user %d failed: %v", user.ID, err)
+		}
+	}
+}
+
+func clearLoginFailures(email string) error {
+	return db.DB.Where("key = ?", emailThrottleKey(email)).Delete(&models.LoginThrottle{}).Error
+}
+
+// UnlockUser lets an admin lift a lockout before it runs out.
+func UnlockUser(w http.ResponseWriter, r *http.Request) {
+	var user models.User
+	err := db.DB.First(&user, mux.Vars(r)["id"]).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("key = ?", emailThrottleKey(user.Email)).Delete(&models.LoginThrottle{}).Error; err != nil {
+			return err
+		}
+		return audit(tx, r, auditUnlocked, user.ID, nil)
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
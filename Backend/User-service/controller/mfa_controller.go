@@ -0,0 +1,367 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+	"user-service/db"
+	"user-service/models"
+	"user-service/utils"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+const recoveryCodeCount = 10
+
+var errInvalidCode = errors.New("invalid code")
+
+// mfaLimiter slows down guessing second-factor codes, per user.
+var mfaLimiter = utils.NewKeyedLimiter(30*time.Second, 5)
+
+// mfaRequired reports whether user must pass two-factor authentication
+// to get the permissions of their role.
+func mfaRequired(user models.User) bool {
+	if user.MFARequired {
+		return true
+	}
+	for _, role := range cfg.MFARequiredRoles {
+		if role == user.Role {
+			return true
+		}
+	}
+	return false
+}
+
+func writeMFAChallenge(w http.ResponseWriter, user models.User) {
+	challenge, err := utils.CreatePurposeToken(utils.AudienceMFALogin, user.ID, user.Email, cfg.MFAChallengeTTL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"mfa_required":    true,
+		"challenge_token": challenge,
+		"expires_in":      int(cfg.MFAChallengeTTL.Seconds()),
+	})
+}
+
+// checkSecondFactor accepts either a current TOTP code or an unused
+// recovery code for user, and uses it up.
+func checkSecondFactor(tx *gorm.DB, user models.User, code, recoveryCode string, now time.Time) error {
+	if recoveryCode != "" {
+		res := tx.Model(&models.RecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, utils.HashRecoveryCode(recoveryCode)).
+			Update("used_at", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errInvalidCode
+		}
+		return nil
+	}
+	step, ok := utils.ValidateTOTP(user.TOTPSecret, code, now)
+	if !ok {
+		return errInvalidCode
+	}
+	// Only a step later than the last accepted one counts, so a code
+	// cannot be replayed, not even concurrently.
+	res := tx.Model(&models.User{}).Where("id = ? AND totp_last_step < ?", user.ID, step).Update("totp_last_step", step)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errInvalidCode
+	}
+	return nil
+}
+
+// replaceRecoveryCodes drops the user's recovery codes and issues new ones.
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	codes, hashes, err := utils.NewRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	rows := make([]models.RecoveryCode, len(hashes))
+	for i, h := range hashes {
+		rows[i] = models.RecoveryCode{UserID: userID, CodeHash: h}
+	}
+	return codes, tx.Create(&rows).Error
+}
+
+// LoginMFA is the second login step: it trades the challenge token from
+// Login plus a TOTP or recovery code for a session.
+func LoginMFA(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"`
+		RecoveryCode   string `json:"recovery_code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	userID, email, err := utils.ParsePurposeToken(utils.AudienceMFALogin, input.ChallengeToken)
+	if err != nil {
+		http.Error(w, "invalid or expired challenge", http.StatusUnauthorized)
+		return
+	}
+	if !mfaLimiter.Allow(email) {
+		http.Error(w, "too many attempts", http.StatusTooManyRequests)
+		return
+	}
+	var user models.User
+	if err := db.DB.First(&user, userID).Error; err != nil || user.Email != email || !user.TOTPEnabled {
+		http.Error(w, "invalid or expired challenge", http.StatusUnauthorized)
+		return
+	}
+	method := loginMethodTOTP
+	if input.RecoveryCode != "" {
+		method = loginMethodRecoveryCode
+	}
+	if err := checkSecondFactor(db.DB, user, input.Code, input.RecoveryCode, time.Now()); err != nil {
+		loginFailed(r, &user, email, method, "invalid code")
+		http.Error(w, "invalid code", http.StatusUnauthorized)
+		return
+	}
+	startSession(w, r, user, true, method)
+}
+
+func currentUser(r *http.Request) (models.User, error) {
+	var user models.User
+	err := db.DB.First(&user, r.Context().Value("userID")).Error
+	return user, err
+}
+
+// EnrollTOTP starts TOTP enrolment with a new secret. It takes effect
+// once ConfirmTOTP sees a code generated from it.
+func EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	user, err := currentUser(r)
+	if err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	if user.TOTPEnabled {
+		http.Error(w, "two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+	secret, err := utils.NewTOTPSecret()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := db.DB.Model(&user).Update("totp_secret", secret).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]string{
+		"secret":      secret,
+		"otpauth_uri": utils.TOTPURI(cfg.TOTPIssuer, user.Email, secret),
+	})
+}
+
+type codeRequest struct {
+	Code string `json:"code"`
+}
+
+// ConfirmTOTP enables TOTP after checking a code from the new secret and
+// returns the recovery codes; they are shown this once only. The current
+// session counts as two-factor from here on.
+func ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	var input codeRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	user, err := currentUser(r)
+	if err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	if user.TOTPEnabled {
+		http.Error(w, "two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+	if user.TOTPSecret == "" {
+		http.Error(w, "start enrolment first", http.StatusConflict)
+		return
+	}
+	claims, _ := r.Context().Value("claims").(*utils.AccessClaims)
+	var codes []string
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := checkSecondFactor(tx, user, input.Code, "", time.Now()); err != nil {
+			return err
+		}
+		if err := tx.Model(&user).Update("totp_enabled", true).Error; err != nil {
+			return err
+		}
+		if claims != nil && claims.SessionID != 0 {
+			if err := tx.Model(&models.Session{}).Where("id = ? AND user_id = ?", claims.SessionID, user.ID).Update("mfa", true).Error; err != nil {
+				return err
+			}
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if errors.Is(err, errInvalidCode) {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{"recovery_codes": codes})
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes; it needs a
+// current TOTP code.
+func RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	var input codeRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	user, err := currentUser(r)
+	if err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	if !user.TOTPEnabled {
+		http.Error(w, "two-factor authentication is not enabled", http.StatusConflict)
+		return
+	}
+	var codes []string
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := checkSecondFactor(tx, user, input.Code, "", time.Now()); err != nil {
+			return err
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if errors.Is(err, errInvalidCode) {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{"recovery_codes": codes})
+}
+
+// DisableTOTP turns 2FA off after checking a TOTP or recovery code. Users
+// who are required to use 2FA cannot turn it off.
+func DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	user, err := currentUser(r)
+	if err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	if !user.TOTPEnabled {
+		http.Error(w, "two-factor authentication is not enabled", http.StatusConflict)
+		return
+	}
+	if mfaRequired(user) {
+		http.Error(w, "two-factor authentication is required for this account", http.StatusForbidden)
+		return
+	}
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := checkSecondFactor(tx, user, input.Code, input.RecoveryCode, time.Now()); err != nil {
+			return err
+		}
+		return clearTOTP(tx, user.ID)
+	})
+	if errors.Is(err, errInvalidCode) {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func clearTOTP(tx *gorm.DB, userID uint) error {
+	err := tx.Model(&models.User{}).Where("id = ?", userID).
+		Updates(map[string]interface{}{"totp_enabled": false, "totp_secret": "", "totp_last_step": 0}).Error
+	if err != nil {
+		return err
+	}
+	return tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+}
+
+// SetMFARequired lets an admin require 2FA of a user whatever their role.
+// Sessions without 2FA lose the role's permissions at their next refresh.
+func SetMFARequired(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Required bool `json:"required"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	var user models.User
+	if err := db.DB.First(&user, mux.Vars(r)["id"]).Error; err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("mfa_required", input.Required).Error; err != nil {
+			return err
+		}
+		return audit(tx, r, auditMFARequired, user.ID, map[string]interface{}{"required": input.Required})
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	user.Password = ""
+	json.NewEncoder(w).Encode(user)
+}
+
+// ResetTOTP lets an admin remove a user's 2FA, e.g. after a lost phone.
+// The user's sessions end; if 2FA is required they must enrol again.
+func ResetTOTP(w http.ResponseWriter, r *http.Request) {
+	var user models.User
+	if err := db.DB.First(&user, mux.Vars(r)["id"]).Error; err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := clearTOTP(tx, user.ID); err != nil {
+			return err
+		}
+		if err := revokeAllSessions(tx, user.ID, "2fa reset", time.Now()); err != nil {
+			return err
+		}
+		return audit(tx, r, auditTOTPReset, user.ID, nil)
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("two-factor authentication of user %d reset", user.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
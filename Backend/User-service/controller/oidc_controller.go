@@ -0,0 +1,292 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"user-service/db"
+	"user-service/models"
+	"user-service/oidc"
+	"user-service/utils"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	oidcStateCookie = "oidc_state"
+	oidcStateTTL    = 10 * time.Minute
+)
+
+var (
+	errInvalidOIDCState  = errors.New("invalid or expired sign-in attempt")
+	errIdentityTaken     = errors.New("this account is already linked to another user")
+	errEmailNotVerified  = errors.New("the provider did not confirm the email address")
+	errAccountUnverified = errors.New("an account with this email exists; sign in with your password and link the provider from your account")
+)
+
+var oidcProviders = map[string]*oidc.Provider{}
+
+// InitOIDC registers the providers users can sign in with.
+func InitOIDC(providers ...*oidc.Provider) {
+	for _, p := range providers {
+		oidcProviders[p.Name] = p
+	}
+}
+
+func oidcProvider(w http.ResponseWriter, r *http.Request) (*oidc.Provider, bool) {
+	p, ok := oidcProviders[mux.Vars(r)["provider"]]
+	if !ok {
+		http.Error(w, "unknown provider", http.StatusNotFound)
+	}
+	return p, ok
+}
+
+// beginOIDC stores a new sign-in attempt, binds it to the browser with a
+// cookie and returns the provider URL to send the browser to.
+func beginOIDC(w http.ResponseWriter, p *oidc.Provider, linkUserID uint) (string, error) {
+	state, err := oidc.RandomString()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		return "", err
+	}
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		return "", err
+	}
+	authURL, err := p.AuthCodeURL(state, nonce, challenge)
+	if err != nil {
+		return "", err
+	}
+	err = db.DB.Create(&models.OIDCLoginState{
+		StateHash:    utils.HashOpaqueToken(state),
+		Provider:     p.Name,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		LinkUserID:   linkUserID,
+		ExpiresAt:    time.Now().Add(oidcStateTTL),
+	}).Error
+	if err != nil {
+		return "", err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/oidc/",
+		MaxAge:   int(oidcStateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(cfg.AppBaseURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+	return authURL, nil
+}
+
+// OIDCLogin sends the browser to the provider to sign in.
+func OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	p, ok := oidcProvider(w, r)
+	if !ok {
+		return
+	}
+	authURL, err := beginOIDC(w, p, 0)
+	if err != nil {
+		log.Printf("oidc %s: %v", p.Name, err)
+		http.Error(w, "provider unavailable", http.StatusBadGateway)
+		return
+	}
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OIDCLink starts adding a provider account to the signed-in user. The
+// client sends the browser to the returned URL; the callback links the
+// account instead of logging in.
+func OIDCLink(w http.ResponseWriter, r *http.Request) {
+	p, ok := oidcProvider(w, r)
+	if !ok {
+		return
+	}
+	user, err := currentUser(r)
+	if err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	authURL, err := beginOIDC(w, p, user.ID)
+	if err != nil {
+		log.Printf("oidc %s: %v", p.Name, err)
+		http.Error(w, "provider unavailable", http.StatusBadGateway)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"authorization_url": authURL})
+}
+
+// OIDCCallback finishes a sign-in: it checks state against the cookie,
+// redeems the code with the PKCE verifier, verifies the ID token and then
+// logs the user in (or links the identity).
+func OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	p, ok := oidcProvider(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		http.Error(w, "sign-in failed: "+e, http.StatusBadRequest)
+		return
+	}
+	state := q.Get("state")
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		http.Error(w, errInvalidOIDCState.Error(), http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: "/oidc/", MaxAge: -1})
+
+	// Use the attempt up before talking to the provider, so a state can
+	// only ever be redeemed once.
+	now := time.Now()
+	var attempt models.OIDCLoginState
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("state_hash = ?", utils.HashOpaqueToken(state)).First(&attempt).Error; err != nil {
+			return errInvalidOIDCState
+		}
+		if attempt.Provider != p.Name || attempt.UsedAt != nil || !now.Before(attempt.ExpiresAt) {
+			return errInvalidOIDCState
+		}
+		return tx.Model(&attempt).Update("used_at", now).Error
+	})
+	if err != nil {
+		http.Error(w, errInvalidOIDCState.Error(), http.StatusBadRequest)
+		return
+	}
+
+	idToken, err := p.Exchange(q.Get("code"), attempt.CodeVerifier)
+	if err != nil {
+		log.Printf("oidc %s: %v", p.Name, err)
+		http.Error(w, "could not complete sign-in with the provider", http.StatusBadGateway)
+		return
+	}
+	claims, err := p.VerifyIDToken(idToken, attempt.Nonce)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	user, identity, err := resolveOIDCUser(p.Name, claims, attempt.LinkUserID, now)
+	if err != nil && attempt.LinkUserID == 0 {
+		loginFailed(r, nil, claims.Email, "oidc:"+p.Name, truncate(err.Error(), 50))
+	}
+	switch {
+	case errors.Is(err, errIdentityTaken), errors.Is(err, errAccountUnverified):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, errEmailNotVerified):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if attempt.LinkUserID != 0 {
+		json.NewEncoder(w).Encode(identity)
+		return
+	}
+	if user.TOTPEnabled {
+		writeMFAChallenge(w, user)
+		return
+	}
+	startSession(w, r, user, false, "oidc:"+p.Name)
+}
+
+// resolveOIDCUser finds the user an ID token signs in: the one already
+// linked to the identity, the user adding it (linkUserID), or the user
+// with the same verified email, in that order. Without any, a new user is
+// created.
+func resolveOIDCUser(provider string, claims *oidc.IDClaims, linkUserID uint, now time.Time) (models.User, models.UserIdentity, error) {
+	var user models.User
+	var identity models.UserIdentity
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("provider = ? AND subject = ?", provider, claims.Subject).First(&identity).Error
+		if err == nil {
+			if linkUserID != 0 && identity.UserID != linkUserID {
+				return errIdentityTaken
+			}
+			identity.LastLoginAt = &now
+			if err := tx.Model(&identity).Update("last_login_at", now).Error; err != nil {
+				return err
+			}
+			return tx.First(&user, identity.UserID).Error
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		email, emailErr := utils.NormalizeEmail(claims.Email)
+		switch {
+		case linkUserID != 0:
+			if err := tx.First(&user, linkUserID).Error; err != nil {
+				return err
+			}
+		case emailErr != nil || !bool(claims.EmailVerified):
+			return errEmailNotVerified
+		default:
+			err := tx.Where("email = ?", email).First(&user).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				user = models.User{Name: claims.Name, Email: email, EmailVerified: true, EmailVerifiedAt: &now}
+				if user.Name == "" {
+					user.Name = strings.SplitN(email, "@", 2)[0]
+				}
+				err = tx.Create(&user).Error
+			} else if err == nil && !user.EmailVerified {
+				// Someone may have registered the address without owning
+				// it; do not hand them a provider-backed login.
+				return errAccountUnverified
+			}
+			if err != nil {
+				return err
+			}
+		}
+		identity = models.UserIdentity{UserID: user.ID, Provider: provider, Subject: claims.Subject, Email: email, LastLoginAt: &now}
+		return tx.Create(&identity).Error
+	})
+	return user, identity, err
+}
+
+// ListIdentities shows the provider accounts linked to the current user.
+func ListIdentities(w http.ResponseWriter, r *http.Request) {
+	var identities []models.UserIdentity
+	db.DB.Where("user_id = ?", r.Context().Value("userID")).Order("id").Find(&identities)
+	json.NewEncoder(w).Encode(identities)
+}
+
+// DeleteIdentity unlinks a provider account, unless it is the user's only
+// way to sign in.
+func DeleteIdentity(w http.ResponseWriter, r *http.Request) {
+	user, err := currentUser(r)
+	if err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	var identity models.UserIdentity
+	if err := db.DB.Where("id = ? AND user_id = ?", mux.Vars(r)["id"], user.ID).First(&identity).Error; err != nil {
+		http.Error(w, "identity not found", http.StatusNotFound)
+		return
+	}
+	var count int64
+	db.DB.Model(&models.UserIdentity{}).Where("user_id = ?", user.ID).Count(&count)
+	if user.Password == "" && count <= 1 {
+		http.Error(w, "set a password before unlinking your last sign-in provider", http.StatusConflict)
+		return
+	}
+	if err := db.DB.Delete(&identity).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,180 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+	"user-service/db"
+	"user-service/mailer"
+	"user-service/models"
+	"user-service/utils"
+
+	"gorm.io/gorm"
+)
+
+var errInvalidResetToken = errors.New("invalid or expired reset token")
+
+var forgotLimiter = utils.NewKeyedLimiter(10*time.Minute, 3)
+
+// breachList is consulted for new passwords when configured.
+var breachList *utils.BreachList
+
+// checkNewPassword applies the password policy to a new password for the
+// account with email and name. If the breach list cannot be read the
+// password is allowed, so a broken list does not stop sign-ups.
+func checkNewPassword(pw, email, name string) error {
+	policy := utils.PasswordPolicy{
+		MinLength:        cfg.PasswordMinLength,
+		MaxBytes:         72,
+		MinClasses:       cfg.PasswordMinClasses,
+		DisallowPersonal: true,
+	}
+	if err := policy.Check(pw, email, name); err != nil {
+		return err
+	}
+	if breachList != nil {
+		breached, err := breachList.Breached(pw)
+		if err != nil {
+			log.Printf("breached password lookup failed: %v", err)
+		} else if breached {
+			return utils.ErrPasswordBreached
+		}
+	}
+	return nil
+}
+
+// ForgotPassword emails a reset link if the address has an account. It
+// answers 202 either way, and looks the account up and sends the mail in
+// the background, so neither the answer nor its timing reveals which
+// emails exist.
+func ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	email, err := utils.NormalizeEmail(input.Email)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !forgotLimiter.Allow(email) {
+		http.Error(w, "too many reset requests, try again later", http.StatusTooManyRequests)
+		return
+	}
+	go func() {
+		var user models.User
+		if err := db.DB.Where("email = ?", email).First(&user).Error; err != nil {
+			return
+		}
+		if err := sendPasswordReset(user); err != nil {
+			log.Printf("password reset for user %d failed: %v", user.ID, err)
+		}
+	}()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func sendPasswordReset(user models.User) error {
+	token, hash, err := utils.NewOpaqueToken()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		// Only the newest link works.
+		if err := tx.Model(&models.PasswordReset{}).Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.PasswordReset{UserID: user.ID, TokenHash: hash, ExpiresAt: now.Add(cfg.PasswordResetTTL)}).Error
+	})
+	if err != nil {
+		return err
+	}
+	link, err := url.Parse(cfg.PasswordResetURL)
+	if err != nil {
+		return err
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	body := fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password for your account. If it was you, open this link within %s:\n\n%s\n\nIf it was not, you can ignore this email.\n",
+		user.Name, cfg.PasswordResetTTL, link)
+	return mailer.Send(user.Email, "Reset your password", body)
+}
+
+// ResetPassword sets a new password with a reset token. The token works
+// once, and every access token issued before the reset stops working.
+func ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	now := time.Now()
+	tokenHash := utils.HashOpaqueToken(input.Token)
+	var pending models.PasswordReset
+	var user models.User
+	if err := db.DB.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", tokenHash, now).First(&pending).Error; err != nil ||
+		db.DB.First(&user, pending.UserID).Error != nil {
+		http.Error(w, errInvalidResetToken.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkNewPassword(input.Password, user.Email, user.Name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	passwordHash, err := utils.HashPassword(input.Password)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		// Claiming the token with a conditional update means two concurrent
+		// resets cannot both use it.
+		res := tx.Model(&models.PasswordReset{}).
+			Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", tokenHash, now).
+			Update("used_at", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errInvalidResetToken
+		}
+		var reset models.PasswordReset
+		if err := tx.Where("token_hash = ?", tokenHash).First(&reset).Error; err != nil {
+			return err
+		}
+		// Getting the link proves the user owns the address.
+		err := tx.Model(&models.User{}).Where("id = ?", reset.UserID).Updates(map[string]interface{}{
+			"password":                passwordHash,
+			"tokens_invalid_before":   now,
+			"password_reset_required": false,
+			"email_verified":          true,
+			"email_verified_at":       gorm.Expr("COALESCE(email_verified_at, ?)", now),
+		}).Error
+		if err != nil {
+			return err
+		}
+		return revokeAllSessions(tx, reset.UserID, "password reset", now)
+	})
+	if errors.Is(err, errInvalidResetToken) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
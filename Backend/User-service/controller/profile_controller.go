@@ -0,0 +1,344 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"user-service/db"
+	"user-service/events"
+	"user-service/mailer"
+	"user-service/models"
+	"user-service/utils"
+
+	"github.com/gajare/BAJAR-App/Backend/pkg/authz"
+	"gorm.io/gorm"
+)
+
+var (
+	errWrongPassword  = errors.New("current password is wrong")
+	errReauthRequired = errors.New("sign in again to confirm this change")
+)
+
+// changeEmailLimiter limits change-email mails per user.
+var changeEmailLimiter = utils.NewKeyedLimiter(10*time.Minute, 3)
+
+// checkCurrentPassword re-authenticates the user for sensitive changes.
+// Users who only sign in through a provider have no password to check;
+// they must have signed in within cfg.ReauthMaxAge instead, so a stolen
+// access token alone is not enough.
+func checkCurrentPassword(r *http.Request, user models.User, password string) error {
+	if user.Password != "" {
+		if utils.CheckPassword(user.Password, password) != nil {
+			return errWrongPassword
+		}
+		return nil
+	}
+	claims, ok := r.Context().Value("claims").(*utils.AccessClaims)
+	if !ok || claims.SessionID == 0 {
+		return errReauthRequired
+	}
+	var session models.Session
+	if err := db.DB.Select("id", "user_id", "created_at").First(&session, claims.SessionID).Error; err != nil ||
+		session.UserID != user.ID || time.Since(session.CreatedAt) > cfg.ReauthMaxAge {
+		return errReauthRequired
+	}
+	return nil
+}
+
+// UpdateProfile changes the name, phone and avatar; fields left out of the
+// body are kept.
+func UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name      *string `json:"name"`
+		Phone     *string `json:"phone"`
+		AvatarURL *string `json:"avatar_url"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	user, err := currentUser(r)
+	if err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	updates := map[string]interface{}{}
+	if input.Name != nil {
+		name := strings.TrimSpace(*input.Name)
+		if name == "" || len(name) > 100 {
+			http.Error(w, "name must be 1 to 100 characters", http.StatusBadRequest)
+			return
+		}
+		updates["name"] = name
+	}
+	if input.Phone != nil {
+		phone := ""
+		if strings.TrimSpace(*input.Phone) != "" {
+			if phone, err = utils.NormalizePhone(*input.Phone); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		updates["phone"] = phone
+	}
+	if input.AvatarURL != nil {
+		avatar := strings.TrimSpace(*input.AvatarURL)
+		if avatar != "" {
+			u, err := url.Parse(avatar)
+			if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || len(avatar) > 500 {
+				http.Error(w, "avatar_url must be an http(s) URL", http.StatusBadRequest)
+				return
+			}
+		}
+		updates["avatar_url"] = avatar
+	}
+	if len(updates) > 0 {
+		if err := db.DB.Model(&user).Updates(updates).Error; err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	user.Password = ""
+	json.NewEncoder(w).Encode(user)
+}
+
+// ChangePassword sets a new password after checking the current one. The
+// user's other sessions are ended; this one stays signed in.
+func ChangePassword(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	if input.NewPassword == "" {
+		http.Error(w, "new_password is required", http.StatusBadRequest)
+		return
+	}
+	user, err := currentUser(r)
+	if err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	if err := checkCurrentPassword(r, user, input.CurrentPassword); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err := checkNewPassword(input.NewPassword, user.Email, user.Name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	hash, err := utils.HashPassword(input.NewPassword)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var sessionID uint
+	if claims, ok := r.Context().Value("claims").(*utils.AccessClaims); ok {
+		sessionID = claims.SessionID
+	}
+	now := time.Now()
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("password", hash).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Session{}).Where("user_id = ? AND id <> ? AND revoked_at IS NULL", user.ID, sessionID).
+			Updates(map[string]interface{}{"revoked_at": now, "revoked_reason": "password changed"}).Error
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ChangeEmail starts moving the account to a new address. Nothing changes
+// until the link mailed to the new address is opened; the old address is
+// told about the request.
+func ChangeEmail(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		NewEmail string `json:"new_email"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	email, err := utils.NormalizeEmail(input.NewEmail)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	user, err := currentUser(r)
+	if err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	if err := checkCurrentPassword(r, user, input.Password); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if email == user.Email {
+		http.Error(w, "that is already your email address", http.StatusBadRequest)
+		return
+	}
+	var taken int64
+	db.DB.Model(&models.User{}).Where("email = ?", email).Count(&taken)
+	if taken > 0 {
+		http.Error(w, "email already registered", http.StatusConflict)
+		return
+	}
+	if !changeEmailLimiter.Allow(fmt.Sprint(user.ID)) {
+		http.Error(w, "too many requests, try again later", http.StatusTooManyRequests)
+		return
+	}
+
+	token, err := utils.CreatePurposeToken(utils.AudienceChangeEmail, user.ID, email, cfg.VerificationTTL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	link := cfg.AppBaseURL + "/confirm-email-change?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("Hi %s,\n\nTo use this address for your account, open this link within %s:\n\n%s\n",
+		user.Name, cfg.VerificationTTL, link)
+	if err := mailer.Send(email, "Confirm your new email address", body); err != nil {
+		http.Error(w, "could not send the confirmation email", http.StatusBadGateway)
+		return
+	}
+	notice := fmt.Sprintf("Hi %s,\n\nSomeone asked to change the email address of your account to %s. If it was not you, reset your password now.\n",
+		user.Name, email)
+	if err := mailer.Send(user.Email, "Your email address is being changed", notice); err != nil {
+		log.Printf("email change notice to user %d failed: %v", user.ID, err)
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ConfirmEmailChange switches the account to the address the link was
+// sent to; opening the link proves the user owns it. Every session ends,
+// so whoever asked for the change has to sign in again with the new
+// address.
+func ConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	userID, email, err := utils.ParsePurposeToken(utils.AudienceChangeEmail, r.URL.Query().Get("token"))
+	if err != nil {
+		http.Error(w, "invalid or expired link", http.StatusBadRequest)
+		return
+	}
+	var user models.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		http.Error(w, "invalid or expired link", http.StatusBadRequest)
+		return
+	}
+	if user.Email != email {
+		var taken int64
+		db.DB.Model(&models.User{}).Where("email = ?", email).Count(&taken)
+		if taken > 0 {
+			http.Error(w, "email already registered", http.StatusConflict)
+			return
+		}
+		now := time.Now()
+		err := db.DB.Transaction(func(tx *gorm.DB) error {
+			err := tx.Model(&user).Updates(map[string]interface{}{
+				"email": email, "email_verified": true, "email_verified_at": now,
+			}).Error
+			if err != nil {
+				return err
+			}
+			return signOutEverywhere(tx, user.ID, now)
+		})
+		if err != nil {
+			// Most likely someone registered the address in between.
+			http.Error(w, "could not change email", http.StatusConflict)
+			return
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":             user.ID,
+		"email":          email,
+		"email_verified": true,
+	})
+}
+
+// DeleteAccount erases the user: personal data in the user row is
+// overwritten and the row soft-deleted (orders and payments still refer
+// to the ID), addresses, linked identities and 2FA data are removed,
+// sessions end, and a user.deleted event tells the other services to
+// erase what they hold.
+func DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Password     string `json:"password"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	user, err := currentUser(r)
+	if err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	if err := checkCurrentPassword(r, user, input.Password); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	now := time.Now()
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if user.TOTPEnabled {
+			if err := checkSecondFactor(tx, user, input.Code, input.RecoveryCode, now); err != nil {
+				return err
+			}
+		}
+		err := tx.Model(&user).Updates(map[string]interface{}{
+			"name":              "Deleted user",
+			"email":             fmt.Sprintf("deleted-%d@deleted.invalid", user.ID),
+			"password":          "",
+			"phone":             "",
+			"avatar_url":        "",
+			"role":              authz.RoleCustomer,
+			"email_verified":    false,
+			"email_verified_at": nil,
+			"totp_secret":       "",
+			"totp_enabled":      false,
+			"mfa_required":      false,
+		}).Error
+		if err != nil {
+			return err
+		}
+		for _, m := range []interface{}{&models.Address{}, &models.UserIdentity{}, &models.RecoveryCode{}, &models.PasswordReset{}, &models.LoginEvent{}} {
+			if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(m).Error; err != nil {
+				return err
+			}
+		}
+		if err := leaveSeller(tx, user.ID); err != nil {
+			return err
+		}
+		if err := revokeAllSessions(tx, user.ID, "account deleted", now); err != nil {
+			return err
+		}
+		if err := tx.Delete(&user).Error; err != nil {
+			return err
+		}
+		return events.Record(tx, events.UserDeleted, map[string]interface{}{"user_id": user.ID, "deleted_at": now})
+	})
+	if errors.Is(err, errInvalidCode) {
+		http.Error(w, "two-factor code required", http.StatusUnauthorized)
+		return
+	}
+	if errors.Is(err, errLastOwner) {
+		http.Error(w, "you are the last owner of a seller; add another owner first", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,555 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"user-service/db"
+	"user-service/events"
+	"user-service/models"
+	"user-service/utils"
+
+	"github.com/gajare/BAJAR-App/Backend/pkg/authz"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Reviewer actions on sellers written to the audit log. Their target user
+// is 0; the seller is named in the details.
+const (
+	auditSellerReviewed    = "seller.kyc_reviewed"
+	auditSellerSuspended   = "seller.suspended"
+	auditSellerReactivated = "seller.reactivated"
+)
+
+var (
+	errLastOwner     = errors.New("a seller needs at least one owner")
+	errAlreadyMember = errors.New("user already works for a seller")
+)
+
+// sellerMembership returns the membership that goes into userID's access
+// tokens, or nil when they do not work for a seller that may sell.
+func sellerMembership(tx *gorm.DB, userID uint) (*models.SellerMember, error) {
+	var m models.SellerMember
+	err := tx.Joins("JOIN sellers ON sellers.id = seller_members.seller_id").
+		Where("seller_members.user_id = ? AND sellers.kyc_status = ? AND sellers.suspended_at IS NULL", userID, models.KYCVerified).
+		First(&m).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+type sellerInput struct {
+	Name       string `json:"name"`
+	LegalName  string `json:"legal_name"`
+	PAN        string `json:"pan"`
+	GSTIN      string `json:"gstin"`
+	Email      string `json:"email"`
+	Phone      string `json:"phone"`
+	Line1      string `json:"line1"`
+	Line2      string `json:"line2"`
+	City       string `json:"city"`
+	State      string `json:"state"`
+	PostalCode string `json:"postal_code"`
+	Country    string `json:"country"`
+}
+
+// apply validates in and copies it onto s. Changing the legal details of
+// a seller, or editing a rejected one, sends it back for KYC review.
+func (in sellerInput) apply(s *models.Seller) error {
+	trim := strings.TrimSpace
+	if trim(in.Name) == "" || trim(in.LegalName) == "" || trim(in.Line1) == "" || trim(in.City) == "" {
+		return errors.New("name, legal_name, line1 and city are required")
+	}
+	pan, err := utils.NormalizePAN(in.PAN)
+	if err != nil {
+		return err
+	}
+	gstin := ""
+	if trim(in.GSTIN) != "" {
+		if gstin, err = utils.NormalizeGSTIN(in.GSTIN, pan); err != nil {
+			return err
+		}
+	}
+	email, err := utils.NormalizeEmail(in.Email)
+	if err != nil {
+		return err
+	}
+	phone, err := utils.NormalizePhone(in.Phone)
+	if err != nil {
+		return err
+	}
+	country := in.Country
+	if country == "" {
+		country = "IN"
+	}
+	if country, err = utils.NormalizeCountry(country); err != nil {
+		return err
+	}
+	postal, err := utils.NormalizePostalCode(country, in.PostalCode)
+	if err != nil {
+		return err
+	}
+	legalName := truncate(trim(in.LegalName), 200)
+	if s.ID == 0 || s.KYCStatus == models.KYCRejected || legalName != s.LegalName || pan != s.PAN || gstin != s.GSTIN {
+		s.KYCStatus, s.KYCNote, s.KYCReviewedAt, s.KYCReviewedBy = models.KYCPending, "", nil, nil
+	}
+	s.Name = truncate(trim(in.Name), 100)
+	s.LegalName = legalName
+	s.PAN = pan
+	s.GSTIN = gstin
+	s.Email = strings.ToLower(email)
+	s.Phone = phone
+	s.Line1 = truncate(trim(in.Line1), 200)
+	s.Line2 = truncate(trim(in.Line2), 200)
+	s.City = truncate(trim(in.City), 100)
+	s.State = truncate(trim(in.State), 100)
+	s.PostalCode = postal
+	s.Country = country
+	return nil
+}
+
+type sellerMemberView struct {
+	models.SellerMember
+	Email string `json:"email"`
+	Name  string `json:"name"`
+}
+
+func sellerMembers(sellerID uint) ([]sellerMemberView, error) {
+	var members []sellerMemberView
+	err := db.DB.Table("seller_members").
+		Select("seller_members.*, users.email, users.name").
+		Joins("JOIN users ON users.id = seller_members.user_id").
+		Where("seller_members.seller_id = ?", sellerID).Order("seller_members.id").
+		Scan(&members).Error
+	return members, err
+}
+
+// targetSeller loads the seller named by the {id} route variable for a
+// caller who works for it in one of roles (any role if none are given) or
+// reviews sellers. Anyone else gets a 404.
+func targetSeller(w http.ResponseWriter, r *http.Request, roles ...string) (models.Seller, bool) {
+	var seller models.Seller
+	if err := db.DB.First(&seller, mux.Vars(r)["id"]).Error; err != nil {
+		http.Error(w, "seller not found", http.StatusNotFound)
+		return seller, false
+	}
+	caller, _ := authz.FromContext(r.Context())
+	if caller.Can(authz.PermSellersReview) {
+		return seller, true
+	}
+	var m models.SellerMember
+	if err := db.DB.Where("seller_id = ? AND user_id = ?", seller.ID, caller.UserID).First(&m).Error; err != nil {
+		http.Error(w, "seller not found", http.StatusNotFound)
+		return seller, false
+	}
+	if len(roles) > 0 && !slices.Contains(roles, m.Role) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return seller, false
+	}
+	return seller, true
+}
+
+// checkOwnersLeft fails with errLastOwner when the seller would be left
+// without an owner. Call it within tx after locking the seller.
+func checkOwnersLeft(tx *gorm.DB, sellerID uint) error {
+	var owners int64
+	if err := tx.Model(&models.SellerMember{}).Where("seller_id = ? AND role = ?", sellerID, authz.SellerRoleOwner).Count(&owners).Error; err != nil {
+		return err
+	}
+	if owners == 0 {
+		return errLastOwner
+	}
+	return nil
+}
+
+func lockSeller(tx *gorm.DB, sellerID uint) error {
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.Seller{}, sellerID).Error
+}
+
+// leaveSeller removes userID from their seller, if any, within tx.
+func leaveSeller(tx *gorm.DB, userID uint) error {
+	var m models.SellerMember
+	err := tx.Where("user_id = ?", userID).First(&m).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := lockSeller(tx, m.SellerID); err != nil {
+		return err
+	}
+	if err := tx.Delete(&m).Error; err != nil {
+		return err
+	}
+	return checkOwnersLeft(tx, m.SellerID)
+}
+
+func writeSellerError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errLastOwner), errors.Is(err, errAlreadyMember):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// CreateSeller registers a business for the caller, who becomes its owner.
+// It cannot sell until its KYC details have been reviewed.
+func CreateSeller(w http.ResponseWriter, r *http.Request) {
+	var input sellerInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	var seller models.Seller
+	if err := input.apply(&seller); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	caller, _ := authz.FromContext(r.Context())
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.SellerMember{}).Where("user_id = ?", caller.UserID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errAlreadyMember
+		}
+		if err := tx.Create(&seller).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.SellerMember{SellerID: seller.ID, UserID: uint(caller.UserID), Role: authz.SellerRoleOwner}).Error
+	})
+	if err != nil {
+		writeSellerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(seller)
+}
+
+// GetMySeller shows the seller the caller works for and their role there.
+func GetMySeller(w http.ResponseWriter, r *http.Request) {
+	var m models.SellerMember
+	if err := db.DB.Where("user_id = ?", r.Context().Value("userID")).First(&m).Error; err != nil {
+		http.Error(w, "you do not work for a seller", http.StatusNotFound)
+		return
+	}
+	var seller models.Seller
+	if err := db.DB.First(&seller, m.SellerID).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"seller": seller, "role": m.Role})
+}
+
+// GetSeller shows a seller and its members.
+func GetSeller(w http.ResponseWriter, r *http.Request) {
+	seller, ok := targetSeller(w, r)
+	if !ok {
+		return
+	}
+	members, err := sellerMembers(seller.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"seller": seller, "members": members})
+}
+
+// UpdateSeller changes a seller's business details. Owners only.
+func UpdateSeller(w http.ResponseWriter, r *http.Request) {
+	var input sellerInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	seller, ok := targetSeller(w, r, authz.SellerRoleOwner)
+	if !ok {
+		return
+	}
+	if err := input.apply(&seller); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := db.DB.Save(&seller).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(seller)
+}
+
+// AddSellerMember lets an existing user work for the seller. Owners only.
+// Like every membership change, it reaches the user's access tokens when
+// they are next refreshed.
+func AddSellerMember(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	if !authz.ValidSellerRole(input.Role) {
+		http.Error(w, "role must be one of "+strings.Join(authz.SellerRoles(), ", "), http.StatusBadRequest)
+		return
+	}
+	seller, ok := targetSeller(w, r, authz.SellerRoleOwner)
+	if !ok {
+		return
+	}
+	var user models.User
+	if err := db.DB.Where("email = ?", strings.ToLower(strings.TrimSpace(input.Email))).First(&user).Error; err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	m := models.SellerMember{SellerID: seller.ID, UserID: user.ID, Role: input.Role}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.SellerMember{}).Where("user_id = ?", user.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errAlreadyMember
+		}
+		return tx.Create(&m).Error
+	})
+	if err != nil {
+		writeSellerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(sellerMemberView{SellerMember: m, Email: user.Email, Name: user.Name})
+}
+
+// targetMember loads the member named by {userID} of seller.
+func targetMember(w http.ResponseWriter, r *http.Request, seller models.Seller) (models.SellerMember, bool) {
+	var m models.SellerMember
+	if err := db.DB.Where("seller_id = ? AND user_id = ?", seller.ID, mux.Vars(r)["userID"]).First(&m).Error; err != nil {
+		http.Error(w, "member not found", http.StatusNotFound)
+		return m, false
+	}
+	return m, true
+}
+
+// UpdateSellerMember changes a member's role. Owners only, and the seller
+// keeps at least one owner.
+func UpdateSellerMember(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	if !authz.ValidSellerRole(input.Role) {
+		http.Error(w, "role must be one of "+strings.Join(authz.SellerRoles(), ", "), http.StatusBadRequest)
+		return
+	}
+	seller, ok := targetSeller(w, r, authz.SellerRoleOwner)
+	if !ok {
+		return
+	}
+	m, ok := targetMember(w, r, seller)
+	if !ok {
+		return
+	}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockSeller(tx, seller.ID); err != nil {
+			return err
+		}
+		if err := tx.Model(&m).Update("role", input.Role).Error; err != nil {
+			return err
+		}
+		return checkOwnersLeft(tx, seller.ID)
+	})
+	if err != nil {
+		writeSellerError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(m)
+}
+
+// RemoveSellerMember takes a user off the seller. Owners may remove
+// anyone, and members may remove themselves; the seller keeps at least one
+// owner.
+func RemoveSellerMember(w http.ResponseWriter, r *http.Request) {
+	caller, _ := authz.FromContext(r.Context())
+	roles := []string{authz.SellerRoleOwner}
+	if mux.Vars(r)["userID"] == fmt.Sprint(caller.UserID) {
+		roles = nil
+	}
+	seller, ok := targetSeller(w, r, roles...)
+	if !ok {
+		return
+	}
+	m, ok := targetMember(w, r, seller)
+	if !ok {
+		return
+	}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		return leaveSeller(tx, m.UserID)
+	})
+	if err != nil {
+		writeSellerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListSellers shows sellers for review, newest first, optionally only
+// those with ?kyc_status or whose name matches ?q.
+func ListSellers(w http.ResponseWriter, r *http.Request) {
+	page, limit := pageParams(r)
+	q := db.DB.Model(&models.Seller{})
+	if v := r.URL.Query().Get("kyc_status"); v != "" {
+		q = q.Where("kyc_status = ?", v)
+	}
+	if v := r.URL.Query().Get("q"); v != "" {
+		p := likePattern(v)
+		q = q.Where("LOWER(name) LIKE ? OR LOWER(legal_name) LIKE ?", p, p)
+	}
+	var total int64
+	var sellers []models.Seller
+	if err := q.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := q.Order("id DESC").Offset((page - 1) * limit).Limit(limit).Find(&sellers).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"sellers": sellers, "total": total, "page": page, "limit": limit})
+}
+
+// ReviewSeller records the KYC decision on a seller: "verified" lets it
+// sell, "rejected" needs a note telling the seller what to fix.
+func ReviewSeller(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Status string `json:"status"`
+		Note   string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	input.Note = truncate(strings.TrimSpace(input.Note), 255)
+	if input.Status != models.KYCVerified && input.Status != models.KYCRejected {
+		http.Error(w, "status must be verified or rejected", http.StatusBadRequest)
+		return
+	}
+	if input.Status == models.KYCRejected && input.Note == "" {
+		http.Error(w, "note is required when rejecting", http.StatusBadRequest)
+		return
+	}
+	seller, ok := targetSeller(w, r)
+	if !ok {
+		return
+	}
+	caller, _ := authz.FromContext(r.Context())
+	var own int64
+	db.DB.Model(&models.SellerMember{}).Where("seller_id = ? AND user_id = ?", seller.ID, caller.UserID).Count(&own)
+	if own > 0 {
+		http.Error(w, "you cannot review a seller you work for", http.StatusConflict)
+		return
+	}
+	now := time.Now()
+	reviewer := uint(caller.UserID)
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&seller).Updates(map[string]interface{}{
+			"kyc_status": input.Status, "kyc_note": input.Note, "kyc_reviewed_at": now, "kyc_reviewed_by": reviewer,
+		}).Error
+		if err != nil {
+			return err
+		}
+		if err := audit(tx, r, auditSellerReviewed, 0, map[string]interface{}{"seller_id": seller.ID, "status": input.Status, "note": input.Note}); err != nil {
+			return err
+		}
+		if input.Status == models.KYCVerified {
+			return events.Record(tx, events.SellerVerified, map[string]interface{}{"seller_id": seller.ID, "verified_at": now})
+		}
+		return nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(seller)
+}
+
+// SuspendSeller stops a seller from selling until it is reactivated.
+func SuspendSeller(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	input.Reason = truncate(strings.TrimSpace(input.Reason), 255)
+	if input.Reason == "" {
+		http.Error(w, "reason is required", http.StatusBadRequest)
+		return
+	}
+	seller, ok := targetSeller(w, r)
+	if !ok {
+		return
+	}
+	if seller.SuspendedAt != nil {
+		http.Error(w, "seller is already suspended", http.StatusConflict)
+		return
+	}
+	now := time.Now()
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&seller).Updates(map[string]interface{}{"suspended_at": now, "suspended_reason": input.Reason}).Error; err != nil {
+			return err
+		}
+		if err := audit(tx, r, auditSellerSuspended, 0, map[string]interface{}{"seller_id": seller.ID, "reason": input.Reason}); err != nil {
+			return err
+		}
+		return events.Record(tx, events.SellerSuspended, map[string]interface{}{"seller_id": seller.ID, "suspended_at": now})
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(seller)
+}
+
+// ReactivateSeller lifts a seller's suspension.
+func ReactivateSeller(w http.ResponseWriter, r *http.Request) {
+	seller, ok := targetSeller(w, r)
+	if !ok {
+		return
+	}
+	if seller.SuspendedAt == nil {
+		http.Error(w, "seller is not suspended", http.StatusConflict)
+		return
+	}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&seller).Updates(map[string]interface{}{"suspended_at": nil, "suspended_reason": ""}).Error; err != nil {
+			return err
+		}
+		if err := audit(tx, r, auditSellerReactivated, 0, map[string]interface{}{"seller_id": seller.ID}); err != nil {
+			return err
+		}
+		return events.Record(tx, events.SellerReactivated, map[string]interface{}{"seller_id": seller.ID})
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(seller)
+}
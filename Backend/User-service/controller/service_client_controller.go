@@ -0,0 +1,255 @@
+package controller
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"user-service/db"
+	"user-service/models"
+	"user-service/utils"
+
+	"github.com/gajare/BAJAR-App/Backend/pkg/authz"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// Admin actions on service clients written to the audit log. Their
+// target user is 0; the client is named in the details.
+const (
+	auditClientCreated = "client.created"
+	auditClientRotated = "client.secret_rotated"
+	auditClientRevoked = "client.revoked"
+)
+
+// newClientID returns a random public client id, e.g. "svc_1f0c...".
+func newClientID() (string, error) {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "svc_" + hex.EncodeToString(buf), nil
+}
+
+// parseScopes checks that every scope is a known permission and drops
+// duplicates.
+func parseScopes(scopes []string) ([]string, error) {
+	seen := map[string]bool{}
+	var out []string
+	for _, s := range scopes {
+		if !authz.ValidPermission(s) {
+			return nil, errors.New("unknown scope " + s)
+		}
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+// writeClientSecret answers with the client and its secret, which is not
+// shown again.
+func writeClientSecret(w http.ResponseWriter, status int, client models.ServiceClient, secret string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(struct {
+		models.ServiceClient
+		ClientSecret string `json:"client_secret"`
+	}{client, secret})
+}
+
+// CreateServiceClient issues credentials for another service. The caller
+// names the permissions ("scopes") its tokens may carry.
+func CreateServiceClient(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" || len(input.Name) > 100 {
+		http.Error(w, "name is required and must be at most 100 characters", http.StatusBadRequest)
+		return
+	}
+	scopes, err := parseScopes(input.Scopes)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	clientID, err := newClientID()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	secret, hash, err := utils.NewOpaqueToken()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	caller, _ := authz.FromContext(r.Context())
+	client := models.ServiceClient{
+		Name:       input.Name,
+		ClientID:   clientID,
+		SecretHash: hash,
+		Scopes:     strings.Join(scopes, " "),
+		CreatedBy:  uint(caller.UserID),
+	}
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&client).Error; err != nil {
+			return err
+		}
+		return audit(tx, r, auditClientCreated, 0, map[string]interface{}{"client_id": clientID, "scope": client.Scopes})
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeClientSecret(w, http.StatusCreated, client, secret)
+}
+
+// ListServiceClients shows every service client, revoked ones included.
+func ListServiceClients(w http.ResponseWriter, r *http.Request) {
+	var clients []models.ServiceClient
+	if err := db.DB.Order("id").Find(&clients).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(clients)
+}
+
+// targetClient loads the active client named by the {id} route variable,
+// writing a 404 if there is none.
+func targetClient(w http.ResponseWriter, r *http.Request) (models.ServiceClient, bool) {
+	var client models.ServiceClient
+	if err := db.DB.Where("revoked_at IS NULL").First(&client, mux.Vars(r)["id"]).Error; err != nil {
+		http.Error(w, "service client not found", http.StatusNotFound)
+		return client, false
+	}
+	return client, true
+}
+
+// RotateServiceClientSecret replaces a client's secret. Tokens already
+// issued stay valid until they expire.
+func RotateServiceClientSecret(w http.ResponseWriter, r *http.Request) {
+	client, ok := targetClient(w, r)
+	if !ok {
+		return
+	}
+	secret, hash, err := utils.NewOpaqueToken()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&client).Update("secret_hash", hash).Error; err != nil {
+			return err
+		}
+		return audit(tx, r, auditClientRotated, 0, map[string]interface{}{"client_id": client.ClientID})
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeClientSecret(w, http.StatusOK, client, secret)
+}
+
+// RevokeServiceClient disables a client. Its tokens stop working at once
+// in User-service and when they expire elsewhere.
+func RevokeServiceClient(w http.ResponseWriter, r *http.Request) {
+	client, ok := targetClient(w, r)
+	if !ok {
+		return
+	}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&client).Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return audit(tx, r, auditClientRevoked, 0, map[string]interface{}{"client_id": client.ClientID})
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// clientCredentials reads the client id and secret from HTTP basic auth,
+// whose values are form encoded (RFC 6749 2.3.1), or from the form.
+func clientCredentials(r *http.Request) (id, secret string, basic bool) {
+	if id, secret, ok := r.BasicAuth(); ok {
+		uid, err1 := url.QueryUnescape(id)
+		usecret, err2 := url.QueryUnescape(secret)
+		if err1 != nil || err2 != nil {
+			return "", "", true
+		}
+		return uid, usecret, true
+	}
+	return r.PostForm.Get("client_id"), r.PostForm.Get("client_secret"), false
+}
+
+// ServiceToken is the OAuth 2 token endpoint for the client credentials
+// grant. The token carries the client's scopes, or the subset named in
+// the scope parameter.
+func ServiceToken(w http.ResponseWriter, r *http.Request) {
+	fail := func(status int, reason string) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": reason})
+	}
+	if err := r.ParseForm(); err != nil {
+		fail(http.StatusBadRequest, "invalid_request")
+		return
+	}
+	if r.PostForm.Get("grant_type") != "client_credentials" {
+		fail(http.StatusBadRequest, "unsupported_grant_type")
+		return
+	}
+	id, secret, basic := clientCredentials(r)
+	var client models.ServiceClient
+	err := db.DB.Where("client_id = ? AND revoked_at IS NULL", id).First(&client).Error
+	if err != nil || id == "" ||
+		subtle.ConstantTimeCompare([]byte(utils.HashOpaqueToken(secret)), []byte(client.SecretHash)) != 1 {
+		if basic {
+			w.Header().Set("WWW-Authenticate", `Basic realm="token"`)
+		}
+		fail(http.StatusUnauthorized, "invalid_client")
+		return
+	}
+	allowed := strings.Fields(client.Scopes)
+	scopes := allowed
+	if requested := r.PostForm.Get("scope"); requested != "" {
+		scopes = strings.Fields(requested)
+		for _, s := range scopes {
+			if !slices.Contains(allowed, s) {
+				fail(http.StatusBadRequest, "invalid_scope")
+				return
+			}
+		}
+	}
+	token, err := utils.CreateServiceToken(client.ClientID, scopes, cfg.ServiceTokenTTL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	db.DB.Model(&client).Updates(map[string]interface{}{"last_used_at": time.Now(), "last_used_ip": remoteIP(r)})
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   int(cfg.ServiceTokenTTL.Seconds()),
+		"scope":        strings.Join(scopes, " "),
+	})
+}
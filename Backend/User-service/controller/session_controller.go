@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+	"user-service/db"
+	"user-service/models"
+	"user-service/utils"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// How a user signed in, recorded on sessions and login events. OIDC
+// logins are "oidc:<provider>".
+const (
+	loginMethodPassword     = "password"
+	loginMethodTOTP         = "totp"
+	loginMethodRecoveryCode = "recovery_code"
+)
+
+// logLogin records a sign-in attempt made with r. Failing to record it
+// does not fail the login.
+func logLogin(r *http.Request, e models.LoginEvent) {
+	e.IP = remoteIP(r)
+	e.UserAgent = truncate(r.UserAgent(), 255)
+	if err := db.DB.Create(&e).Error; err != nil {
+		log.Printf("could not record login of %s: %v", e.Email, err)
+	}
+}
+
+func loginFailed(r *http.Request, user *models.User, email, method, reason string) {
+	e := models.LoginEvent{Email: email, Method: method, Reason: reason}
+	if user != nil {
+		e.UserID, e.Email = &user.ID, user.Email
+	}
+	logLogin(r, e)
+}
+
+type sessionView struct {
+	models.Session
+	Current bool `json:"current"`
+}
+
+// activeSessions lists the sessions of userID that can still be
+// refreshed, most recently used first.
+func activeSessions(userID interface{}, currentID uint) ([]sessionView, error) {
+	var sessions []models.Session
+	err := db.DB.Where("user_id = ? AND revoked_at IS NULL AND last_used_at > ?", userID, time.Now().Add(-cfg.RefreshTokenTTL)).
+		Order("last_used_at DESC").Find(&sessions).Error
+	views := make([]sessionView, len(sessions))
+	for i, s := range sessions {
+		views[i] = sessionView{Session: s, Current: s.ID == currentID}
+	}
+	return views, err
+}
+
+// loginHistory returns the most recent login events of userID; ?before=<id>
+// pages back and ?limit caps the page at 200.
+func loginHistory(r *http.Request, userID interface{}) ([]models.LoginEvent, error) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	q := db.DB.Where("user_id = ?", userID)
+	if before, err := strconv.ParseUint(r.URL.Query().Get("before"), 10, 64); err == nil {
+		q = q.Where("id < ?", before)
+	}
+	var events []models.LoginEvent
+	err := q.Order("id DESC").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+func currentSessionID(r *http.Request) uint {
+	if claims, ok := r.Context().Value("claims").(*utils.AccessClaims); ok {
+		return claims.SessionID
+	}
+	return 0
+}
+
+// ListSessions shows the devices the current user is signed in on.
+func ListSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := activeSessions(r.Context().Value("userID"), currentSessionID(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(sessions)
+}
+
+// RevokeSession signs one of the current user's devices out.
+func RevokeSession(w http.ResponseWriter, r *http.Request) {
+	var session models.Session
+	err := db.DB.Where("id = ? AND user_id = ?", mux.Vars(r)["id"], r.Context().Value("userID")).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if session.RevokedAt == nil {
+		if err := revokeSession(db.DB, &session, "signed out by user", time.Now()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SignOutEverywhere signs the current user out on every device, this one
+// included: every session ends and every token issued so far stops
+// working.
+func SignOutEverywhere(w http.ResponseWriter, r *http.Request) {
+	user, err := currentUser(r)
+	if err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	if err := signOutEverywhere(db.DB, user.ID, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func signOutEverywhere(tx *gorm.DB, userID uint, now time.Time) error {
+	return tx.Transaction(func(tx *gorm.DB) error {
+		if err := revokeAllSessions(tx, userID, "signed out everywhere", now); err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("id = ?", userID).Update("tokens_invalid_before", now).Error
+	})
+}
+
+// ListLogins shows the current user's recent sign-in attempts.
+func ListLogins(w http.ResponseWriter, r *http.Request) {
+	events, err := loginHistory(r, r.Context().Value("userID"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(events)
+}
+
+// ListUserSessions lets support see where a user is signed in.
+func ListUserSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := activeSessions(mux.Vars(r)["id"], 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(sessions)
+}
+
+// ListUserLogins lets support see a user's recent sign-in attempts.
+func ListUserLogins(w http.ResponseWriter, r *http.Request) {
+	events, err := loginHistory(r, mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(events)
+}
@@ -0,0 +1,232 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+	"user-service/db"
+	"user-service/models"
+	"user-service/utils"
+
+	"github.com/gajare/BAJAR-App/Backend/pkg/authz"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errInvalidRefreshToken = errors.New("invalid refresh token")
+
+type tokenPair struct {
+	Token        string `json:"token"` // same as AccessToken, kept for older clients
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	// MFAEnrollmentRequired tells a privileged user to enrol in 2FA before
+	// the token grants their role's permissions.
+	MFAEnrollmentRequired bool `json:"mfa_enrollment_required,omitempty"`
+}
+
+// startSession opens a new session for user, records the login and
+// writes the first token pair. mfa records whether the user passed a
+// second factor; method is how they signed in.
+func startSession(w http.ResponseWriter, r *http.Request, user models.User, mfa bool, method string) {
+	if user.SuspendedAt != nil {
+		loginFailed(r, &user, user.Email, method, "suspended")
+		http.Error(w, "account suspended", http.StatusForbidden)
+		return
+	}
+	now := time.Now()
+	session := models.Session{UserID: user.ID, UserAgent: truncate(r.UserAgent(), 255), IP: remoteIP(r), LastUsedAt: now, MFA: mfa, Method: method}
+	var refresh string
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+		err := tx.Create(&models.LoginEvent{
+			UserID: &user.ID, Email: user.Email, Method: method, Success: true,
+			SessionID: &session.ID, IP: session.IP, UserAgent: session.UserAgent,
+		}).Error
+		if err != nil {
+			return err
+		}
+		refresh, err = issueRefreshToken(tx, session.ID, now)
+		return err
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeTokenPair(w, user, session, refresh)
+}
+
+func issueRefreshToken(tx *gorm.DB, sessionID uint, now time.Time) (string, error) {
+	token, hash, err := utils.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	err = tx.Create(&models.RefreshToken{SessionID: sessionID, TokenHash: hash, ExpiresAt: now.Add(cfg.RefreshTokenTTL)}).Error
+	return token, err
+}
+
+func writeTokenPair(w http.ResponseWriter, user models.User, session models.Session, refresh string) {
+	amr := []string{authz.AMRPassword}
+	if session.MFA {
+		amr = append(amr, authz.AMROTP)
+	}
+	perms := authz.Permissions(user.Role)
+	enroll := false
+	if mfaRequired(user) && !session.MFA {
+		perms = authz.Permissions(authz.RoleCustomer)
+		enroll = !user.TOTPEnabled
+	}
+	seller, err := sellerMembership(db.DB, user.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var access string
+	if seller != nil {
+		access, err = utils.CreateSellerAccessToken(user.ID, session.ID, user.Role, perms, amr, seller.SellerID, seller.Role, cfg.AccessTokenTTL)
+	} else {
+		access, err = utils.CreateAccessToken(user.ID, session.ID, user.Role, perms, amr, cfg.AccessTokenTTL)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(tokenPair{
+		Token:        access,
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(cfg.AccessTokenTTL.Seconds()),
+
+		MFAEnrollmentRequired: enroll,
+	})
+}
+
+// RefreshToken swaps a refresh token for a new access and refresh token.
+// Each refresh token works once; if an already rotated one shows up again
+// it has probably been stolen, so the whole session is revoked.
+func RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	var session models.Session
+	var refresh string
+	reused := false
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var current models.RefreshToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", utils.HashOpaqueToken(input.RefreshToken)).First(&current).Error; err != nil {
+			return errInvalidRefreshToken
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&session, current.SessionID).Error; err != nil {
+			return errInvalidRefreshToken
+		}
+		if session.RevokedAt != nil {
+			return errInvalidRefreshToken
+		}
+		if current.UsedAt != nil {
+			// Commit the revocation; the caller still gets an error.
+			reused = true
+			return revokeSession(tx, &session, "refresh token reuse", now)
+		}
+		if !now.Before(current.ExpiresAt) {
+			return errInvalidRefreshToken
+		}
+		if err := tx.Model(&current).Update("used_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&session).Update("last_used_at", now).Error; err != nil {
+			return err
+		}
+		var err error
+		refresh, err = issueRefreshToken(tx, session.ID, now)
+		return err
+	})
+	if reused {
+		log.Printf("refresh token reuse in session %d of user %d, session revoked", session.ID, session.UserID)
+		err = errInvalidRefreshToken
+	}
+	if errors.Is(err, errInvalidRefreshToken) {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// The new access token carries the user's current role.
+	var user models.User
+	if err := db.DB.First(&user, session.UserID).Error; err != nil {
+		http.Error(w, errInvalidRefreshToken.Error(), http.StatusUnauthorized)
+		return
+	}
+	writeTokenPair(w, user, session, refresh)
+}
+
+// Logout revokes the caller's session, or signs the user out everywhere
+// with ?all=true.
+func Logout(w http.ResponseWriter, r *http.Request) {
+	claims, _ := r.Context().Value("claims").(*utils.AccessClaims)
+	if claims == nil {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+	userID, _ := strconv.ParseUint(claims.Subject, 10, 64)
+	now := time.Now()
+	var err error
+	if r.URL.Query().Get("all") == "true" {
+		err = signOutEverywhere(db.DB, uint(userID), now)
+	} else {
+		err = db.DB.Model(&models.Session{}).Where("id = ? AND user_id = ? AND revoked_at IS NULL", claims.SessionID, userID).
+			Updates(map[string]interface{}{"revoked_at": now, "revoked_reason": "logout"}).Error
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func revokeSession(tx *gorm.DB, session *models.Session, reason string, now time.Time) error {
+	session.RevokedAt = &now
+	session.RevokedReason = reason
+	return tx.Model(session).Updates(map[string]interface{}{"revoked_at": now, "revoked_reason": reason}).Error
+}
+
+// revokeAllSessions ends every session of the user, e.g. after a password
+// reset.
+func revokeAllSessions(tx *gorm.DB, userID uint, reason string, now time.Time) error {
+	return tx.Model(&models.Session{}).Where("user_id = ? AND revoked_at IS NULL", userID).
+		Updates(map[string]interface{}{"revoked_at": now, "revoked_reason": reason}).Error
+}
+
+func remoteIP(r *http.Request) string {
+	return utils.ClientIP(r)
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// JWKS publishes the public keys access tokens are signed with.
+func JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(utils.JWKS())
+}
@@ -3,8 +3,13 @@ package controller
 import (
 	"encoding/json"
 	"net/http"
+	"time"
 	"user-service/db"
 	"user-service/models"
+
+	"github.com/gajare/BAJAR-App/Backend/pkg/authz"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
 )
 
 func GetProfile(w http.ResponseWriter, r *http.Request) {
@@ -18,11 +23,90 @@ func GetProfile(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(user)
 }
 
+// ListUsers searches users, a page at a time. ?q matches email or name,
+// ?email and ?name match just one, ?role is exact and ?status is active,
+// suspended or unverified.
 func ListUsers(w http.ResponseWriter, r *http.Request) {
+	page, limit := pageParams(r)
+	query := r.URL.Query()
+	q := db.DB.Model(&models.User{})
+	if v := query.Get("q"); v != "" {
+		q = q.Where("(LOWER(email) LIKE ? OR LOWER(name) LIKE ?)", likePattern(v), likePattern(v))
+	}
+	if v := query.Get("email"); v != "" {
+		q = q.Where("LOWER(email) LIKE ?", likePattern(v))
+	}
+	if v := query.Get("name"); v != "" {
+		q = q.Where("LOWER(name) LIKE ?", likePattern(v))
+	}
+	if v := query.Get("role"); v != "" {
+		q = q.Where("role = ?", v)
+	}
+	switch query.Get("status") {
+	case "":
+	case "active":
+		q = q.Where("suspended_at IS NULL")
+	case "suspended":
+		q = q.Where("suspended_at IS NOT NULL")
+	case "unverified":
+		q = q.Where("NOT email_verified")
+	default:
+		http.Error(w, "status must be active, suspended or unverified", http.StatusBadRequest)
+		return
+	}
+	var total int64
 	var users []models.User
-	db.DB.Find(&users)
+	if err := q.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := q.Order("id").Offset((page - 1) * limit).Limit(limit).Find(&users).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 	for i := range users {
 		users[i].Password = ""
 	}
-	json.NewEncoder(w).Encode(users)
+	json.NewEncoder(w).Encode(map[string]interface{}{"users": users, "total": total, "page": page, "limit": limit})
+}
+
+type roleRequest struct {
+	Role string `json:"role"`
+}
+
+// UpdateUserRole changes a user's role. The user's sessions are ended so
+// tokens carrying the old permissions stop working right away.
+func UpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	var req roleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	if !authz.ValidRole(req.Role) {
+		http.Error(w, "unknown role", http.StatusBadRequest)
+		return
+	}
+	var user models.User
+	if err := db.DB.First(&user, mux.Vars(r)["id"]).Error; err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	if user.Role != req.Role {
+		from := user.Role
+		err := db.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&user).Update("role", req.Role).Error; err != nil {
+				return err
+			}
+			if err := revokeAllSessions(tx, user.ID, "role changed", time.Now()); err != nil {
+				return err
+			}
+			return audit(tx, r, auditRoleChanged, user.ID, map[string]interface{}{"from": from, "to": req.Role})
+		})
+		if err != nil {
+			http.Error(w, "could not update role", http.StatusInternalServerError)
+			return
+		}
+	}
+	user.Password = ""
+	json.NewEncoder(w).Encode(user)
 }
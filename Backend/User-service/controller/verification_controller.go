@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+	"user-service/config"
+	"user-service/db"
+	"user-service/mailer"
+	"user-service/models"
+	"user-service/utils"
+)
+
+var cfg = &config.Config{
+	AccessTokenTTL:   15 * time.Minute,
+	RefreshTokenTTL:  30 * 24 * time.Hour,
+	VerificationTTL:  24 * time.Hour,
+	PasswordResetTTL: time.Hour,
+}
+
+// Init hands the controllers their configuration.
+func Init(c *config.Config) {
+	cfg = c
+	if c.BreachedPasswordsDir != "" {
+		breachList = utils.NewBreachList(c.BreachedPasswordsDir)
+	}
+}
+
+// resendLimiter allows a few verification emails per address, then one
+// every ten minutes.
+var resendLimiter = utils.NewKeyedLimiter(10*time.Minute, 3)
+
+func sendVerificationEmail(user models.User) error {
+	token, err := utils.CreatePurposeToken(utils.AudienceVerifyEmail, user.ID, user.Email, cfg.VerificationTTL)
+	if err != nil {
+		return err
+	}
+	link := cfg.AppBaseURL + "/verify-email?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening this link within %s:\n\n%s\n",
+		user.Name, cfg.VerificationTTL, link)
+	return mailer.Send(user.Email, "Verify your email address", body)
+}
+
+func VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	userID, email, err := utils.ParsePurposeToken(utils.AudienceVerifyEmail, r.URL.Query().Get("token"))
+	if err != nil {
+		http.Error(w, "invalid or expired verification link", http.StatusBadRequest)
+		return
+	}
+	var user models.User
+	// The link only counts for the address it was sent to.
+	if err := db.DB.First(&user, userID).Error; err != nil || user.Email != email {
+		http.Error(w, "invalid or expired verification link", http.StatusBadRequest)
+		return
+	}
+	if !user.EmailVerified {
+		now := time.Now()
+		if err := db.DB.Model(&user).Updates(map[string]interface{}{"email_verified": true, "email_verified_at": now}).Error; err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":             user.ID,
+		"email":          user.Email,
+		"email_verified": true,
+	})
+}
+
+// ResendVerification always answers 202 so it cannot be used to find out
+// which addresses have accounts.
+func ResendVerification(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	email, err := utils.NormalizeEmail(input.Email)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !resendLimiter.Allow(email) {
+		http.Error(w, "too many verification emails, try again later", http.StatusTooManyRequests)
+		return
+	}
+	var user models.User
+	if err := db.DB.Where("email = ?", email).First(&user).Error; err == nil && !user.EmailVerified {
+		if err := sendVerificationEmail(user); err != nil {
+			log.Printf("verification email to user %d failed: %v", user.ID, err)
+		}
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -1,9 +1,12 @@
 package db
 
 import (
+	"fmt"
 	"log"
+	"strings"
 	model "user-service/models"
 
+	"github.com/gajare/BAJAR-App/Backend/pkg/authz"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
@@ -16,5 +19,45 @@ func InitDB(dsn string) {
 	if err != nil {
 		log.Fatalf("failed to connect db: %v", err)
 	}
-	DB.AutoMigrate(&model.User{})
+	// Accounts created before email verification existed are trusted as
+	// verified rather than locked out.
+	backfillVerified := DB.Migrator().HasTable(&model.User{}) && !DB.Migrator().HasColumn(&model.User{}, "EmailVerified")
+	DB.AutoMigrate(&model.User{}, &model.PasswordReset{}, &model.Session{}, &model.RefreshToken{}, &model.SigningKey{}, &model.RecoveryCode{}, &model.UserIdentity{}, &model.OIDCLoginState{}, &model.Address{}, &model.OutboxEvent{}, &model.LoginThrottle{}, &model.LoginEvent{}, &model.AdminAuditLog{}, &model.ServiceClient{}, &model.Seller{}, &model.SellerMember{})
+	if backfillVerified {
+		DB.Exec("UPDATE users SET email_verified = true, email_verified_at = created_at")
+	}
+	if err := lowercaseEmails(DB); err != nil {
+		log.Fatalf("failed to normalize emails: %v", err)
+	}
+}
+
+// lowercaseEmails brings addresses stored before emails were normalized in
+// line with utils.NormalizeEmail, so those accounts can still sign in, and
+// keeps them unique regardless of case. Addresses that differ only in case
+// must be merged by hand first; until then the service refuses to start.
+func lowercaseEmails(db *gorm.DB) error {
+	var clashes []string
+	err := db.Raw(`SELECT lower(email) FROM users GROUP BY lower(email) HAVING count(*) > 1`).Scan(&clashes).Error
+	if err != nil {
+		return err
+	}
+	if len(clashes) > 0 {
+		return fmt.Errorf("accounts differ only in the case of their email: %s", strings.Join(clashes, ", "))
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`UPDATE users SET email = lower(email) WHERE email <> lower(email)`).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_lower ON users (lower(email))`).Error
+	})
+}
+
+// PromoteToAdmin gives the account with the given email the admin role.
+func PromoteToAdmin(email string) {
+	res := DB.Model(&model.User{}).Where("email = ?", strings.ToLower(strings.TrimSpace(email))).Update("role", authz.RoleAdmin)
+	if res.Error != nil {
+		log.Printf("could not promote %s to admin: %v", email, res.Error)
+	} else if res.RowsAffected == 0 {
+		log.Printf("bootstrap admin %s has no account yet", email)
+	}
 }
@@ -0,0 +1,66 @@
+// Package events publishes the service's domain events to other services.
+// Events are written to an outbox table in the same transaction as the
+// change they describe and delivered afterwards by a relay, so an event is
+// never lost or sent for a change that was rolled back. Delivery is at
+// least once; consumers dedupe on ID.
+package events
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+	"user-service/models"
+
+	"gorm.io/gorm"
+)
+
+// Event types.
+const (
+	UserDeleted = "user.deleted"
+
+	SellerVerified    = "seller.verified"
+	SellerSuspended   = "seller.suspended"
+	SellerReactivated = "seller.reactivated"
+)
+
+// Event is what subscribers receive.
+type Event struct {
+	ID         uint            `json:"id"`
+	Type       string          `json:"type"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+type Publisher interface {
+	Publish(e Event) error
+}
+
+// Log writes events to the log. It is the default until subscribers are
+// configured.
+type Log struct{}
+
+func (Log) Publish(e Event) error {
+	log.Printf("event %d %s: %s", e.ID, e.Type, e.Data)
+	return nil
+}
+
+// Default is used by the outbox relay.
+var Default Publisher = Log{}
+
+// New returns the publisher for kind: "webhook" (POSTing to urls, signed
+// with secret) or "log".
+func New(kind string, urls []string, secret string) Publisher {
+	if kind == "webhook" {
+		return NewWebhook(urls, secret)
+	}
+	return Log{}
+}
+
+// Record adds an event to the outbox within tx.
+func Record(tx *gorm.DB, typ string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&models.OutboxEvent{Type: typ, Payload: string(payload)}).Error
+}
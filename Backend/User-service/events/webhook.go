@@ -0,0 +1,56 @@
+package events
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Webhook POSTs each event as JSON to every URL. With a secret, the body
+// is signed in the X-Event-Signature header ("sha256=" + hex HMAC) so
+// subscribers can check it came from us.
+type Webhook struct {
+	URLs   []string
+	Secret string
+	Client *http.Client
+}
+
+func NewWebhook(urls []string, secret string) *Webhook {
+	return &Webhook{URLs: urls, Secret: secret, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Publish fails if any subscriber does not answer 2xx; the relay then
+// retries the event for all of them.
+func (h *Webhook) Publish(e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	for _, url := range h.URLs {
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Event-Type", e.Type)
+		if h.Secret != "" {
+			mac := hmac.New(sha256.New, []byte(h.Secret))
+			mac.Write(body)
+			req.Header.Set("X-Event-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		}
+		resp, err := h.Client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("%s answered %s", url, resp.Status)
+		}
+	}
+	return nil
+}
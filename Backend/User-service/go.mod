@@ -3,8 +3,10 @@ module user-service
 go 1.24.6
 
 require (
+	github.com/gajare/BAJAR-App/Backend/pkg v0.0.0
 	github.com/golang-jwt/jwt/v4 v4.5.2
 	github.com/gorilla/mux v1.8.1
+	github.com/redis/go-redis/v9 v9.22.0
 	golang.org/x/crypto v0.42.0
 	golang.org/x/time v0.13.0
 	gorm.io/driver/postgres v1.6.0
@@ -12,12 +14,17 @@ require (
 )
 
 require (
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
 	github.com/jackc/pgpassfile v1.0.0 // indirect
 	github.com/jackc/pgservicefile v0.0.0-20240606120523-5a60cdf6a761 // indirect
 	github.com/jackc/pgx/v5 v5.6.0 // indirect
 	github.com/jackc/puddle/v2 v2.2.2 // indirect
 	github.com/jinzhu/inflection v1.0.0 // indirect
 	github.com/jinzhu/now v1.1.5 // indirect
+	go.uber.org/atomic v1.11.0 // indirect
 	golang.org/x/sync v0.17.0 // indirect
+	golang.org/x/sys v0.36.0 // indirect
 	golang.org/x/text v0.29.0 // indirect
 )
+
+replace github.com/gajare/BAJAR-App/Backend/pkg => ../pkg
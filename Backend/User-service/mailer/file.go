@@ -0,0 +1,33 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// File writes each message to its own file in Dir, for local development
+// where the links in emails need to be clicked.
+type File struct {
+	Dir string
+}
+
+func (f File) Send(to, subject, body string) error {
+	if err := os.MkdirAll(f.Dir, 0o700); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), sanitize(to))
+	msg := fmt.Sprintf("To: %s\r\nSubject: %s\r\nDate: %s\r\n\r\n%s", to, subject, time.Now().Format(time.RFC1123Z), body)
+	return os.WriteFile(filepath.Join(f.Dir, name), []byte(msg), 0o600)
+}
+
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '@' || r == '.' || r == '-' || r == '_' || ('a' <= r && r <= 'z') || ('0' <= r && r <= '9') {
+			return r
+		}
+		return '_'
+	}, strings.ToLower(s))
+}
@@ -0,0 +1,32 @@
+// Package mailer sends the service's transactional email.
+package mailer
+
+import "log"
+
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// Console writes messages to the log instead of sending them. It is the
+// default until a real mailer is configured.
+type Console struct{}
+
+func (Console) Send(to, subject, body string) error {
+	log.Printf("mail to %s: %s\n%s", to, subject, body)
+	return nil
+}
+
+// Default is used by Send.
+var Default Mailer = Console{}
+
+// New returns the mailer for kind: "file" (writing to dir) or "console".
+func New(kind, dir string) Mailer {
+	if kind == "file" {
+		return File{Dir: dir}
+	}
+	return Console{}
+}
+
+func Send(to, subject, body string) error {
+	return Default.Send(to, subject, body)
+}
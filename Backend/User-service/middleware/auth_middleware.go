@@ -3,25 +3,119 @@ package middleware
 import (
 	"context"
 	"net/http"
+	"strconv"
 	"strings"
 
+	"github.com/gajare/BAJAR-App/Backend/pkg/authz"
+
+	"user-service/db"
+	"user-service/models"
 	"user-service/utils"
 )
 
+// JwtAuthMiddleware accepts user tokens only; see ServiceOrUserAuth.
 func JwtAuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		auth := r.Header.Get("Authorization")
-		if auth == "" || !strings.HasPrefix(auth, "Bearer ") {
-			http.Error(w, "missing token", http.StatusUnauthorized)
+		claims, ok := bearerClaims(w, r)
+		if !ok {
+			return
+		}
+		if claims.ClientID != "" {
+			http.Error(w, "service tokens are not accepted here", http.StatusForbidden)
 			return
 		}
-		tokenStr := strings.TrimPrefix(auth, "Bearer ")
-		claims, err := utils.ParseToken(tokenStr)
-		if err != nil {
+		if revoked(claims) {
 			http.Error(w, "invalid token", http.StatusUnauthorized)
 			return
 		}
 		ctx := context.WithValue(r.Context(), "userID", claims.Subject)
+		ctx = context.WithValue(ctx, "claims", claims)
+		userID, _ := strconv.ParseUint(claims.Subject, 10, 64)
+		mfa := false
+		for _, m := range claims.AMR {
+			mfa = mfa || m == authz.AMROTP
+		}
+		ctx = authz.WithIdentity(ctx, authz.Identity{
+			UserID:      userID,
+			Role:        claims.Role,
+			Permissions: claims.Permissions,
+			SessionID:   uint64(claims.SessionID),
+			MFA:         mfa,
+			SellerID:    uint64(claims.SellerID),
+			SellerRole:  claims.SellerRole,
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// ServiceOrUserAuth accepts service tokens as well as user tokens. Routes
+// behind it must authorize with authz.FromContext alone: a service has no
+// user, so "userID" is not set for it.
+func ServiceOrUserAuth(next http.Handler) http.Handler {
+	users := JwtAuthMiddleware(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, err := utils.ParseToken(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		if err != nil || claims.ClientID == "" {
+			users.ServeHTTP(w, r)
+			return
+		}
+		var client models.ServiceClient
+		if err := db.DB.Select("id").Where("client_id = ? AND revoked_at IS NULL", claims.ClientID).First(&client).Error; err != nil {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		ctx := authz.WithIdentity(r.Context(), authz.Identity{ClientID: claims.ClientID, Permissions: claims.Permissions})
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+func bearerClaims(w http.ResponseWriter, r *http.Request) (*utils.AccessClaims, bool) {
+	auth := r.Header.Get("Authorization")
+	if auth == "" || !strings.HasPrefix(auth, "Bearer ") {
+		http.Error(w, "missing token", http.StatusUnauthorized)
+		return nil, false
+	}
+	claims, err := utils.ParseToken(strings.TrimPrefix(auth, "Bearer "))
+	if err != nil {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return nil, false
+	}
+	return claims, true
+}
+
+// RequireVerifiedEmail must run after JwtAuthMiddleware; it rejects users
+// who have not verified their email address.
+func RequireVerifiedEmail(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var user models.User
+		if err := db.DB.Select("id", "email_verified").First(&user, r.Context().Value("userID")).Error; err != nil {
+			http.Error(w, "user not found", http.StatusUnauthorized)
+			return
+		}
+		if !user.EmailVerified {
+			http.Error(w, "email not verified", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// revoked reports whether the token's session was revoked, the token was
+// issued before the user's tokens were invalidated (e.g. by a password
+// reset), or the user is gone.
+func revoked(claims *utils.AccessClaims) bool {
+	if claims.SessionID != 0 {
+		var session models.Session
+		if err := db.DB.Select("id", "revoked_at").First(&session, claims.SessionID).Error; err != nil || session.RevokedAt != nil {
+			return true
+		}
+	}
+	var user models.User
+	if err := db.DB.Select("id", "tokens_invalid_before").First(&user, claims.Subject).Error; err != nil {
+		return true
+	}
+	if user.TokensInvalidBefore == nil {
+		return false
+	}
+	return claims.IssuedBefore(*user.TokensInvalidBefore)
+}
@@ -1,39 +1,81 @@
 package middleware
 
 import (
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
-	"golang.org/x/time/rate"
+	"user-service/ratelimit"
+	"user-service/utils"
 )
 
-type client struct {
-	limiter  *rate.Limiter
-	lastSeen time.Time
-}
+var (
+	rateMu       sync.RWMutex
+	rateStore    ratelimit.Store = ratelimit.NewMemoryStore()
+	ratePolicies                 = []ratelimit.Policy{{Method: "*", Path: "*", Limit: ratelimit.Limit{Count: 5, Period: 5 * time.Second}, Key: ratelimit.KeyIP}}
+)
 
-var clients = make(map[string]*client)
-var mu sync.Mutex
+// SetRateLimits sets where limits are kept and the policies
+// RateLimitMiddleware applies.
+func SetRateLimits(store ratelimit.Store, policies []ratelimit.Policy) {
+	rateMu.Lock()
+	defer rateMu.Unlock()
+	rateStore, ratePolicies = store, policies
+}
 
-func getLimiter(ip string) *rate.Limiter {
-	mu.Lock()
-	defer mu.Unlock()
-	c, exists := clients[ip]
-	if !exists {
-		lim := rate.NewLimiter(1, 5)
-		clients[ip] = &client{limiter: lim, lastSeen: time.Now()}
-		return lim
+// rateIdentity is who a request counts against under p: the signed-in
+// user or service client when p asks for one and the request's token
+// verifies, otherwise the client IP. Only verified tokens count, since a
+// caller can put anything in an unverified header and get a fresh limit.
+func rateIdentity(r *http.Request, p ratelimit.Policy) string {
+	if p.Key == ratelimit.KeyUser || p.Key == ratelimit.KeyAPIKey {
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			if claims, err := utils.ParseToken(strings.TrimPrefix(auth, "Bearer ")); err == nil {
+				if p.Key == ratelimit.KeyUser {
+					return "user:" + claims.Subject
+				}
+				if claims.ClientID != "" {
+					return "client:" + claims.ClientID
+				}
+			}
+		}
 	}
-	c.lastSeen = time.Now()
-	return c.limiter
+	return "ip:" + utils.ClientIP(r)
+}
+
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
 }
 
+// RateLimitMiddleware applies the most specific matching policy and sets
+// the RateLimit-* headers. If the store fails, requests are let through.
 func RateLimitMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ip := r.RemoteAddr
-		lim := getLimiter(ip)
-		if !lim.Allow() {
+		rateMu.RLock()
+		store, policies := rateStore, ratePolicies
+		rateMu.RUnlock()
+		p, ok := ratelimit.Match(policies, r.Method, r.URL.Path)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		res, err := store.Take(r.Context(), p.Name()+"|"+rateIdentity(r, p), p.Limit, time.Now())
+		if err != nil {
+			log.Printf("rate limit store: %v", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+		h := w.Header()
+		h.Set("RateLimit-Policy", p.Limit.String())
+		h.Set("RateLimit-Limit", strconv.Itoa(p.Limit.Count))
+		h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		h.Set("RateLimit-Reset", seconds(res.Reset))
+		if !res.Allowed {
+			h.Set("Retry-After", seconds(res.RetryAfter))
 			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
 			return
 		}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Address is an entry in a user's address book. Deleted addresses are
+// kept (soft delete) so orders that refer to one can still read it.
+type Address struct {
+	ID              uint           `gorm:"primaryKey" json:"id"`
+	UserID          uint           `gorm:"not null;index" json:"user_id"`
+	Label           string         `gorm:"size:50" json:"label"`
+	RecipientName   string         `gorm:"size:100;not null" json:"recipient_name"`
+	Phone           string         `gorm:"size:20;not null" json:"phone"`
+	Line1           string         `gorm:"size:200;not null" json:"line1"`
+	Line2           string         `gorm:"size:200" json:"line2,omitempty"`
+	City            string         `gorm:"size:100;not null" json:"city"`
+	State           string         `gorm:"size:100" json:"state,omitempty"`
+	PostalCode      string         `gorm:"size:12" json:"postal_code"`
+	Country         string         `gorm:"size:2;not null" json:"country"`
+	DefaultShipping bool           `gorm:"not null;default:false" json:"default_shipping"`
+	DefaultBilling  bool           `gorm:"not null;default:false" json:"default_billing"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// AddressSnapshot is the copy of an address an order keeps, so later
+// edits to the address book do not change where the order went.
+type AddressSnapshot struct {
+	AddressID     uint   `json:"address_id"`
+	RecipientName string `json:"recipient_name"`
+	Phone         string `json:"phone"`
+	Line1         string `json:"line1"`
+	Line2         string `json:"line2,omitempty"`
+	City          string `json:"city"`
+	State         string `json:"state,omitempty"`
+	PostalCode    string `json:"postal_code"`
+	Country       string `json:"country"`
+}
+
+func (a Address) Snapshot() AddressSnapshot {
+	return AddressSnapshot{
+		AddressID:     a.ID,
+		RecipientName: a.RecipientName,
+		Phone:         a.Phone,
+		Line1:         a.Line1,
+		Line2:         a.Line2,
+		City:          a.City,
+		State:         a.State,
+		PostalCode:    a.PostalCode,
+		Country:       a.Country,
+	}
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// AdminAuditLog records one action an admin took on a user account.
+// Details is a JSON object describing the change.
+type AdminAuditLog struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	ActorID      uint      `gorm:"not null;index" json:"actor_id"`
+	Action       string    `gorm:"size:50;not null" json:"action"`
+	TargetUserID uint      `gorm:"not null;index" json:"target_user_id"`
+	Details      string    `gorm:"type:text" json:"details,omitempty"`
+	IP           string    `gorm:"size:45" json:"ip"`
+	CreatedAt    time.Time `gorm:"index" json:"created_at"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// UserIdentity links a user to an account at an external OIDC provider.
+// A user may have several; Subject is the provider's stable user ID.
+type UserIdentity struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"not null;index" json:"user_id"`
+	Provider    string     `gorm:"size:50;not null;uniqueIndex:idx_identity_provider_subject" json:"provider"`
+	Subject     string     `gorm:"size:255;not null;uniqueIndex:idx_identity_provider_subject" json:"-"`
+	Email       string     `gorm:"size:100" json:"email"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+}
+
+// OIDCLoginState is one sign-in attempt at a provider, stored by the hash
+// of its state parameter. LinkUserID is set when a signed-in user is adding
+// the identity to their account rather than logging in.
+type OIDCLoginState struct {
+	ID           uint      `gorm:"primaryKey"`
+	StateHash    string    `gorm:"size:64;uniqueIndex;not null"`
+	Provider     string    `gorm:"size:50;not null"`
+	Nonce        string    `gorm:"size:64;not null"`
+	CodeVerifier string    `gorm:"size:64;not null"`
+	LinkUserID   uint      `gorm:"not null;default:0"`
+	ExpiresAt    time.Time `gorm:"not null"`
+	UsedAt       *time.Time
+	CreatedAt    time.Time
+}
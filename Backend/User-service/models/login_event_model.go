@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// LoginEvent records one sign-in attempt, successful or not. UserID is
+// nil when the email matched no account.
+type LoginEvent struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    *uint     `gorm:"index" json:"user_id,omitempty"`
+	Email     string    `gorm:"size:100" json:"email"`
+	Method    string    `gorm:"size:50" json:"method"`
+	Success   bool      `gorm:"not null" json:"success"`
+	Reason    string    `gorm:"size:50" json:"reason,omitempty"`
+	SessionID *uint     `json:"session_id,omitempty"`
+	IP        string    `gorm:"size:45" json:"ip"`
+	UserAgent string    `gorm:"size:255" json:"user_agent"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
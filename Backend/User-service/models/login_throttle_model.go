@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// LoginThrottle counts recent failed logins for a key, "email:<address>"
+// or "ip:<address>". It lives in the database so restarts do not reset
+// it.
+type LoginThrottle struct {
+	Key           string `gorm:"primaryKey;size:150"`
+	Failures      int    `gorm:"not null;default:0"`
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+}
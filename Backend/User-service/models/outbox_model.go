@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// OutboxEvent is a domain event waiting to be (or already) published; see
+// package events.
+type OutboxEvent struct {
+	ID          uint   `gorm:"primaryKey"`
+	Type        string `gorm:"size:50;not null"`
+	Payload     string `gorm:"type:text;not null"`
+	CreatedAt   time.Time
+	PublishedAt *time.Time `gorm:"index"`
+	Attempts    int        `gorm:"not null;default:0"`
+	LastError   string     `gorm:"size:500"`
+}
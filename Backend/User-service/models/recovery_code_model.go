@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// RecoveryCode is a hashed one-time code that stands in for a TOTP code
+// when the user has lost their authenticator.
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"size:64;not null;index" json:"-"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// KYC states of a seller. Only verified sellers that are not suspended can
+// sell: their members' access tokens carry the seller (see authz.Claims).
+const (
+	KYCPending  = "pending" // waiting for review
+	KYCVerified = "verified"
+	KYCRejected = "rejected" // see KYCNote; editing the details resubmits
+)
+
+// Seller is a third-party business selling on the marketplace. PAN and
+// GSTIN are its Indian tax registrations; the address is where it is
+// registered.
+type Seller struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	Name            string     `gorm:"size:100;not null" json:"name"`
+	LegalName       string     `gorm:"size:200;not null" json:"legal_name"`
+	PAN             string     `gorm:"size:10;not null" json:"pan"`
+	GSTIN           string     `gorm:"size:15" json:"gstin,omitempty"`
+	Email           string     `gorm:"size:255;not null" json:"email"`
+	Phone           string     `gorm:"size:20;not null" json:"phone"`
+	Line1           string     `gorm:"size:200;not null" json:"line1"`
+	Line2           string     `gorm:"size:200" json:"line2,omitempty"`
+	City            string     `gorm:"size:100;not null" json:"city"`
+	State           string     `gorm:"size:100" json:"state,omitempty"`
+	PostalCode      string     `gorm:"size:12" json:"postal_code"`
+	Country         string     `gorm:"size:2;not null" json:"country"`
+	KYCStatus       string     `gorm:"size:20;not null;default:pending;index" json:"kyc_status"`
+	KYCNote         string     `gorm:"size:255" json:"kyc_note,omitempty"`
+	KYCReviewedAt   *time.Time `json:"kyc_reviewed_at,omitempty"`
+	KYCReviewedBy   *uint      `json:"kyc_reviewed_by,omitempty"`
+	SuspendedAt     *time.Time `json:"suspended_at,omitempty"`
+	SuspendedReason string     `gorm:"size:255" json:"suspended_reason,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// Active reports whether the seller may sell.
+func (s Seller) Active() bool {
+	return s.KYCStatus == KYCVerified && s.SuspendedAt == nil
+}
+
+// SellerMember is a user working for a seller in one of the authz
+// SellerRole roles. A user works for one seller at most.
+type SellerMember struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	SellerID  uint      `gorm:"not null;index" json:"seller_id"`
+	UserID    uint      `gorm:"not null;uniqueIndex" json:"user_id"`
+	Role      string    `gorm:"size:20;not null" json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
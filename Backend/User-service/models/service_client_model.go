@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// ServiceClient is a machine credential another service uses to get
+// tokens with the client credentials grant. Only a hash of the secret is
+// stored. Scopes is a space separated list of the permissions its tokens
+// may carry.
+type ServiceClient struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	Name       string     `gorm:"size:100;not null" json:"name"`
+	ClientID   string     `gorm:"size:40;uniqueIndex;not null" json:"client_id"`
+	SecretHash string     `gorm:"size:64;not null" json:"-"`
+	Scopes     string     `gorm:"size:500" json:"scope"`
+	CreatedBy  uint       `gorm:"not null" json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `gorm:"size:45" json:"last_used_ip,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Session is one login. Its refresh tokens form a family: each refresh
+// replaces the current token with a new one, and presenting a replaced
+// token again revokes the whole session.
+type Session struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	UserID        uint       `gorm:"not null;index" json:"user_id"`
+	UserAgent     string     `gorm:"size:255" json:"user_agent,omitempty"`
+	IP            string     `gorm:"size:45" json:"ip,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	LastUsedAt    time.Time  `json:"last_used_at"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+	RevokedReason string     `gorm:"size:50" json:"revoked_reason,omitempty"`
+	// MFA is set when the user passed a second factor for this session.
+	MFA bool `gorm:"not null;default:false" json:"mfa"`
+	// Method is how the user signed in, e.g. "password" or "oidc:google".
+	Method string `gorm:"size:50" json:"method,omitempty"`
+}
+
+// RefreshToken is stored as a hash. UsedAt is set when it is rotated.
+type RefreshToken struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	SessionID uint       `gorm:"not null;index" json:"session_id"`
+	TokenHash string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// SigningKey is a key access tokens are signed with. It is published from
+// creation, signs from ActivatesAt until a newer key activates, and is kept
+// until every token it signed has expired.
+type SigningKey struct {
+	ID          uint      `gorm:"primaryKey"`
+	KID         string    `gorm:"size:32;uniqueIndex;not null"`
+	Algorithm   string    `gorm:"size:10;not null"`
+	PrivateKey  string    `gorm:"type:text;not null"` // PKCS#8 PEM
+	ActivatesAt time.Time `gorm:"not null;index"`
+	CreatedAt   time.Time
+}
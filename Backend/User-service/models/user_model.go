@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type User struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
@@ -9,4 +13,34 @@ type User struct {
 	Name      string    `gorm:"size:100;not null" json:"name"`
 	Email     string    `gorm:"size:100;uniqueIndex;not null" json:"email"`
 	Password  string    `gorm:"size:255;not null" json:"-"`
+	Role      string    `gorm:"size:30;not null;default:'customer'" json:"role"`
+	Phone     string    `gorm:"size:20" json:"phone,omitempty"`
+	AvatarURL string    `gorm:"size:500" json:"avatar_url,omitempty"`
+
+	EmailVerified   bool       `gorm:"not null;default:false" json:"email_verified"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+
+	// TokensInvalidBefore revokes every access token issued before it,
+	// e.g. after a password reset.
+	TokensInvalidBefore *time.Time `json:"-"`
+
+	// TOTPSecret is set at enrolment and only used once TOTPEnabled is
+	// confirmed. TOTPLastStep is the time step of the last accepted code,
+	// so a code cannot be replayed. MFARequired is set by an admin to
+	// require 2FA regardless of role.
+	TOTPSecret   string `gorm:"size:64" json:"-"`
+	TOTPEnabled  bool   `gorm:"not null;default:false" json:"totp_enabled"`
+	TOTPLastStep int64  `gorm:"not null;default:0" json:"-"`
+	MFARequired  bool   `gorm:"not null;default:false" json:"mfa_required"`
+
+	// SuspendedAt is set while an admin has suspended the account; a
+	// suspended user cannot sign in. PasswordResetRequired makes login
+	// refuse the current password until a new one is set by reset link.
+	SuspendedAt           *time.Time `gorm:"index" json:"suspended_at,omitempty"`
+	SuspendedReason       string     `gorm:"size:255" json:"suspended_reason,omitempty"`
+	PasswordResetRequired bool       `gorm:"not null;default:false" json:"password_reset_required"`
+
+	// DeletedAt is set when the account is deleted; the row is kept,
+	// anonymised, so other records can still refer to it.
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
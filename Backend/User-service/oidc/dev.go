@@ -0,0 +1,165 @@
+package oidc
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gajare/BAJAR-App/Backend/pkg/authz"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// DevProvider is a stand-in OpenID provider for local development and
+// tests. It signs in whoever it is asked to: /authorize takes the email
+// from login_hint (or asks for one) and redirects straight back with a
+// code. Any client ID and secret are accepted; PKCE is enforced.
+type DevProvider struct {
+	Issuer string
+
+	key ed25519.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]devCode
+}
+
+type devCode struct {
+	clientID    string
+	redirectURI string
+	challenge   string
+	nonce       string
+	email       string
+	expiresAt   time.Time
+}
+
+const devKeyID = "dev"
+
+func NewDevProvider(issuer string) (*DevProvider, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &DevProvider{Issuer: strings.TrimRight(issuer, "/"), key: key, codes: map[string]devCode{}}, nil
+}
+
+func (d *DevProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		json.NewEncoder(w).Encode(endpoints{
+			Issuer:        d.Issuer,
+			Authorization: d.Issuer + "/authorize",
+			Token:         d.Issuer + "/token",
+			JWKS:          d.Issuer + "/jwks",
+		})
+	case "/jwks":
+		jwk, _ := authz.NewJWK(devKeyID, d.key.Public())
+		json.NewEncoder(w).Encode(authz.JWKSet{Keys: []authz.JWK{jwk}})
+	case "/authorize":
+		d.authorize(w, r)
+	case "/token":
+		d.token(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+var devLoginForm = template.Must(template.New("login").Parse(`<!doctype html>
+<title>Dev sign-in</title>
+<form method="get">
+{{range $k, $v := .}}{{range $v}}<input type="hidden" name="{{$k}}" value="{{.}}">{{end}}{{end}}
+<label>Email <input name="login_hint" type="email" required></label>
+<button>Sign in</button>
+</form>`))
+
+func (d *DevProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		http.Error(w, "code flow with S256 PKCE required", http.StatusBadRequest)
+		return
+	}
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || redirect.Scheme == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	email := strings.TrimSpace(q.Get("login_hint"))
+	if email == "" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		devLoginForm.Execute(w, q)
+		return
+	}
+	code, err := RandomString()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	d.mu.Lock()
+	d.codes[code] = devCode{
+		clientID:    q.Get("client_id"),
+		redirectURI: redirect.String(),
+		challenge:   q.Get("code_challenge"),
+		nonce:       q.Get("nonce"),
+		email:       strings.ToLower(email),
+		expiresAt:   time.Now().Add(time.Minute),
+	}
+	d.mu.Unlock()
+	back := redirect.Query()
+	back.Set("code", code)
+	back.Set("state", q.Get("state"))
+	redirect.RawQuery = back.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (d *DevProvider) token(w http.ResponseWriter, r *http.Request) {
+	fail := func(reason string) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": reason})
+	}
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		fail("unsupported_grant_type")
+		return
+	}
+	d.mu.Lock()
+	c, ok := d.codes[r.PostForm.Get("code")]
+	delete(d.codes, r.PostForm.Get("code"))
+	d.mu.Unlock()
+	if !ok || time.Now().After(c.expiresAt) || c.clientID != r.PostForm.Get("client_id") ||
+		c.redirectURI != r.PostForm.Get("redirect_uri") || S256(r.PostForm.Get("code_verifier")) != c.challenge {
+		fail("invalid_grant")
+		return
+	}
+	now := time.Now()
+	tok := jwt.NewWithClaims(jwt.SigningMethodEdDSA, IDClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    d.Issuer,
+			Subject:   "dev|" + c.email,
+			Audience:  jwt.ClaimStrings{c.clientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(5 * time.Minute)),
+		},
+		Nonce:         c.nonce,
+		Email:         c.email,
+		EmailVerified: true,
+		Name:          strings.SplitN(c.email, "@", 2)[0],
+	})
+	tok.Header["kid"] = devKeyID
+	idToken, err := tok.SignedString(d.key)
+	if err != nil {
+		fail("server_error")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": idToken,
+		"token_type":   "Bearer",
+		"id_token":     idToken,
+		"expires_in":   300,
+	})
+}
@@ -0,0 +1,202 @@
+// Package oidc signs users in with an external OpenID Connect provider
+// using the authorization code flow with PKCE.
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gajare/BAJAR-App/Backend/pkg/authz"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var ErrInvalidIDToken = errors.New("invalid id token")
+
+// Provider is one configured OIDC provider. Its endpoints are discovered
+// from the issuer on first use, so a provider that is down at startup (or
+// served by this process, like the dev provider) does not stop the
+// service from starting.
+type Provider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+
+	client *http.Client
+
+	mu        sync.Mutex
+	endpoints *endpoints
+	keys      *authz.KeySet
+}
+
+type endpoints struct {
+	Issuer        string `json:"issuer"`
+	Authorization string `json:"authorization_endpoint"`
+	Token         string `json:"token_endpoint"`
+	JWKS          string `json:"jwks_uri"`
+}
+
+// IDClaims are the ID token claims a login needs.
+type IDClaims struct {
+	jwt.RegisteredClaims
+	Nonce         string   `json:"nonce"`
+	Email         string   `json:"email"`
+	EmailVerified flexBool `json:"email_verified"`
+	Name          string   `json:"name"`
+}
+
+// flexBool accepts true and "true": some providers send email_verified as
+// a string.
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	*b = flexBool(s == "true")
+	return nil
+}
+
+func NewProvider(name, issuer, clientID, clientSecret, redirectURL string) *Provider {
+	return &Provider{
+		Name:         name,
+		Issuer:       strings.TrimRight(issuer, "/"),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       []string{"openid", "email", "profile"},
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *Provider) discover() (*endpoints, *authz.KeySet, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.endpoints != nil {
+		return p.endpoints, p.keys, nil
+	}
+	resp, err := p.client.Get(p.Issuer + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("%s discovery: %s", p.Name, resp.Status)
+	}
+	var e endpoints
+	if err := json.NewDecoder(resp.Body).Decode(&e); err != nil {
+		return nil, nil, err
+	}
+	if strings.TrimRight(e.Issuer, "/") != p.Issuer || e.Authorization == "" || e.Token == "" || e.JWKS == "" {
+		return nil, nil, fmt.Errorf("%s discovery: incomplete or mismatched document", p.Name)
+	}
+	p.endpoints, p.keys = &e, authz.NewKeySet(e.JWKS, time.Hour)
+	return p.endpoints, p.keys, nil
+}
+
+// AuthCodeURL is where the browser is sent to sign in.
+func (p *Provider) AuthCodeURL(state, nonce, codeChallenge string) (string, error) {
+	e, _, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.ClientID)
+	q.Set("redirect_uri", p.RedirectURL)
+	q.Set("scope", strings.Join(p.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", codeChallenge)
+	q.Set("code_challenge_method", "S256")
+	sep := "?"
+	if strings.Contains(e.Authorization, "?") {
+		sep = "&"
+	}
+	return e.Authorization + sep + q.Encode(), nil
+}
+
+// Exchange redeems an authorization code and returns the raw ID token.
+func (p *Provider) Exchange(code, codeVerifier string) (string, error) {
+	e, _, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("client_id", p.ClientID)
+	form.Set("client_secret", p.ClientSecret)
+	form.Set("code_verifier", codeVerifier)
+	resp, err := p.client.PostForm(e.Token, form)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var body struct {
+		IDToken string `json:"id_token"`
+		Error   string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("%s token endpoint: %s", p.Name, resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK || body.IDToken == "" {
+		return "", fmt.Errorf("%s token endpoint: %s %s", p.Name, resp.Status, body.Error)
+	}
+	return body.IDToken, nil
+}
+
+// VerifyIDToken checks the ID token's signature against the provider's
+// JWKS, its issuer, audience and expiry, and that it carries nonce.
+func (p *Provider) VerifyIDToken(raw, nonce string) (*IDClaims, error) {
+	_, keys, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+	var c IDClaims
+	tok, err := jwt.ParseWithClaims(raw, &c, authz.KeyFunc(keys.Lookup))
+	if err != nil || !tok.Valid {
+		return nil, ErrInvalidIDToken
+	}
+	if strings.TrimRight(c.Issuer, "/") != p.Issuer || !c.VerifyAudience(p.ClientID, true) || c.ExpiresAt == nil {
+		return nil, ErrInvalidIDToken
+	}
+	if nonce == "" || c.Nonce != nonce || c.Subject == "" {
+		return nil, ErrInvalidIDToken
+	}
+	return &c, nil
+}
+
+// NewPKCE returns a code verifier and its S256 challenge.
+func NewPKCE() (verifier, challenge string, err error) {
+	verifier, err = RandomString()
+	if err != nil {
+		return "", "", err
+	}
+	return verifier, S256(verifier), nil
+}
+
+func S256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// RandomString returns 256 random bits, base64url encoded; used for state,
+// nonce and PKCE verifiers.
+func RandomString() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
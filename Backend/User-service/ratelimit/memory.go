@@ -0,0 +1,61 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps limits in this process. Run evicts keys whose limit
+// is fully available again, so idle clients do not pile up.
+type MemoryStore struct {
+	mu   sync.Mutex
+	tats map[string]time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{tats: map[string]time.Time{}}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, l Limit, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tat, res := gcra(s.tats[key], now, l)
+	s.tats[key] = tat
+	return res, nil
+}
+
+// Evict drops the keys that no longer hold back any request at now.
+func (s *MemoryStore) Evict(now time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for key, tat := range s.tats {
+		if !tat.After(now) {
+			delete(s.tats, key)
+			n++
+		}
+	}
+	return n
+}
+
+// Len is the number of keys held.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.tats)
+}
+
+// Run calls Evict every interval until ctx is done.
+func (s *MemoryStore) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			s.Evict(now)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strings"
+)
+
+// What a policy counts requests by. KeyAPIKey counts by the service
+// client of a verified service token. Requests without a verified user or
+// client token are counted by IP.
+const (
+	KeyIP     = "ip"
+	KeyUser   = "user"
+	KeyAPIKey = "apikey"
+)
+
+// Policy limits the requests matching Method and Path. Method "*" matches
+// any method; a Path ending in "*" matches by prefix and "*" alone
+// matches every path.
+type Policy struct {
+	Method string
+	Path   string
+	Limit  Limit
+	Key    string
+}
+
+// Name identifies the policy's counters in the store.
+func (p Policy) Name() string {
+	return p.Method + " " + p.Path
+}
+
+func (p Policy) matches(method, path string) bool {
+	if p.Method != "*" && p.Method != method {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(p.Path, "*"); ok {
+		return strings.HasPrefix(path, prefix)
+	}
+	return p.Path == path
+}
+
+// specificity orders matching policies: exact paths beat prefixes, longer
+// prefixes beat shorter ones, and a method beats "*".
+func (p Policy) specificity() int {
+	n := 2 * len(p.Path)
+	if !strings.HasSuffix(p.Path, "*") {
+		n += 100000
+	}
+	if p.Method != "*" {
+		n++
+	}
+	return n
+}
+
+// ParsePolicies reads policies separated by ";", each "METHOD PATH LIMIT
+// [KEY]", e.g. "* * 5/5s ip; POST /login 10/m; * /api/* 300/m user". KEY
+// defaults to ip.
+func ParsePolicies(spec string) ([]Policy, error) {
+	var policies []Policy
+	for _, entry := range strings.Split(spec, ";") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 3 || len(fields) > 4 {
+			return nil, fmt.Errorf("invalid rate limit policy %q", strings.TrimSpace(entry))
+		}
+		limit, err := ParseLimit(fields[2])
+		if err != nil {
+			return nil, err
+		}
+		p := Policy{Method: strings.ToUpper(fields[0]), Path: fields[1], Limit: limit, Key: KeyIP}
+		if len(fields) == 4 {
+			p.Key = strings.ToLower(fields[3])
+		}
+		if p.Key != KeyIP && p.Key != KeyUser && p.Key != KeyAPIKey {
+			return nil, fmt.Errorf("invalid rate limit key %q", fields[3])
+		}
+		policies = append(policies, p)
+	}
+	return policies, nil
+}
+
+// Match returns the most specific policy for a request, if any.
+func Match(policies []Policy, method, path string) (Policy, bool) {
+	var best Policy
+	found := false
+	for _, p := range policies {
+		if p.matches(method, path) && (!found || p.specificity() > best.specificity()) {
+			best, found = p, true
+		}
+	}
+	return best, found
+}
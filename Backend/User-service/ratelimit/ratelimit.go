@@ -0,0 +1,88 @@
+// Package ratelimit limits request rates per route and per client. Limits
+// are kept in a Store; the Redis store lets several instances share them.
+//
+// Limits use GCRA (the generic cell rate algorithm): a limit of N per
+// period allows a burst of N and then one request every period/N. Only a
+// single timestamp is stored per key.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit is Count requests per Period.
+type Limit struct {
+	Count  int
+	Period time.Duration
+}
+
+func (l Limit) interval() time.Duration {
+	return l.Period / time.Duration(l.Count)
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d;w=%d", l.Count, int(l.Period.Seconds()))
+}
+
+// Result is the outcome of taking a request from a limit. Reset is how
+// long until the limit is fully available again.
+type Result struct {
+	Allowed    bool
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// Store keeps the state of every key.
+type Store interface {
+	// Take counts one request against key and reports whether it is
+	// within l.
+	Take(ctx context.Context, key string, l Limit, now time.Time) (Result, error)
+}
+
+// gcra applies one request at now to a key whose theoretical arrival time
+// is tat. It returns the new tat, which is unchanged when the request is
+// refused.
+func gcra(tat, now time.Time, l Limit) (time.Time, Result) {
+	if tat.Before(now) {
+		tat = now
+	}
+	next := tat.Add(l.interval())
+	if allowAt := next.Add(-l.Period); now.Before(allowAt) {
+		return tat, Result{Reset: tat.Sub(now), RetryAfter: allowAt.Sub(now)}
+	}
+	return next, Result{
+		Allowed:   true,
+		Remaining: int((l.Period - next.Sub(now)) / l.interval()),
+		Reset:     next.Sub(now),
+	}
+}
+
+// ParseLimit reads "N/period", where period is s, m, h or a duration
+// such as 10m: "5/s", "100/m", "3/10m".
+func ParseLimit(s string) (Limit, error) {
+	count, period, ok := strings.Cut(strings.TrimSpace(s), "/")
+	n, err := strconv.Atoi(count)
+	if !ok || err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("invalid limit %q", s)
+	}
+	var d time.Duration
+	switch period {
+	case "s":
+		d = time.Second
+	case "m":
+		d = time.Minute
+	case "h":
+		d = time.Hour
+	default:
+		d, err = time.ParseDuration(period)
+		if err != nil || d <= 0 {
+			return Limit{}, fmt.Errorf("invalid limit %q", s)
+		}
+	}
+	return Limit{Count: n, Period: d}, nil
+}
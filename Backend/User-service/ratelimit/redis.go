@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// gcraScript is gcra run atomically in Redis. Times are in microseconds,
+// stored with %.0f since Lua would print them in exponent form; the key
+// expires when its limit is fully available again.
+var gcraScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local period = tonumber(ARGV[3])
+local tat = tonumber(redis.call("GET", KEYS[1]) or now)
+if tat < now then tat = now end
+local next = tat + interval
+local allow_at = next - period
+if now < allow_at then
+	return {0, 0, tat - now, allow_at - now}
+end
+redis.call("SET", KEYS[1], string.format("%.0f", next), "PX", math.ceil((next - now) / 1000))
+return {1, math.floor((period - (next - now)) / interval), next - now, 0}
+`)
+
+// RedisStore keeps limits in Redis so every instance of the service
+// shares them. Keys expire on their own.
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisStore connects to url, e.g. redis://localhost:6379/0.
+func NewRedisStore(url string) (*RedisStore, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisStore{client: redis.NewClient(opts), prefix: "ratelimit:"}, nil
+}
+
+func (s *RedisStore) Take(ctx context.Context, key string, l Limit, now time.Time) (Result, error) {
+	v, err := gcraScript.Run(ctx, s.client, []string{s.prefix + key},
+		now.UnixMicro(), l.interval().Microseconds(), l.Period.Microseconds()).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{
+		Allowed:    v[0] == 1,
+		Remaining:  int(v[1]),
+		Reset:      time.Duration(v[2]) * time.Microsecond,
+		RetryAfter: time.Duration(v[3]) * time.Microsecond,
+	}, nil
+}
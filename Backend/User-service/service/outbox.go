@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+	"user-service/db"
+	"user-service/events"
+	"user-service/models"
+)
+
+// RelayEvents publishes up to limit unpublished outbox events in order and
+// stops at the first failure, so subscribers see events in order.
+func RelayEvents(limit int) (int, error) {
+	var pending []models.OutboxEvent
+	if err := db.DB.Where("published_at IS NULL").Order("id").Limit(limit).Find(&pending).Error; err != nil {
+		return 0, err
+	}
+	for i, o := range pending {
+		err := events.Default.Publish(events.Event{ID: o.ID, Type: o.Type, OccurredAt: o.CreatedAt, Data: json.RawMessage(o.Payload)})
+		if err != nil {
+			db.DB.Model(&o).Updates(map[string]interface{}{"attempts": o.Attempts + 1, "last_error": truncate(err.Error(), 500)})
+			return i, err
+		}
+		if err := db.DB.Model(&o).Updates(map[string]interface{}{"published_at": time.Now(), "attempts": o.Attempts + 1, "last_error": ""}).Error; err != nil {
+			return i, err
+		}
+	}
+	return len(pending), nil
+}
+
+// RunOutboxRelay calls RelayEvents every interval until ctx is done.
+func RunOutboxRelay(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if _, err := RelayEvents(100); err != nil {
+				log.Printf("event relay: %v", err)
+			}
+		}
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+	"user-service/config"
+	"user-service/db"
+	"user-service/models"
+	"user-service/utils"
+)
+
+// RotateSigningKeys keeps the key set current and loads it into utils:
+//   - with no usable key, one is created that signs right away;
+//   - KeyPublishAhead before the active key is KeyRotationInterval old, the
+//     next key is created so other services cache it before it signs;
+//   - a key is dropped once a newer key has been signing for longer than
+//     any token it signed can live.
+//
+// Every instance runs this; an instance picks up keys another one created
+// on its next run, well before they activate.
+func RotateSigningKeys(cfg *config.Config, now time.Time) error {
+	var keys []models.SigningKey
+	if err := db.DB.Order("activates_at").Find(&keys).Error; err != nil {
+		return err
+	}
+	var active *models.SigningKey
+	pending := false
+	for i := range keys {
+		if keys[i].ActivatesAt.After(now) {
+			pending = true
+		} else {
+			active = &keys[i]
+		}
+	}
+	switch {
+	case active == nil:
+		k, err := createSigningKey(cfg.JWTAlgorithm, now)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, k)
+	case !pending && !now.Before(active.ActivatesAt.Add(cfg.KeyRotationInterval-cfg.KeyPublishAhead)):
+		next := active.ActivatesAt.Add(cfg.KeyRotationInterval)
+		if earliest := now.Add(cfg.KeyPublishAhead); next.Before(earliest) {
+			next = earliest
+		}
+		k, err := createSigningKey(cfg.JWTAlgorithm, next)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, k)
+	}
+
+	retention := cfg.AccessTokenTTL
+	if cfg.VerificationTTL > retention {
+		retention = cfg.VerificationTTL
+	}
+	loaded := make([]utils.SigningKey, 0, len(keys))
+	for i, k := range keys {
+		// keys is ordered by activation, so the next key is the one that
+		// took over from k
+		if i+1 < len(keys) && keys[i+1].ActivatesAt.Add(retention).Before(now) {
+			if err := db.DB.Delete(&models.SigningKey{}, k.ID).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		sk, err := utils.ParseSigningKey(k.KID, k.Algorithm, k.PrivateKey, k.ActivatesAt)
+		if err != nil {
+			return err
+		}
+		loaded = append(loaded, sk)
+	}
+	utils.SetSigningKeys(loaded)
+	return nil
+}
+
+func createSigningKey(alg string, activatesAt time.Time) (models.SigningKey, error) {
+	k, err := utils.NewSigningKey(alg, activatesAt)
+	if err != nil {
+		return models.SigningKey{}, err
+	}
+	pem, err := k.PrivatePEM()
+	if err != nil {
+		return models.SigningKey{}, err
+	}
+	row := models.SigningKey{KID: k.KID, Algorithm: alg, PrivateKey: pem, ActivatesAt: activatesAt}
+	if err := db.DB.Create(&row).Error; err != nil {
+		return models.SigningKey{}, err
+	}
+	log.Printf("created signing key %s (%s), signs from %s", k.KID, alg, activatesAt.Format(time.RFC3339))
+	return row, nil
+}
+
+// RunKeyRotation calls RotateSigningKeys every interval until ctx is done.
+func RunKeyRotation(ctx context.Context, cfg *config.Config, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			if err := RotateSigningKeys(cfg, now); err != nil {
+				log.Printf("signing key rotation: %v", err)
+			}
+		}
+	}
+}
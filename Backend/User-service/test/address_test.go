@@ -0,0 +1,58 @@
+package test
+
+import (
+	"testing"
+	"user-service/models"
+	"user-service/utils"
+)
+
+func TestNormalizePostalCode(t *testing.T) {
+	good := []struct{ country, in, want string }{
+		{"IN", " 560001 ", "560001"},
+		{"US", "94105-1234", "94105-1234"},
+		{"GB", "sw1a1aa", "SW1A 1AA"},
+		{"CA", "k1a 0b1", "K1A 0B1"},
+		{"AE", "", ""},
+		{"NL", "1012 ab", "1012 AB"},
+	}
+	for _, c := range good {
+		got, err := utils.NormalizePostalCode(c.country, c.in)
+		if err != nil || got != c.want {
+			t.Errorf("NormalizePostalCode(%s, %q) = %q, %v; want %q", c.country, c.in, got, err, c.want)
+		}
+	}
+	bad := []struct{ country, in string }{
+		{"IN", "056001"}, {"IN", "56001"}, {"US", "9410"}, {"GB", "12345"}, {"AE", "12345"}, {"NL", ""},
+	}
+	for _, c := range bad {
+		if _, err := utils.NormalizePostalCode(c.country, c.in); err == nil {
+			t.Errorf("NormalizePostalCode(%s, %q) should fail", c.country, c.in)
+		}
+	}
+}
+
+func TestNormalizePhoneAndCountry(t *testing.T) {
+	if got, err := utils.NormalizePhone("+91 98765-43210"); err != nil || got != "+919876543210" {
+		t.Errorf("NormalizePhone = %q, %v", got, err)
+	}
+	for _, bad := range []string{"", "12345", "call me", "+91 98765 43210 1234 5"} {
+		if _, err := utils.NormalizePhone(bad); err == nil {
+			t.Errorf("NormalizePhone(%q) should fail", bad)
+		}
+	}
+	if got, err := utils.NormalizeCountry(" in"); err != nil || got != "IN" {
+		t.Errorf("NormalizeCountry = %q, %v", got, err)
+	}
+	if _, err := utils.NormalizeCountry("IND"); err == nil {
+		t.Error("three-letter country accepted")
+	}
+}
+
+func TestAddressSnapshot(t *testing.T) {
+	a := models.Address{ID: 3, UserID: 7, Label: "Home", RecipientName: "Alice", Phone: "+919876543210",
+		Line1: "1 MG Road", City: "Bengaluru", PostalCode: "560001", Country: "IN", DefaultShipping: true}
+	s := a.Snapshot()
+	if s.AddressID != 3 || s.RecipientName != "Alice" || s.Line1 != "1 MG Road" || s.PostalCode != "560001" || s.Country != "IN" {
+		t.Errorf("Snapshot = %+v", s)
+	}
+}
@@ -0,0 +1,46 @@
+package test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"user-service/events"
+)
+
+func TestWebhookPublisher(t *testing.T) {
+	var got events.Event
+	var signature string
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &got)
+		mac := hmac.New(sha256.New, []byte("s3cret"))
+		mac.Write(body)
+		if r.Header.Get("X-Event-Signature") != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		signature = r.Header.Get("X-Event-Signature")
+	}))
+	defer ok.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	e := events.Event{ID: 9, Type: events.UserDeleted, OccurredAt: time.Now(), Data: json.RawMessage(`{"user_id":7}`)}
+	if err := events.NewWebhook([]string{ok.URL}, "s3cret").Publish(e); err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != 9 || got.Type != events.UserDeleted || string(got.Data) != `{"user_id":7}` || signature == "" {
+		t.Errorf("subscriber got %+v (signature %q)", got, signature)
+	}
+	if err := events.NewWebhook([]string{ok.URL, down.URL}, "s3cret").Publish(e); err == nil {
+		t.Error("failed delivery reported as published")
+	}
+}
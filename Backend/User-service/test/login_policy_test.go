@@ -0,0 +1,58 @@
+package test
+
+import (
+	"testing"
+	"time"
+	"user-service/models"
+	"user-service/utils"
+)
+
+var testLoginPolicy = utils.LoginPolicy{DelayAfter: 3, MaxDelay: 8 * time.Second, LockAfter: 6, LockFor: 15 * time.Minute, Window: time.Hour}
+
+func TestLoginPolicyDelaysThenLocks(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	var th models.LoginThrottle
+	for i := 1; i <= 2; i++ {
+		testLoginPolicy.Fail(&th, now)
+		if wait, _ := testLoginPolicy.RetryAfter(th, now); wait != 0 {
+			t.Fatalf("after %d failures: wait %s, want none", i, wait)
+		}
+	}
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		if testLoginPolicy.Fail(&th, now) {
+			t.Fatalf("locked after %d failures", th.Failures)
+		}
+		if wait, locked := testLoginPolicy.RetryAfter(th, now); wait != want || locked {
+			t.Fatalf("failure %d: wait %s locked %v, want %s", i+3, wait, locked, want)
+		}
+	}
+	if !testLoginPolicy.Fail(&th, now) {
+		t.Fatal("not locked after LockAfter failures")
+	}
+	if wait, locked := testLoginPolicy.RetryAfter(th, now.Add(time.Minute)); !locked || wait != 14*time.Minute {
+		t.Fatalf("wait %s locked %v during lockout", wait, locked)
+	}
+	after := now.Add(16 * time.Minute)
+	if wait, _ := testLoginPolicy.RetryAfter(th, after); wait != 0 {
+		t.Fatalf("wait %s after the lock ran out", wait)
+	}
+	testLoginPolicy.Fail(&th, after)
+	if th.Failures != 1 || th.LockedUntil != nil {
+		t.Fatalf("count not restarted after lockout: %+v", th)
+	}
+}
+
+func TestLoginPolicyForgetsOldFailures(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	var th models.LoginThrottle
+	for i := 0; i < 5; i++ {
+		testLoginPolicy.Fail(&th, now)
+	}
+	if wait, _ := testLoginPolicy.RetryAfter(th, now.Add(2*time.Hour)); wait != 0 {
+		t.Fatalf("wait %s after the window", wait)
+	}
+	testLoginPolicy.Fail(&th, now.Add(2*time.Hour))
+	if th.Failures != 1 {
+		t.Fatalf("failures = %d, want 1", th.Failures)
+	}
+}
@@ -0,0 +1,90 @@
+package test
+
+import (
+	"encoding/base32"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"user-service/controller"
+	"user-service/utils"
+
+	"github.com/gajare/BAJAR-App/Backend/pkg/authz"
+)
+
+func TestTOTP(t *testing.T) {
+	// RFC 6238 appendix B, SHA1, truncated to six digits.
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	vectors := map[int64]string{59: "287082", 1111111109: "081804", 1234567890: "005924", 2000000000: "279037"}
+	for unix, want := range vectors {
+		got, err := utils.TOTPCode(secret, time.Unix(unix, 0))
+		if err != nil || got != want {
+			t.Errorf("TOTPCode at %d = %q, %v; want %q", unix, got, err, want)
+		}
+	}
+
+	now := time.Unix(1111111109, 0)
+	step, ok := utils.ValidateTOTP(secret, "081804", now)
+	if !ok || step != 1111111109/30 {
+		t.Fatalf("ValidateTOTP = %d, %v", step, ok)
+	}
+	if _, ok := utils.ValidateTOTP(secret, "081804", now.Add(30*time.Second)); !ok {
+		t.Error("code from the previous step rejected")
+	}
+	if _, ok := utils.ValidateTOTP(secret, "081804", now.Add(2*time.Minute)); ok {
+		t.Error("stale code accepted")
+	}
+	if _, ok := utils.ValidateTOTP(secret, "", now); ok {
+		t.Error("empty code accepted")
+	}
+
+	uri := utils.TOTPURI("BAJAR", "alice@example.com", secret)
+	if !strings.HasPrefix(uri, "otpauth://totp/BAJAR:alice@example.com?") || !strings.Contains(uri, "secret="+secret) {
+		t.Errorf("TOTPURI = %s", uri)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := utils.NewRecoveryCodes(10)
+	if err != nil || len(codes) != 10 || len(hashes) != 10 {
+		t.Fatalf("NewRecoveryCodes = %d codes, %v", len(codes), err)
+	}
+	seen := map[string]bool{}
+	for i, c := range codes {
+		if seen[c] {
+			t.Errorf("duplicate code %s", c)
+		}
+		seen[c] = true
+		if utils.HashRecoveryCode(" "+strings.ToUpper(strings.ReplaceAll(c, "-", ""))) != hashes[i] {
+			t.Errorf("code %s does not match its hash when typed differently", c)
+		}
+	}
+}
+
+func TestTokenWithoutRequiredMFAGrantsNothing(t *testing.T) {
+	useSigningKeys(t, authz.AlgEdDSA)
+	jwks := httptest.NewServer(http.HandlerFunc(controller.JWKS))
+	defer jwks.Close()
+	v := authz.NewJWKSVerifier(jwks.URL, time.Minute)
+
+	pending, _ := utils.CreateAccessToken(7, 1, authz.RoleAdmin, nil, []string{authz.AMRPassword}, time.Minute)
+	c, err := v.Verify(pending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := c.Identity()
+	if id.Role != authz.RoleAdmin || len(id.Permissions) != 0 || id.MFA {
+		t.Errorf("admin without 2FA: %+v", id)
+	}
+
+	full, _ := utils.CreateAccessToken(7, 1, authz.RoleAdmin, authz.Permissions(authz.RoleAdmin), []string{authz.AMRPassword, authz.AMROTP}, time.Minute)
+	c, err = v.Verify(full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ = c.Identity()
+	if !id.Can(authz.PermUsersWrite) || !id.MFA {
+		t.Errorf("admin with 2FA: %+v", id)
+	}
+}
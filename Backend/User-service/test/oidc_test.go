@@ -0,0 +1,75 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"user-service/oidc"
+)
+
+// devAuthorize runs the browser leg against the dev provider and returns
+// the code and state it redirects back with.
+func devAuthorize(t *testing.T, authURL, email string) (code, state string) {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL + "&login_hint=" + url.QueryEscape(email))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize: status %d", resp.StatusCode)
+	}
+	back, _ := url.Parse(resp.Header.Get("Location"))
+	return back.Query().Get("code"), back.Query().Get("state")
+}
+
+func TestOIDCCodeFlowWithDevProvider(t *testing.T) {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	dev, err := oidc.NewDevProvider(srv.URL + "/dev-oidc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.Handle("/dev-oidc/", http.StripPrefix("/dev-oidc", dev))
+	p := oidc.NewProvider("dev", dev.Issuer, "user-service", "", "http://app.test/oidc/dev/callback")
+
+	verifier, challenge, _ := oidc.NewPKCE()
+	authURL, err := p.AuthCodeURL("state-1", "nonce-1", challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state := devAuthorize(t, authURL, "Alice@Example.com")
+	if state != "state-1" || code == "" {
+		t.Fatalf("callback code %q state %q", code, state)
+	}
+	idToken, err := p.Exchange(code, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := p.VerifyIDToken(idToken, "nonce-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Email != "alice@example.com" || !bool(claims.EmailVerified) || claims.Subject == "" {
+		t.Errorf("claims = %+v", claims)
+	}
+
+	if _, err := p.VerifyIDToken(idToken, "other-nonce"); err == nil {
+		t.Error("ID token accepted with the wrong nonce")
+	}
+	other := oidc.NewProvider("dev", dev.Issuer, "another-client", "", "http://app.test/oidc/dev/callback")
+	if _, err := other.VerifyIDToken(idToken, "nonce-1"); err == nil {
+		t.Error("ID token accepted for another client")
+	}
+	if _, err := p.Exchange(code, verifier); err == nil {
+		t.Error("authorization code redeemed twice")
+	}
+
+	code, _ = devAuthorize(t, authURL, "alice@example.com")
+	if _, err := p.Exchange(code, "wrong-verifier"); err == nil {
+		t.Error("code redeemed without the PKCE verifier")
+	}
+}
@@ -0,0 +1,39 @@
+package test
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"user-service/mailer"
+	"user-service/utils"
+)
+
+func TestOpaqueTokenHash(t *testing.T) {
+	token, hash, err := utils.NewOpaqueToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(token) < 40 || hash == token || utils.HashOpaqueToken(token) != hash {
+		t.Errorf("token %q hash %q", token, hash)
+	}
+	other, _, _ := utils.NewOpaqueToken()
+	if other == token {
+		t.Error("tokens should be random")
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	m := mailer.New("file", dir)
+	if err := m.Send("Alice@Example.com", "Reset your password", "open https://example.com/reset"); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 || !strings.HasSuffix(files[0].Name(), "alice@example.com.eml") {
+		t.Fatalf("unexpected files %v", files)
+	}
+	msg, _ := os.ReadFile(dir + "/" + files[0].Name())
+	if !strings.Contains(string(msg), "Subject: Reset your password") || !strings.Contains(string(msg), "https://example.com/reset") {
+		t.Errorf("unexpected message %q", msg)
+	}
+}
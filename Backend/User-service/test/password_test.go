@@ -0,0 +1,88 @@
+package test
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"user-service/utils"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	p := utils.PasswordPolicy{MinLength: 8, MaxBytes: 72, MinClasses: 3, DisallowPersonal: true}
+	if err := p.Check("Correct-Horse9", "alice@example.com", "Alice Smith"); err != nil {
+		t.Errorf("good password refused: %v", err)
+	}
+	bad := map[string]string{
+		"":                        "empty",
+		"Ab1!":                    "too short",
+		"alllowercase":            "one class",
+		"Smith-2024x":             "contains the name",
+		"Xalice-99":               "contains the email",
+		strings.Repeat("Aa1", 30): "too long",
+	}
+	for pw, why := range bad {
+		if err := p.Check(pw, "alice@example.com", "Alice Smith"); err == nil {
+			t.Errorf("%q accepted (%s)", pw, why)
+		}
+	}
+}
+
+func TestBreachListRangeFiles(t *testing.T) {
+	dir := t.TempDir()
+	sum := sha1.Sum([]byte("password1"))
+	digest := strings.ToUpper(hex.EncodeToString(sum[:]))
+	body := "0000000000000000000000000000000000A:3\r\n" + digest[5:] + ":2413945\r\n"
+	if err := os.WriteFile(filepath.Join(dir, digest[:5]+".txt"), []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	list := utils.NewBreachList(dir)
+	if breached, err := list.Breached("password1"); err != nil || !breached {
+		t.Errorf("password1: %v, %v", breached, err)
+	}
+	if breached, err := list.Breached("a much rarer passphrase"); err != nil || breached {
+		t.Errorf("rare password: %v, %v", breached, err)
+	}
+}
+
+func TestPasswordHashUpgrade(t *testing.T) {
+	defer utils.SetPasswordHashing(utils.HashBcrypt, bcrypt.DefaultCost)
+
+	if err := utils.SetPasswordHashing(utils.HashBcrypt, bcrypt.MinCost); err != nil {
+		t.Fatal(err)
+	}
+	old, err := utils.HashPassword("s3cret-pass")
+	if err != nil || utils.NeedsRehash(old) {
+		t.Fatalf("fresh bcrypt hash: %v, rehash %v", err, utils.NeedsRehash(old))
+	}
+	if _, err := utils.HashPassword(""); err == nil {
+		t.Error("empty password hashed")
+	}
+
+	utils.SetPasswordHashing(utils.HashBcrypt, bcrypt.MinCost+1)
+	if !utils.NeedsRehash(old) {
+		t.Error("lower bcrypt cost not flagged for rehash")
+	}
+
+	utils.SetPasswordHashing(utils.HashArgon2id, bcrypt.MinCost)
+	if !utils.NeedsRehash(old) {
+		t.Error("bcrypt hash not flagged when argon2id is configured")
+	}
+	upgraded, err := utils.HashPassword("s3cret-pass")
+	if err != nil || !strings.HasPrefix(upgraded, "$argon2id$v=19$") || utils.NeedsRehash(upgraded) {
+		t.Fatalf("argon2id hash %q: %v", upgraded, err)
+	}
+	if utils.CheckPassword(upgraded, "s3cret-pass") != nil || utils.CheckPassword(upgraded, "wrong") == nil {
+		t.Error("argon2id hash does not verify correctly")
+	}
+	if utils.CheckPassword(old, "s3cret-pass") != nil {
+		t.Error("old bcrypt hash stopped working")
+	}
+	if err := utils.SetPasswordHashing("md5", 10); err == nil {
+		t.Error("unknown algorithm accepted")
+	}
+}
@@ -0,0 +1,142 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"user-service/middleware"
+	"user-service/ratelimit"
+	"user-service/utils"
+
+	"github.com/gajare/BAJAR-App/Backend/pkg/authz"
+)
+
+func TestMemoryStoreBurstThenRate(t *testing.T) {
+	s := ratelimit.NewMemoryStore()
+	l := ratelimit.Limit{Count: 3, Period: 3 * time.Second}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 2; i >= 0; i-- {
+		res, _ := s.Take(context.Background(), "k", l, now)
+		if !res.Allowed || res.Remaining != i {
+			t.Fatalf("burst request: %+v, want remaining %d", res, i)
+		}
+	}
+	res, _ := s.Take(context.Background(), "k", l, now)
+	if res.Allowed || res.RetryAfter != time.Second {
+		t.Fatalf("over the limit: %+v", res)
+	}
+	if res, _ := s.Take(context.Background(), "k", l, now.Add(time.Second)); !res.Allowed {
+		t.Fatalf("refused after one interval: %+v", res)
+	}
+	if res, _ := s.Take(context.Background(), "other", l, now); !res.Allowed {
+		t.Fatal("keys are not independent")
+	}
+	if n := s.Evict(now.Add(10 * time.Second)); n != 2 || s.Len() != 0 {
+		t.Fatalf("evicted %d, %d left", n, s.Len())
+	}
+}
+
+func TestParseAndMatchPolicies(t *testing.T) {
+	policies, err := ratelimit.ParsePolicies("* * 5/5s; POST /login 10/m ip; * /api/* 300/m user; GET /api/me/* 1/h apikey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct{ method, path, want string }{
+		{"POST", "/login", "POST /login"},
+		{"GET", "/login", "* *"},
+		{"POST", "/login/2fa", "* *"},
+		{"GET", "/api/users", "* /api/*"},
+		{"GET", "/api/me/sessions", "GET /api/me/*"},
+		{"DELETE", "/api/me/sessions", "* /api/*"},
+	}
+	for _, c := range cases {
+		p, ok := ratelimit.Match(policies, c.method, c.path)
+		if !ok || p.Name() != c.want {
+			t.Errorf("%s %s matched %q, want %q", c.method, c.path, p.Name(), c.want)
+		}
+	}
+	for _, bad := range []string{"* *", "* * 5", "* * 0/s", "* * 5/s token", "* * x/m"} {
+		if _, err := ratelimit.ParsePolicies(bad); err == nil {
+			t.Errorf("ParsePolicies(%q) should fail", bad)
+		}
+	}
+}
+
+func TestClientIPTrustsOnlyConfiguredProxies(t *testing.T) {
+	if err := utils.SetTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"}); err != nil {
+		t.Fatal(err)
+	}
+	defer utils.SetTrustedProxies(nil)
+	req := func(remote, xff string) *http.Request {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = remote
+		if xff != "" {
+			r.Header.Set("X-Forwarded-For", xff)
+		}
+		return r
+	}
+	cases := []struct{ remote, xff, want string }{
+		{"203.0.113.5:1234", "1.2.3.4", "203.0.113.5"},
+		{"10.1.2.3:1234", "198.51.100.7", "198.51.100.7"},
+		{"10.1.2.3:1234", "6.6.6.6, 198.51.100.7, 192.168.1.1", "198.51.100.7"},
+		{"10.1.2.3:1234", "10.9.9.9", "10.9.9.9"},
+		{"10.1.2.3:1234", "", "10.1.2.3"},
+	}
+	for _, c := range cases {
+		if got := utils.ClientIP(req(c.remote, c.xff)); got != c.want {
+			t.Errorf("ClientIP(%s, %q) = %s, want %s", c.remote, c.xff, got, c.want)
+		}
+	}
+}
+
+func TestRateLimitMiddlewareHeaders(t *testing.T) {
+	policies, _ := ratelimit.ParsePolicies("* * 2/m ip")
+	middleware.SetRateLimits(ratelimit.NewMemoryStore(), policies)
+	defer middleware.SetRateLimits(ratelimit.NewMemoryStore(), nil)
+	handler := middleware.RateLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	var w *httptest.ResponseRecorder
+	for i := 0; i < 3; i++ {
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	}
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("third request: %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "30" || w.Header().Get("RateLimit-Remaining") != "0" || w.Header().Get("RateLimit-Policy") != "2;w=60" {
+		t.Errorf("headers: %v", w.Header())
+	}
+}
+
+func TestRotatingAPIKeyDoesNotEscapeTheLimit(t *testing.T) {
+	useSigningKeys(t, authz.AlgEdDSA)
+	policies, _ := ratelimit.ParsePolicies("* * 2/m apikey")
+	middleware.SetRateLimits(ratelimit.NewMemoryStore(), policies)
+	defer middleware.SetRateLimits(ratelimit.NewMemoryStore(), nil)
+	handler := middleware.RateLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve := func(key, token string) int {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-API-Key", key)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Without a verified token the header is ignored and the IP counts.
+	for i, key := range []string{"a", "b", "c"} {
+		if code := serve(key, ""); (code == http.StatusTooManyRequests) != (i == 2) {
+			t.Fatalf("request %d with key %q: %d", i, key, code)
+		}
+	}
+	// A service client has its own limit, whatever key it sends.
+	token, _ := utils.CreateServiceToken("svc_orders", nil, time.Minute)
+	for i, key := range []string{"d", "e", "f"} {
+		if code := serve(key, token); (code == http.StatusTooManyRequests) != (i == 2) {
+			t.Fatalf("service request %d with key %q: %d", i, key, code)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"user-service/controller"
+	"user-service/utils"
+
+	"github.com/gajare/BAJAR-App/Backend/pkg/authz"
+)
+
+func TestSellerTaxIDs(t *testing.T) {
+	pan, err := utils.NormalizePAN(" aapfu0939f ")
+	if err != nil || pan != "AAPFU0939F" {
+		t.Fatalf("NormalizePAN = %q, %v", pan, err)
+	}
+	for _, bad := range []string{"", "AAPFU0939", "AAPF10939F", "AAPFU0939FF"} {
+		if _, err := utils.NormalizePAN(bad); err == nil {
+			t.Errorf("NormalizePAN(%q) should fail", bad)
+		}
+	}
+
+	if got, err := utils.NormalizeGSTIN("27aapfu0939f1zv", pan); err != nil || got != "27AAPFU0939F1ZV" {
+		t.Errorf("NormalizeGSTIN = %q, %v", got, err)
+	}
+	bad := []struct{ gstin, pan string }{
+		{"27AAPFU0939F1ZW", pan},          // wrong check character
+		{"27AAPFU0939F1ZV", "AAPFU0939G"}, // someone else's PAN
+		{"27AAPFU0939F1YV", pan},
+		{"27AAPFU0939F1Z", pan},
+	}
+	for _, c := range bad {
+		if _, err := utils.NormalizeGSTIN(c.gstin, c.pan); err == nil {
+			t.Errorf("NormalizeGSTIN(%q, %q) should fail", c.gstin, c.pan)
+		}
+	}
+}
+
+func TestSellerTokenCarriesSeller(t *testing.T) {
+	useSigningKeys(t, authz.AlgEdDSA)
+	jwks := httptest.NewServer(http.HandlerFunc(controller.JWKS))
+	defer jwks.Close()
+
+	token, err := utils.CreateSellerAccessToken(7, 42, authz.RoleCustomer, nil, nil, 3, authz.SellerRoleCatalog, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := authz.NewJWKSVerifier(jwks.URL, time.Minute).Verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := claims.Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.UserID != 7 || id.SellerID != 3 || !id.CanForSeller(3, authz.PermCatalogWrite) || id.CanForSeller(4, authz.PermCatalogWrite) {
+		t.Fatalf("identity = %+v", id)
+	}
+}
@@ -0,0 +1,155 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"user-service/controller"
+	"user-service/middleware"
+	"user-service/utils"
+
+	"github.com/gajare/BAJAR-App/Backend/pkg/authz"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// useSigningKeys loads fresh keys for algs into the keyring; the first one
+// signs, the rest activate an hour from now.
+func useSigningKeys(t *testing.T, algs ...string) []utils.SigningKey {
+	t.Helper()
+	var keys []utils.SigningKey
+	for i, alg := range algs {
+		at := time.Now().Add(-time.Minute)
+		if i > 0 {
+			at = time.Now().Add(time.Hour)
+		}
+		k, err := utils.NewSigningKey(alg, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, k)
+	}
+	utils.SetSigningKeys(keys)
+	return keys
+}
+
+func TestSessionTokenCarriesSessionID(t *testing.T) {
+	useSigningKeys(t, authz.AlgEdDSA)
+	token, err := utils.CreateSessionToken(7, 42, "support", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := utils.ParseToken(token)
+	if err != nil || claims.Subject != "7" || claims.SessionID != 42 {
+		t.Fatalf("ParseToken = %+v, %v", claims, err)
+	}
+
+	expired, _ := utils.CreateSessionToken(7, 42, "support", -time.Second)
+	if _, err := utils.ParseToken(expired); err == nil {
+		t.Error("expired access token accepted")
+	}
+	useSigningKeys(t, authz.AlgEdDSA)
+	if _, err := utils.ParseToken(token); err == nil {
+		t.Error("token signed with an unknown key accepted")
+	}
+}
+
+func TestLoginInTheSecondOfAResetIsAccepted(t *testing.T) {
+	// iat has whole seconds only; the reset's cutoff does not.
+	reset := time.Now().Truncate(time.Second).Add(900 * time.Millisecond)
+	claims := &utils.AccessClaims{}
+	claims.IssuedAt = jwt.NewNumericDate(reset.Add(50 * time.Millisecond))
+	if claims.IssuedBefore(reset) {
+		t.Error("token issued in the same second as the reset rejected")
+	}
+	claims.IssuedAt = jwt.NewNumericDate(reset.Add(-time.Second))
+	if !claims.IssuedBefore(reset) {
+		t.Error("token issued a second before the reset accepted")
+	}
+	if !(&utils.AccessClaims{}).IssuedBefore(reset) {
+		t.Error("token without iat accepted")
+	}
+}
+
+func TestSessionTokenCarriesRole(t *testing.T) {
+	useSigningKeys(t, authz.AlgRS256)
+	jwks := httptest.NewServer(http.HandlerFunc(controller.JWKS))
+	defer jwks.Close()
+
+	token, err := utils.CreateSessionToken(7, 42, authz.RoleFinance, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The other services verify with the shared authz package.
+	claims, err := authz.NewJWKSVerifier(jwks.URL, time.Minute).Verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := claims.Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.UserID != 7 || id.Role != authz.RoleFinance || !id.Can(authz.PermFinanceWrite) || id.Can(authz.PermUsersWrite) {
+		t.Fatalf("identity = %+v", id)
+	}
+}
+
+func TestServiceTokenCarriesScopes(t *testing.T) {
+	useSigningKeys(t, authz.AlgEdDSA)
+	jwks := httptest.NewServer(http.HandlerFunc(controller.JWKS))
+	defer jwks.Close()
+
+	token, err := utils.CreateServiceToken("svc_orders", []string{authz.PermPaymentsCreate}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := authz.NewJWKSVerifier(jwks.URL, time.Minute).Verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := claims.Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !id.IsService() || id.ClientID != "svc_orders" || id.UserID != 0 ||
+		!id.Can(authz.PermPaymentsCreate) || id.Can(authz.PermPaymentsRead) {
+		t.Fatalf("identity = %+v", id)
+	}
+
+	// User-only routes turn service tokens away before touching the database.
+	handler := middleware.JwtAuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("service token on a user route: %d", w.Code)
+	}
+}
+
+func TestRotatedKeyIsPublishedBeforeItSigns(t *testing.T) {
+	keys := useSigningKeys(t, authz.AlgRS256, authz.AlgEdDSA)
+	if got := len(utils.JWKS().Keys); got != 2 {
+		t.Fatalf("JWKS has %d keys, want both", got)
+	}
+	jwks := httptest.NewServer(http.HandlerFunc(controller.JWKS))
+	defer jwks.Close()
+	verifier := authz.NewJWKSVerifier(jwks.URL, time.Hour)
+
+	before, _ := utils.CreateSessionToken(7, 1, authz.RoleCustomer, time.Minute)
+	if _, err := verifier.Verify(before); err != nil {
+		t.Fatalf("token from the active key: %v", err)
+	}
+
+	// The pending key activates; the verifier's cached set already has it.
+	jwks.Close()
+	keys[1].ActivatesAt = time.Now().Add(-time.Second)
+	utils.SetSigningKeys(keys)
+	after, _ := utils.CreateSessionToken(7, 1, authz.RoleCustomer, time.Minute)
+	if _, err := verifier.Verify(after); err != nil {
+		t.Fatalf("token from the rotated key: %v", err)
+	}
+	if _, err := utils.ParseToken(before); err != nil {
+		t.Errorf("token from the retired key rejected: %v", err)
+	}
+}
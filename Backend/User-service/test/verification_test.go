@@ -0,0 +1,79 @@
+package test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+	"user-service/utils"
+
+	"github.com/gajare/BAJAR-App/Backend/pkg/authz"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	good := map[string]string{
+		"Alice@Example.com":   "alice@example.com",
+		"  bob@mail.shop.in ": "bob@mail.shop.in",
+	}
+	for in, want := range good {
+		if got, err := utils.NormalizeEmail(in); err != nil || got != want {
+			t.Errorf("NormalizeEmail(%q) = %q, %v", in, got, err)
+		}
+	}
+	for _, bad := range []string{"", "alice", "alice@", "alice@localhost", "Alice <alice@example.com>"} {
+		if _, err := utils.NormalizeEmail(bad); err == nil {
+			t.Errorf("NormalizeEmail(%q) should fail", bad)
+		}
+	}
+}
+
+func TestVerificationTokenIsSinglePurpose(t *testing.T) {
+	useSigningKeys(t, authz.AlgEdDSA)
+	token, err := utils.CreatePurposeToken(utils.AudienceVerifyEmail, 7, "alice@example.com", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, email, err := utils.ParsePurposeToken(utils.AudienceVerifyEmail, token)
+	if err != nil || id != 7 || email != "alice@example.com" {
+		t.Fatalf("ParsePurposeToken = %d, %q, %v", id, email, err)
+	}
+	if _, err := utils.ParseToken(token); err == nil {
+		t.Error("verification token accepted as an access token")
+	}
+	if _, _, err := utils.ParsePurposeToken("reset-password", token); err == nil {
+		t.Error("verification token accepted for another purpose")
+	}
+
+	access, _ := utils.CreateToken(7, time.Hour)
+	if _, _, err := utils.ParsePurposeToken(utils.AudienceVerifyEmail, access); err == nil {
+		t.Error("access token accepted as a verification token")
+	}
+	expired, _ := utils.CreatePurposeToken(utils.AudienceVerifyEmail, 7, "alice@example.com", -time.Minute)
+	if _, _, err := utils.ParsePurposeToken(utils.AudienceVerifyEmail, expired); err == nil {
+		t.Error("expired verification token accepted")
+	}
+}
+
+func TestKeyedLimiter(t *testing.T) {
+	lim := utils.NewKeyedLimiter(time.Hour, 2)
+	if !lim.Allow("a") || !lim.Allow("a") {
+		t.Fatal("burst should be allowed")
+	}
+	if lim.Allow("a") {
+		t.Error("third request within the hour should be limited")
+	}
+	if !lim.Allow("b") {
+		t.Error("keys should be limited independently")
+	}
+}
+
+func TestKeyedLimiterForgetsIdleKeys(t *testing.T) {
+	lim := utils.NewKeyedLimiter(time.Millisecond, 2)
+	for i := 0; i < 100; i++ {
+		lim.Allow(fmt.Sprint("key", i))
+	}
+	time.Sleep(5 * time.Millisecond)
+	lim.Allow("fresh")
+	if n := lim.Len(); n != 1 {
+		t.Errorf("%d keys tracked, want the idle ones dropped", n)
+	}
+}
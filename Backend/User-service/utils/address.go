@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrInvalidPhone      = errors.New("invalid phone number")
+	ErrInvalidPostalCode = errors.New("invalid postal code")
+	ErrInvalidCountry    = errors.New("country must be a two-letter ISO code")
+)
+
+var phoneDigits = regexp.MustCompile(`^\+?[0-9]{7,15}$`)
+
+// NormalizePhone strips spaces, dashes, dots and brackets and checks what
+// is left is 7 to 15 digits with an optional leading +.
+func NormalizePhone(s string) (string, error) {
+	s = strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "").Replace(s)
+	if !phoneDigits.MatchString(s) {
+		return "", ErrInvalidPhone
+	}
+	return s, nil
+}
+
+// postalCodes holds the formats of the countries we ship to most; others
+// get a loose check.
+var postalCodes = map[string]*regexp.Regexp{
+	"IN": regexp.MustCompile(`^[1-9][0-9]{5}$`),
+	"US": regexp.MustCompile(`^[0-9]{5}(-[0-9]{4})?$`),
+	"GB": regexp.MustCompile(`^[A-Z]{1,2}[0-9][A-Z0-9]? [0-9][A-Z]{2}$`),
+	"CA": regexp.MustCompile(`^[A-Z][0-9][A-Z] [0-9][A-Z][0-9]$`),
+	"DE": regexp.MustCompile(`^[0-9]{5}$`),
+	"FR": regexp.MustCompile(`^[0-9]{5}$`),
+	"AU": regexp.MustCompile(`^[0-9]{4}$`),
+	"SG": regexp.MustCompile(`^[0-9]{6}$`),
+	"AE": regexp.MustCompile(`^$`), // no postal codes
+}
+
+var loosePostal = regexp.MustCompile(`^[A-Z0-9][A-Z0-9 -]{1,9}$`)
+
+// NormalizeCountry upper-cases a two-letter country code.
+func NormalizeCountry(s string) (string, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if len(s) != 2 || s[0] < 'A' || s[0] > 'Z' || s[1] < 'A' || s[1] > 'Z' {
+		return "", ErrInvalidCountry
+	}
+	return s, nil
+}
+
+// NormalizePostalCode upper-cases code, fixes the spacing of GB and CA
+// codes and checks it against the country's format.
+func NormalizePostalCode(country, code string) (string, error) {
+	code = strings.ToUpper(strings.Join(strings.Fields(code), " "))
+	if country == "GB" || country == "CA" {
+		compact := strings.ReplaceAll(code, " ", "")
+		if len(compact) > 3 {
+			code = compact[:len(compact)-3] + " " + compact[len(compact)-3:]
+		}
+	}
+	if re, ok := postalCodes[country]; ok {
+		if !re.MatchString(code) {
+			return "", ErrInvalidPostalCode
+		}
+		return code, nil
+	}
+	if !loosePostal.MatchString(code) {
+		return "", ErrInvalidPostalCode
+	}
+	return code, nil
+}
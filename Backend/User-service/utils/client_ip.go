@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+var (
+	proxiesMu      sync.RWMutex
+	trustedProxies []*net.IPNet
+)
+
+// SetTrustedProxies sets the proxies, as CIDRs or single addresses, whose
+// X-Forwarded-For headers ClientIP believes.
+func SetTrustedProxies(cidrs []string) error {
+	var nets []*net.IPNet
+	for _, c := range cidrs {
+		if !strings.Contains(c, "/") {
+			if ip := net.ParseIP(c); ip != nil && ip.To4() != nil {
+				c += "/32"
+			} else {
+				c += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return err
+		}
+		nets = append(nets, n)
+	}
+	proxiesMu.Lock()
+	trustedProxies = nets
+	proxiesMu.Unlock()
+	return nil
+}
+
+func trusted(ip net.IP) bool {
+	proxiesMu.RLock()
+	defer proxiesMu.RUnlock()
+	for _, n := range trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP is the address r came from. When the connection is from a
+// trusted proxy, X-Forwarded-For is read from the right, skipping trusted
+// proxies; the first other address is the client. Addresses further left
+// were set by the client and cannot be believed.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !trusted(ip) {
+		return host
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			break
+		}
+		ip = hop
+		if !trusted(hop) {
+			break
+		}
+	}
+	return ip.String()
+}
@@ -1,14 +1,13 @@
 package utils
 
-import "golang.org/x/crypto/bcrypt"
-
 
+// HashPassword hashes pw with the algorithm set by SetPasswordHashing.
 func HashPassword(pw string) (string, error) {
-b, err := bcrypt.GenerateFromPassword([]byte(pw), bcrypt.DefaultCost)
-return string(b), err
+return hashPassword(pw)
 }
 
 
+// CheckPassword accepts bcrypt and argon2id hashes.
 func CheckPassword(hash, pw string) error {
-return bcrypt.CompareHashAndPassword([]byte(hash), []byte(pw))
-}
\ No newline at end of file
+return checkPassword(hash, pw)
+}
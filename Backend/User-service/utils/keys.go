@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gajare/BAJAR-App/Backend/pkg/authz"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// SigningKey is a private key tokens are signed with. A key is published
+// in the JWKS as soon as it is loaded but only signs from ActivatesAt on,
+// so other services have seen it before the first token needs it.
+type SigningKey struct {
+	KID         string
+	Algorithm   string
+	Private     crypto.Signer
+	ActivatesAt time.Time
+}
+
+var ErrNoSigningKey = errors.New("no active signing key")
+
+var keyring struct {
+	sync.RWMutex
+	keys []SigningKey // newest ActivatesAt first
+}
+
+// SetSigningKeys replaces the keys tokens are signed and verified with.
+func SetSigningKeys(keys []SigningKey) {
+	sorted := append([]SigningKey(nil), keys...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ActivatesAt.After(sorted[j].ActivatesAt) })
+	keyring.Lock()
+	keyring.keys = sorted
+	keyring.Unlock()
+}
+
+// NewSigningKey generates a key for alg (RS256 or EdDSA) with a random kid.
+func NewSigningKey(alg string, activatesAt time.Time) (SigningKey, error) {
+	var priv crypto.Signer
+	var err error
+	switch alg {
+	case authz.AlgRS256:
+		priv, err = rsa.GenerateKey(rand.Reader, 2048)
+	case authz.AlgEdDSA:
+		_, priv, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return SigningKey{}, fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	if err != nil {
+		return SigningKey{}, err
+	}
+	kid := make([]byte, 8)
+	if _, err := rand.Read(kid); err != nil {
+		return SigningKey{}, err
+	}
+	return SigningKey{KID: hex.EncodeToString(kid), Algorithm: alg, Private: priv, ActivatesAt: activatesAt}, nil
+}
+
+// PrivatePEM encodes the private key as PKCS#8 PEM for storage.
+func (k SigningKey) PrivatePEM() (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(k.Private)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// ParseSigningKey reads a key stored with PrivatePEM.
+func ParseSigningKey(kid, alg, privatePEM string, activatesAt time.Time) (SigningKey, error) {
+	block, _ := pem.Decode([]byte(privatePEM))
+	if block == nil {
+		return SigningKey{}, fmt.Errorf("key %s: no PEM data", kid)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return SigningKey{}, fmt.Errorf("key %s: %w", kid, err)
+	}
+	priv, ok := parsed.(crypto.Signer)
+	if !ok || authz.SigningMethod(alg) == nil {
+		return SigningKey{}, fmt.Errorf("key %s: unsupported key", kid)
+	}
+	return SigningKey{KID: kid, Algorithm: alg, Private: priv, ActivatesAt: activatesAt}, nil
+}
+
+// JWKS returns the public keys of every loaded key, including ones that
+// are not active yet or no longer sign.
+func JWKS() authz.JWKSet {
+	keyring.RLock()
+	defer keyring.RUnlock()
+	set := authz.JWKSet{Keys: []authz.JWK{}}
+	for _, k := range keyring.keys {
+		if jwk, err := authz.NewJWK(k.KID, k.Private.Public()); err == nil {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
+
+// signToken signs claims with the newest active key.
+func signToken(claims jwt.Claims) (string, error) {
+	now := time.Now()
+	keyring.RLock()
+	var signer *SigningKey
+	for i := range keyring.keys {
+		if !keyring.keys[i].ActivatesAt.After(now) {
+			signer = &keyring.keys[i]
+			break
+		}
+	}
+	keyring.RUnlock()
+	if signer == nil {
+		return "", ErrNoSigningKey
+	}
+	tok := jwt.NewWithClaims(authz.SigningMethod(signer.Algorithm), claims)
+	tok.Header["kid"] = signer.KID
+	return tok.SignedString(signer.Private)
+}
+
+// verificationKey finds the public key for kid; it is used as the keyfunc
+// lookup for every token the service parses.
+func verificationKey(kid string) (string, interface{}, bool) {
+	keyring.RLock()
+	defer keyring.RUnlock()
+	for _, k := range keyring.keys {
+		if k.KID == kid {
+			return k.Algorithm, k.Private.Public(), true
+		}
+	}
+	return "", nil, false
+}
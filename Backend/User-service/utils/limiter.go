@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// KeyedLimiter rate limits per key, e.g. per email address.
+type KeyedLimiter struct {
+	mu       sync.Mutex
+	limiters map[string]*keyedEntry
+	every    time.Duration
+	burst    int
+	// idle is how long until an unused limiter has refilled completely;
+	// it then behaves like a new one and can be dropped.
+	idle      time.Duration
+	lastSweep time.Time
+}
+
+type keyedEntry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// NewKeyedLimiter allows burst events per key, refilled one per every.
+func NewKeyedLimiter(every time.Duration, burst int) *KeyedLimiter {
+	return &KeyedLimiter{
+		limiters:  map[string]*keyedEntry{},
+		every:     every,
+		burst:     burst,
+		idle:      every * time.Duration(burst),
+		lastSweep: time.Now(),
+	}
+}
+
+func (l *KeyedLimiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.sweep(now)
+	e, ok := l.limiters[key]
+	if !ok {
+		e = &keyedEntry{limiter: rate.NewLimiter(rate.Every(l.every), l.burst)}
+		l.limiters[key] = e
+	}
+	e.lastSeen = now
+	return e.limiter.AllowN(now, 1)
+}
+
+// Len is the number of keys currently tracked.
+func (l *KeyedLimiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.limiters)
+}
+
+// sweep drops the limiters that have been idle long enough to be full
+// again, at most once per idle period, so the map does not grow with
+// every key ever seen. It must be called with mu held.
+func (l *KeyedLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.idle {
+		return
+	}
+	for key, e := range l.limiters {
+		if now.Sub(e.lastSeen) >= l.idle {
+			delete(l.limiters, key)
+		}
+	}
+	l.lastSweep = now
+}
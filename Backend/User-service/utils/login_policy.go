@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"time"
+	"user-service/models"
+)
+
+// LoginPolicy decides how failed logins slow down and lock a key. From
+// DelayAfter failures on, each attempt must wait twice as long as the
+// previous one (from one second up to MaxDelay); LockAfter failures lock
+// the key for LockFor. Failures are forgotten after Window without one.
+type LoginPolicy struct {
+	DelayAfter int
+	MaxDelay   time.Duration
+	LockAfter  int
+	LockFor    time.Duration
+	Window     time.Duration
+}
+
+// RetryAfter is how long t must wait before the next attempt; zero means
+// it may try now. locked reports a lockout rather than a delay.
+func (p LoginPolicy) RetryAfter(t models.LoginThrottle, now time.Time) (wait time.Duration, locked bool) {
+	if t.LockedUntil != nil {
+		if now.Before(*t.LockedUntil) {
+			return t.LockedUntil.Sub(now), true
+		}
+		// A lock that ran out starts the count over.
+		return 0, false
+	}
+	if p.expired(t, now) || t.Failures < p.DelayAfter {
+		return 0, false
+	}
+	delay := p.MaxDelay
+	if shift := t.Failures - p.DelayAfter; shift < 30 && time.Second<<shift < p.MaxDelay {
+		delay = time.Second << shift
+	}
+	if next := t.LastFailureAt.Add(delay); now.Before(next) {
+		return next.Sub(now), false
+	}
+	return 0, false
+}
+
+// Fail records a failed attempt on t and reports whether it just locked.
+func (p LoginPolicy) Fail(t *models.LoginThrottle, now time.Time) bool {
+	if p.expired(*t, now) || (t.LockedUntil != nil && !now.Before(*t.LockedUntil)) {
+		t.Failures, t.LockedUntil = 0, nil
+	}
+	t.Failures++
+	t.LastFailureAt = now
+	if p.LockAfter > 0 && t.Failures >= p.LockAfter && t.LockedUntil == nil {
+		until := now.Add(p.LockFor)
+		t.LockedUntil = &until
+		return true
+	}
+	return false
+}
+
+func (p LoginPolicy) expired(t models.LoginThrottle, now time.Time) bool {
+	return t.Failures == 0 || now.Sub(t.LastFailureAt) > p.Window
+}
@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password hash algorithms.
+const (
+	HashBcrypt   = "bcrypt"
+	HashArgon2id = "argon2id"
+)
+
+var ErrEmptyPassword = errors.New("password is required")
+
+// argon2Params are the argon2id settings new hashes use.
+type argon2Params struct {
+	Time    uint32
+	Memory  uint32 // KiB
+	Threads uint8
+}
+
+var defaultArgon2 = argon2Params{Time: 3, Memory: 64 * 1024, Threads: 2}
+
+var hashing = struct {
+	sync.RWMutex
+	algorithm  string
+	bcryptCost int
+	argon2     argon2Params
+}{algorithm: HashBcrypt, bcryptCost: bcrypt.DefaultCost, argon2: defaultArgon2}
+
+// SetPasswordHashing chooses how new password hashes are made. Existing
+// hashes keep working; NeedsRehash reports the ones made differently.
+func SetPasswordHashing(algorithm string, bcryptCost int) error {
+	if algorithm != HashBcrypt && algorithm != HashArgon2id {
+		return fmt.Errorf("unknown password hash algorithm %q", algorithm)
+	}
+	if bcryptCost < bcrypt.MinCost || bcryptCost > bcrypt.MaxCost {
+		return fmt.Errorf("bcrypt cost %d out of range", bcryptCost)
+	}
+	hashing.Lock()
+	defer hashing.Unlock()
+	hashing.algorithm, hashing.bcryptCost = algorithm, bcryptCost
+	return nil
+}
+
+func hashPassword(pw string) (string, error) {
+	if pw == "" {
+		return "", ErrEmptyPassword
+	}
+	hashing.RLock()
+	algorithm, cost, params := hashing.algorithm, hashing.bcryptCost, hashing.argon2
+	hashing.RUnlock()
+	if algorithm == HashArgon2id {
+		return hashArgon2id(pw, params)
+	}
+	b, err := bcrypt.GenerateFromPassword([]byte(pw), cost)
+	return string(b), err
+}
+
+// hashArgon2id encodes in the PHC string format:
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>.
+func hashArgon2id(pw string, p argon2Params) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(pw), salt, p.Time, p.Memory, p.Threads, 32)
+	enc := base64.RawStdEncoding
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Time, p.Threads, enc.EncodeToString(salt), enc.EncodeToString(key)), nil
+}
+
+var errBadArgon2Hash = errors.New("malformed argon2id hash")
+
+func parseArgon2id(hash string) (argon2Params, []byte, []byte, error) {
+	var p argon2Params
+	var version int
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != HashArgon2id {
+		return p, nil, nil, errBadArgon2Hash
+	}
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, errBadArgon2Hash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Time, &p.Threads); err != nil {
+		return p, nil, nil, errBadArgon2Hash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, errBadArgon2Hash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, errBadArgon2Hash
+	}
+	return p, salt, key, nil
+}
+
+func checkPassword(hash, pw string) error {
+	if !strings.HasPrefix(hash, "$argon2id$") {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(pw))
+	}
+	p, salt, key, err := parseArgon2id(hash)
+	if err != nil {
+		return err
+	}
+	got := argon2.IDKey([]byte(pw), salt, p.Time, p.Memory, p.Threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(got, key) != 1 {
+		return bcrypt.ErrMismatchedHashAndPassword
+	}
+	return nil
+}
+
+// NeedsRehash reports whether hash was made with another algorithm or
+// weaker settings than new hashes use, so it should be replaced the next
+// time the password is known.
+func NeedsRehash(hash string) bool {
+	hashing.RLock()
+	defer hashing.RUnlock()
+	if strings.HasPrefix(hash, "$argon2id$") {
+		p, _, _, err := parseArgon2id(hash)
+		return hashing.algorithm != HashArgon2id || err != nil || p != hashing.argon2
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return hashing.algorithm != HashBcrypt || err != nil || cost < hashing.bcryptCost
+}
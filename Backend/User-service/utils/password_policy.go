@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PasswordPolicy is what a new password must meet. MinClasses counts
+// lowercase letters, uppercase letters, digits and other characters.
+// MaxBytes guards bcrypt, which only reads the first 72 bytes.
+type PasswordPolicy struct {
+	MinLength        int
+	MaxBytes         int
+	MinClasses       int
+	DisallowPersonal bool
+}
+
+var ErrPasswordBreached = errors.New("this password has appeared in a data breach; choose another")
+
+// Check returns why pw is not acceptable for the account with email and
+// name, or nil.
+func (p PasswordPolicy) Check(pw, email, name string) error {
+	if pw == "" {
+		return ErrEmptyPassword
+	}
+	if n := utf8.RuneCountInString(pw); n < p.MinLength {
+		return fmt.Errorf("password must be at least %d characters", p.MinLength)
+	}
+	if p.MaxBytes > 0 && len(pw) > p.MaxBytes {
+		return fmt.Errorf("password must be at most %d bytes", p.MaxBytes)
+	}
+	var lower, upper, digit, other bool
+	for _, r := range pw {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+	classes := 0
+	for _, has := range []bool{lower, upper, digit, other} {
+		if has {
+			classes++
+		}
+	}
+	if classes < p.MinClasses {
+		return fmt.Errorf("password must mix at least %d of lowercase, uppercase, digits and symbols", p.MinClasses)
+	}
+	if p.DisallowPersonal {
+		lowered := strings.ToLower(pw)
+		local, _, _ := strings.Cut(strings.ToLower(email), "@")
+		personal := append([]string{local}, strings.Fields(strings.ToLower(name))...)
+		for _, word := range personal {
+			if len(word) >= 3 && strings.Contains(lowered, word) {
+				return errors.New("password must not contain your name or email address")
+			}
+		}
+	}
+	return nil
+}
+
+// BreachList looks passwords up in a local copy of a breached password
+// corpus kept as k-anonymity range files, as served by the Pwned
+// Passwords range API: Dir holds one file per 5 hex digit SHA-1 prefix
+// (e.g. "21BD1" or "21BD1.txt"), each listing "SUFFIX:COUNT" lines. Only
+// the file for the password's prefix is read.
+type BreachList struct {
+	Dir string
+}
+
+func NewBreachList(dir string) *BreachList {
+	return &BreachList{Dir: dir}
+}
+
+// Breached reports whether pw is in the list.
+func (b *BreachList) Breached(pw string) (bool, error) {
+	sum := sha1.Sum([]byte(pw))
+	digest := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := digest[:5], digest[5:]
+	f, err := os.Open(filepath.Join(b.Dir, prefix))
+	if errors.Is(err, os.ErrNotExist) {
+		f, err = os.Open(filepath.Join(b.Dir, prefix+".txt"))
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line, _, _ := strings.Cut(s.Text(), ":")
+		if strings.EqualFold(strings.TrimSpace(line), suffix) {
+			return true, nil
+		}
+	}
+	return false, s.Err()
+}
@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrInvalidPAN   = errors.New("invalid PAN")
+	ErrInvalidGSTIN = errors.New("invalid GSTIN")
+)
+
+var (
+	panFormat   = regexp.MustCompile(`^[A-Z]{5}[0-9]{4}[A-Z]$`)
+	gstinFormat = regexp.MustCompile(`^[0-9]{2}[A-Z]{5}[0-9]{4}[A-Z][1-9A-Z]Z[0-9A-Z]$`)
+)
+
+const base36 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// NormalizePAN upper-cases an Indian permanent account number and checks
+// its format, e.g. "AAPFU0939F".
+func NormalizePAN(s string) (string, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if !panFormat.MatchString(s) {
+		return "", ErrInvalidPAN
+	}
+	return s, nil
+}
+
+// NormalizeGSTIN upper-cases a GST identification number and checks its
+// format and check character. A GSTIN embeds the holder's PAN, which must
+// be pan.
+func NormalizeGSTIN(s, pan string) (string, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if !gstinFormat.MatchString(s) || s[2:12] != pan {
+		return "", ErrInvalidGSTIN
+	}
+	sum := 0
+	for i, c := range s[:14] {
+		v := strings.IndexRune(base36, c) * (i%2 + 1)
+		sum += v/36 + v%36
+	}
+	if s[14] != base36[(36-sum%36)%36] {
+		return "", ErrInvalidGSTIN
+	}
+	return s, nil
+}
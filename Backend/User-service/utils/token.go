@@ -6,40 +6,104 @@ import (
 "time"
 
 
+"github.com/gajare/BAJAR-App/Backend/pkg/authz"
 "github.com/golang-jwt/jwt/v4"
 )
 
 
-var JWTSecret []byte
+// AccessClaims are the claims of an access token. SessionID ties the token
+// to the login session it was issued for, so it dies with the session.
+// Role and Permissions are read by the other services (see authz.Claims).
+// Permissions is always set, even when empty: a privileged user who has not
+// passed two-factor authentication gets none of the role's permissions.
+// AMR lists how the user authenticated ("pwd", "otp"). ClientID is set
+// instead of a user on tokens issued to service clients. SellerID and
+// SellerRole are set for members of a seller that may sell.
+type AccessClaims struct {
+jwt.RegisteredClaims
+Role string `json:"role,omitempty"`
+Permissions []string `json:"perms"`
+AMR []string `json:"amr,omitempty"`
+SessionID uint `json:"sid,omitempty"`
+ClientID string `json:"client_id,omitempty"`
+SellerID uint `json:"seller_id,omitempty"`
+SellerRole string `json:"seller_role,omitempty"`
+}
 
 
-func InitJWT(secret string) {
-JWTSecret = []byte(secret)
+func CreateToken(userID uint, ttl time.Duration) (string, error) {
+return CreateSessionToken(userID, 0, authz.RoleCustomer, ttl)
 }
 
 
-func CreateToken(userID uint, ttl time.Duration) (string, error) {
-tok := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+func CreateSessionToken(userID, sessionID uint, role string, ttl time.Duration) (string, error) {
+return CreateAccessToken(userID, sessionID, role, authz.Permissions(role), nil, ttl)
+}
+
+
+func CreateAccessToken(userID, sessionID uint, role string, perms, amr []string, ttl time.Duration) (string, error) {
+return CreateSellerAccessToken(userID, sessionID, role, perms, amr, 0, "", ttl)
+}
+
+
+// CreateSellerAccessToken is CreateAccessToken for a member of seller
+// sellerID, who works for it in sellerRole.
+func CreateSellerAccessToken(userID, sessionID uint, role string, perms, amr []string, sellerID uint, sellerRole string, ttl time.Duration) (string, error) {
+if perms == nil {
+perms = []string{}
+}
+return signToken(AccessClaims{
+RegisteredClaims: jwt.RegisteredClaims{
 Subject: fmt.Sprint(userID),
 ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
 IssuedAt: jwt.NewNumericDate(time.Now()),
+},
+Role: role,
+Permissions: perms,
+AMR: amr,
+SessionID: sessionID,
+SellerID: sellerID,
+SellerRole: sellerRole,
 })
-return tok.SignedString(JWTSecret)
 }
 
 
-func ParseToken(tokenStr string) (*jwt.RegisteredClaims, error) {
-tok, err := jwt.ParseWithClaims(tokenStr, &jwt.RegisteredClaims{}, func(t *jwt.Token) (interface{}, error) {
-if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-return nil, errors.New("unexpected signing method")
+// CreateServiceToken issues a client credentials token whose permissions
+// are the client's scopes.
+func CreateServiceToken(clientID string, scopes []string, ttl time.Duration) (string, error) {
+if scopes == nil {
+scopes = []string{}
 }
-return JWTSecret, nil
+return signToken(AccessClaims{
+RegisteredClaims: jwt.RegisteredClaims{
+Subject: authz.ServiceSubject(clientID),
+ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+IssuedAt: jwt.NewNumericDate(time.Now()),
+},
+Permissions: scopes,
+ClientID: clientID,
 })
+}
+
+
+func ParseToken(tokenStr string) (*AccessClaims, error) {
+tok, err := jwt.ParseWithClaims(tokenStr, &AccessClaims{}, authz.KeyFunc(verificationKey))
 if err != nil {
 return nil, err
 }
-if claims, ok := tok.Claims.(*jwt.RegisteredClaims); ok && tok.Valid {
+claims, ok := tok.Claims.(*AccessClaims)
+// tokens with an audience are single-purpose links (see verification.go), never access tokens
+if ok && tok.Valid && len(claims.Audience) == 0 {
 return claims, nil
 }
 return nil, errors.New("invalid token")
-}
\ No newline at end of file
+}
+
+
+// IssuedBefore reports whether the token was issued before cutoff, e.g. a
+// password reset. iat only has whole seconds, so a token issued in the
+// same second as cutoff counts as issued after it; otherwise a login right
+// after the reset would be rejected.
+func (c *AccessClaims) IssuedBefore(cutoff time.Time) bool {
+return c.IssuedAt == nil || c.IssuedAt.Time.Before(cutoff.Truncate(time.Second))
+}
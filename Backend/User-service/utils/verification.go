@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gajare/BAJAR-App/Backend/pkg/authz"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// AudienceVerifyEmail marks tokens that may only be used to verify an email
+// address. ParseToken refuses them as access tokens.
+const AudienceVerifyEmail = "verify-email"
+
+// AudienceChangeEmail marks links that confirm a new email address; the
+// token carries the new address.
+const AudienceChangeEmail = "change-email"
+
+// AudienceMFALogin marks the challenge token Login hands out when the user
+// still has to enter a second factor.
+const AudienceMFALogin = "mfa-login"
+
+var ErrInvalidEmail = errors.New("invalid email address")
+
+type purposeClaims struct {
+	jwt.RegisteredClaims
+	Email string `json:"email"`
+}
+
+// NormalizeEmail checks that s is a plain address ("a@b.c", no display
+// name) and returns it lower-cased.
+func NormalizeEmail(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	addr, err := mail.ParseAddress(s)
+	if err != nil || addr.Address != s || !strings.Contains(s[strings.LastIndex(s, "@"):], ".") {
+		return "", ErrInvalidEmail
+	}
+	return strings.ToLower(s), nil
+}
+
+// CreatePurposeToken signs a short-lived token bound to the user, the email
+// address it was issued for and a single purpose (audience).
+func CreatePurposeToken(purpose string, userID uint, email string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	return signToken(purposeClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   fmt.Sprint(userID),
+			Audience:  jwt.ClaimStrings{purpose},
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+		Email: email,
+	})
+}
+
+// ParsePurposeToken checks a token made by CreatePurposeToken for purpose
+// and returns the user ID and email address in it.
+func ParsePurposeToken(purpose, tokenStr string) (uint, string, error) {
+	var claims purposeClaims
+	tok, err := jwt.ParseWithClaims(tokenStr, &claims, authz.KeyFunc(verificationKey))
+	if err != nil {
+		return 0, "", err
+	}
+	if !tok.Valid || !claims.VerifyAudience(purpose, true) {
+		return 0, "", errors.New("invalid token")
+	}
+	id, err := strconv.ParseUint(claims.Subject, 10, 64)
+	if err != nil {
+		return 0, "", errors.New("invalid token")
+	}
+	return uint(id), claims.Email, nil
+}
@@ -0,0 +1,215 @@
+package authz
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Signing algorithms the User-service may use.
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// JWK is a public signing key in JSON Web Key form (RFC 7517). Only RSA
+// and Ed25519 keys are supported.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKSet is the document served at /.well-known/jwks.json.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewJWK describes the public half of an RSA or Ed25519 key.
+func NewJWK(kid string, pub crypto.PublicKey) (JWK, error) {
+	enc := base64.RawURLEncoding
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return JWK{Kty: "RSA", Kid: kid, Use: "sig", Alg: AlgRS256,
+			N: enc.EncodeToString(k.N.Bytes()), E: enc.EncodeToString(big.NewInt(int64(k.E)).Bytes())}, nil
+	case ed25519.PublicKey:
+		return JWK{Kty: "OKP", Kid: kid, Use: "sig", Alg: AlgEdDSA, Crv: "Ed25519", X: enc.EncodeToString(k)}, nil
+	}
+	return JWK{}, fmt.Errorf("unsupported key type %T", pub)
+}
+
+// PublicKey decodes the key.
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	enc := base64.RawURLEncoding
+	switch {
+	case k.Kty == "RSA" && k.Alg == AlgRS256:
+		n, err := enc.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := enc.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case k.Kty == "OKP" && k.Crv == "Ed25519" && k.Alg == AlgEdDSA:
+		x, err := enc.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key %q (%s/%s)", k.Kid, k.Kty, k.Alg)
+}
+
+// SigningMethod returns the jwt method for alg, or nil if alg is not one
+// of the supported asymmetric algorithms.
+func SigningMethod(alg string) jwt.SigningMethod {
+	switch alg {
+	case AlgRS256:
+		return jwt.SigningMethodRS256
+	case AlgEdDSA:
+		return jwt.SigningMethodEdDSA
+	}
+	return nil
+}
+
+// KeyFunc returns a jwt.Keyfunc that looks the token's kid up with find
+// and checks the token was signed with the algorithm of that key.
+func KeyFunc(find func(kid string) (alg string, key interface{}, ok bool)) jwt.Keyfunc {
+	return func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		if kid == "" {
+			return nil, errors.New("token has no key id")
+		}
+		alg, key, ok := find(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+		if t.Method.Alg() != alg {
+			return nil, errors.New("unexpected signing method")
+		}
+		return key, nil
+	}
+}
+
+// KeySet is a cached copy of a remote JWKS. The set is refetched once it
+// is older than ttl, and when asked for an unknown kid (at most every
+// minRefetch) so freshly rotated keys are picked up without waiting for
+// the cache to expire. If the URL cannot be reached, the cached keys stay
+// in use.
+type KeySet struct {
+	url        string
+	client     *http.Client
+	ttl        time.Duration
+	minRefetch time.Duration
+
+	mu        sync.Mutex
+	keys      map[string]jwkEntry
+	fetchedAt time.Time
+	triedAt   time.Time
+}
+
+type jwkEntry struct {
+	alg string
+	key crypto.PublicKey
+}
+
+func NewKeySet(url string, ttl time.Duration) *KeySet {
+	return &KeySet{
+		url:        url,
+		client:     &http.Client{Timeout: 5 * time.Second},
+		ttl:        ttl,
+		minRefetch: 30 * time.Second,
+	}
+}
+
+// Lookup returns the algorithm and public key for kid; it fits KeyFunc.
+func (s *KeySet) Lookup(kid string) (string, interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	e, ok := s.keys[kid]
+	stale := now.Sub(s.fetchedAt) > s.ttl
+	if (!ok || stale) && now.Sub(s.triedAt) >= s.minRefetch {
+		s.triedAt = now
+		if keys, err := s.fetch(); err == nil {
+			s.keys, s.fetchedAt = keys, now
+			e, ok = s.keys[kid]
+		}
+	}
+	return e.alg, e.key, ok
+}
+
+func (s *KeySet) fetch() (map[string]jwkEntry, error) {
+	resp, err := s.client.Get(s.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch %s: %s", s.url, resp.Status)
+	}
+	var set JWKSet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]jwkEntry, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		// alg is optional in a JWK; these key types only have one.
+		if k.Alg == "" && k.Kty == "RSA" {
+			k.Alg = AlgRS256
+		} else if k.Alg == "" && k.Kty == "OKP" {
+			k.Alg = AlgEdDSA
+		}
+		pub, err := k.PublicKey()
+		if err != nil {
+			continue // skip keys we cannot use rather than failing all of them
+		}
+		keys[k.Kid] = jwkEntry{alg: k.Alg, key: pub}
+	}
+	return keys, nil
+}
+
+// JWKSVerifier verifies access tokens against the keys the User-service
+// publishes, cached in a KeySet.
+type JWKSVerifier struct {
+	keys *KeySet
+}
+
+func NewJWKSVerifier(url string, ttl time.Duration) *JWKSVerifier {
+	return &JWKSVerifier{keys: NewKeySet(url, ttl)}
+}
+
+func (v *JWKSVerifier) Verify(token string) (*Claims, error) {
+	var c Claims
+	tok, err := jwt.ParseWithClaims(token, &c, KeyFunc(v.keys.Lookup))
+	if err != nil || !tok.Valid {
+		return nil, ErrInvalidToken
+	}
+	// Tokens with an audience are single-purpose links, not access tokens.
+	if len(c.Audience) > 0 {
+		return nil, ErrInvalidToken
+	}
+	return &c, nil
+}
@@ -0,0 +1,66 @@
+package authz
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+type contextKey struct{}
+
+// WithIdentity stores the caller in ctx.
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the caller stored by Authenticate.
+func FromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(contextKey{}).(Identity)
+	return id, ok
+}
+
+// Authenticate rejects requests without a valid bearer token and stores the
+// caller's Identity in the request context.
+func Authenticate(v TokenVerifier) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			auth := r.Header.Get("Authorization")
+			if !strings.HasPrefix(auth, "Bearer ") {
+				http.Error(w, "missing token", http.StatusUnauthorized)
+				return
+			}
+			claims, err := v.Verify(strings.TrimPrefix(auth, "Bearer "))
+			if err != nil {
+				http.Error(w, "invalid token", http.StatusUnauthorized)
+				return
+			}
+			id, err := claims.Identity()
+			if err != nil {
+				http.Error(w, "invalid token", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), id)))
+		})
+	}
+}
+
+// Require must run after Authenticate; it only lets callers holding all of
+// perms through.
+func Require(perms ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id, ok := FromContext(r.Context())
+			if !ok {
+				http.Error(w, "missing token", http.StatusUnauthorized)
+				return
+			}
+			for _, p := range perms {
+				if !id.Can(p) {
+					http.Error(w, "forbidden", http.StatusForbidden)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+// Package authz holds the roles and permissions issued by User-service and
+// the HTTP middleware the other services use to enforce them.
+package authz
+
+// Roles stored on User-service users.
+const (
+	RoleCustomer       = "customer"
+	RoleSupport        = "support"
+	RoleCatalogManager = "catalog_manager"
+	RoleFinance        = "finance"
+	RoleDelivery       = "delivery"
+	RoleAdmin          = "admin"
+)
+
+// Permissions are "<resource>:<action>".
+const (
+	PermUsersRead  = "users:read"  // list and look up any user
+	PermUsersWrite = "users:write" // change roles, suspend and reset users
+	PermAuditRead  = "audit:read"  // read the admin audit log
+
+	PermClientsWrite = "clients:write" // issue and revoke service credentials
+
+	PermCatalogWrite = "catalog:write" // create, edit and delete products and categories
+
+	PermPaymentsRead    = "payments:read"    // see every user's payments
+	PermPaymentsCreate  = "payments:create"  // take payments for any user, e.g. the order service
+	PermPaymentsWrite   = "payments:write"   // update status, refund, capture and void
+	PermPaymentsCollect = "payments:collect" // mark cash on delivery as collected
+	PermRiskReview      = "risk:review"      // work the review queue and blocklist
+	PermFinanceWrite    = "finance:write"    // exchange rates, reconciliation, gift cards
+
+	PermOrdersRead  = "orders:read"
+	PermOrdersWrite = "orders:write"
+
+	PermSellersReview = "sellers:review" // approve seller KYC, suspend sellers
+	PermPayoutsRead   = "payouts:read"   // see seller earnings and payouts
+	PermPayoutsWrite  = "payouts:write"  // run payouts and mark them paid
+)
+
+var rolePermissions = map[string][]string{
+	RoleCustomer:       {},
+	RoleSupport:        {PermUsersRead, PermPaymentsRead, PermPaymentsWrite, PermRiskReview, PermOrdersRead, PermOrdersWrite},
+	RoleCatalogManager: {PermCatalogWrite},
+	RoleFinance:        {PermPaymentsRead, PermPaymentsWrite, PermFinanceWrite, PermOrdersRead, PermPayoutsRead, PermPayoutsWrite},
+	RoleDelivery:       {PermPaymentsCollect, PermOrdersRead},
+	RoleAdmin: {
+		PermUsersRead, PermUsersWrite, PermAuditRead, PermCatalogWrite,
+		PermPaymentsRead, PermPaymentsCreate, PermPaymentsWrite, PermPaymentsCollect, PermRiskReview, PermFinanceWrite,
+		PermOrdersRead, PermOrdersWrite, PermClientsWrite,
+		PermSellersReview, PermPayoutsRead, PermPayoutsWrite,
+	},
+}
+
+// ValidPermission reports whether perm is one of the Perm constants; the
+// admin role holds every one.
+func ValidPermission(perm string) bool {
+	for _, p := range rolePermissions[RoleAdmin] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidRole reports whether role is one of the Role constants.
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// Permissions returns what role may do. Unknown roles may do nothing.
+func Permissions(role string) []string {
+	return append([]string{}, rolePermissions[role]...)
+}
+
+// Roles lists every role.
+func Roles() []string {
+	return []string{RoleCustomer, RoleSupport, RoleCatalogManager, RoleFinance, RoleDelivery, RoleAdmin}
+}
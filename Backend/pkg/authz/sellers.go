@@ -0,0 +1,49 @@
+package authz
+
+// Roles a user can hold in a seller organisation. Unlike the platform
+// roles they only reach the seller's own products, orders and payouts.
+const (
+	SellerRoleOwner   = "owner"   // everything, including the members
+	SellerRoleCatalog = "catalog" // the seller's products
+	SellerRoleFinance = "finance" // the seller's earnings and payouts
+)
+
+var sellerRolePermissions = map[string][]string{
+	SellerRoleOwner:   {PermCatalogWrite, PermPayoutsRead},
+	SellerRoleCatalog: {PermCatalogWrite},
+	SellerRoleFinance: {PermPayoutsRead},
+}
+
+// ValidSellerRole reports whether role is one of the SellerRole constants.
+func ValidSellerRole(role string) bool {
+	_, ok := sellerRolePermissions[role]
+	return ok
+}
+
+// SellerRoles lists every seller role.
+func SellerRoles() []string {
+	return []string{SellerRoleOwner, SellerRoleCatalog, SellerRoleFinance}
+}
+
+// IsSeller reports whether the caller acts for a seller.
+func (i Identity) IsSeller() bool {
+	return i.SellerID != 0
+}
+
+// CanForSeller reports whether the caller may use perm on what seller
+// sellerID owns: it holds perm platform-wide, or works for that seller in
+// a role that grants it. Seller 0 is the platform itself.
+func (i Identity) CanForSeller(sellerID uint64, perm string) bool {
+	if i.Can(perm) {
+		return true
+	}
+	if sellerID == 0 || i.SellerID != sellerID {
+		return false
+	}
+	for _, p := range sellerRolePermissions[i.SellerRole] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
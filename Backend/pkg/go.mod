@@ -0,0 +1,5 @@
+module github.com/gajare/BAJAR-App/Backend/pkg
+
+go 1.20
+
+require github.com/golang-jwt/jwt/v4 v4.5.2
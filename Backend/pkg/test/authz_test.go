@@ -0,0 +1,101 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gajare/BAJAR-App/Backend/pkg/authz"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func token(t *testing.T, secret string, claims jwt.MapClaims) string {
+	t.Helper()
+	claims["exp"] = time.Now().Add(time.Hour).Unix()
+	s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func serve(h http.Handler, bearer string) int {
+	req := httptest.NewRequest("POST", "/products", nil)
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestRequirePermission(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h := authz.Authenticate(authz.NewHMACVerifier("secret"))(authz.Require(authz.PermCatalogWrite)(ok))
+
+	cases := []struct {
+		name   string
+		bearer string
+		want   int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"wrong secret", token(t, "other", jwt.MapClaims{"sub": "1", "role": "admin"}), http.StatusUnauthorized},
+		{"customer", token(t, "secret", jwt.MapClaims{"sub": "1"}), http.StatusForbidden},
+		{"finance", token(t, "secret", jwt.MapClaims{"sub": "1", "role": authz.RoleFinance}), http.StatusForbidden},
+		{"catalog manager", token(t, "secret", jwt.MapClaims{"sub": "1", "role": authz.RoleCatalogManager}), http.StatusOK},
+		{"explicit perms", token(t, "secret", jwt.MapClaims{"sub": "1", "role": "customer", "perms": []string{authz.PermCatalogWrite}}), http.StatusOK},
+		{"purpose token", token(t, "secret", jwt.MapClaims{"sub": "1", "role": "admin", "aud": "verify-email"}), http.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		if got := serve(h, c.bearer); got != c.want {
+			t.Errorf("%s: status %d, want %d", c.name, got, c.want)
+		}
+	}
+}
+
+func TestRolePermissions(t *testing.T) {
+	for _, role := range authz.Roles() {
+		if !authz.ValidRole(role) {
+			t.Errorf("%s should be valid", role)
+		}
+	}
+	if authz.ValidRole("root") || len(authz.Permissions("root")) != 0 {
+		t.Error("unknown roles should have no permissions")
+	}
+	admin := authz.Identity{Role: authz.RoleAdmin, Permissions: authz.Permissions(authz.RoleAdmin)}
+	for _, role := range authz.Roles() {
+		for _, p := range authz.Permissions(role) {
+			if !admin.Can(p) {
+				t.Errorf("admin lacks %s held by %s", p, role)
+			}
+		}
+	}
+}
+
+func TestCanForSeller(t *testing.T) {
+	claims := authz.Claims{SellerID: 4, SellerRole: authz.SellerRoleCatalog}
+	claims.Subject = "1"
+	seller, err := claims.Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !seller.IsSeller() || !seller.CanForSeller(4, authz.PermCatalogWrite) {
+		t.Errorf("catalog member cannot manage its own products: %+v", seller)
+	}
+	if seller.CanForSeller(5, authz.PermCatalogWrite) || seller.CanForSeller(0, authz.PermCatalogWrite) {
+		t.Error("catalog member can manage products of others")
+	}
+	if seller.CanForSeller(4, authz.PermPayoutsRead) {
+		t.Error("catalog member can see payouts")
+	}
+	manager := authz.Identity{Role: authz.RoleCatalogManager, Permissions: authz.Permissions(authz.RoleCatalogManager)}
+	if !manager.CanForSeller(4, authz.PermCatalogWrite) || !manager.CanForSeller(0, authz.PermCatalogWrite) {
+		t.Error("catalog manager cannot manage every seller's products")
+	}
+
+	claims.SellerRole = "root"
+	if id, _ := claims.Identity(); id.IsSeller() {
+		t.Error("unknown seller role accepted")
+	}
+}
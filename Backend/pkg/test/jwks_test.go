@@ -0,0 +1,77 @@
+package test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gajare/BAJAR-App/Backend/pkg/authz"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func signWithKid(t *testing.T, method jwt.SigningMethod, kid string, key interface{}) string {
+	t.Helper()
+	tok := jwt.NewWithClaims(method, jwt.MapClaims{"sub": "7", "role": "finance", "exp": time.Now().Add(time.Hour).Unix()})
+	tok.Header["kid"] = kid
+	s, err := tok.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestJWKSVerifier(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var set authz.JWKSet
+	for kid, pub := range map[string]interface{}{"rsa": &rsaKey.PublicKey, "ed": edKey.Public()} {
+		jwk, err := authz.NewJWK(kid, pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	fetches := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		json.NewEncoder(w).Encode(set)
+	}))
+	defer srv.Close()
+	v := authz.NewJWKSVerifier(srv.URL, time.Hour)
+
+	for _, tok := range []string{
+		signWithKid(t, jwt.SigningMethodRS256, "rsa", rsaKey),
+		signWithKid(t, jwt.SigningMethodEdDSA, "ed", edKey),
+	} {
+		c, err := v.Verify(tok)
+		if err != nil || c.Subject != "7" || c.Role != "finance" {
+			t.Fatalf("Verify = %+v, %v", c, err)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("key set fetched %d times, want it cached", fetches)
+	}
+
+	rejected := map[string]string{
+		"unknown kid": signWithKid(t, jwt.SigningMethodRS256, "other", rsaKey),
+		"wrong alg":   signWithKid(t, jwt.SigningMethodRS256, "ed", rsaKey),
+		"hmac":        signWithKid(t, jwt.SigningMethodHS256, "rsa", []byte("secret")),
+		"no kid":      token(t, "secret", jwt.MapClaims{"sub": "7"}),
+	}
+	for name, tok := range rejected {
+		if _, err := v.Verify(tok); err == nil {
+			t.Errorf("%s: token accepted", name)
+		}
+	}
+}
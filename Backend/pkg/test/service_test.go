@@ -0,0 +1,73 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gajare/BAJAR-App/Backend/pkg/authz"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestServiceTokenIdentity(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, _ := authz.FromContext(r.Context())
+		if !id.IsService() || id.ClientID != "svc_orders" || id.UserID != 0 {
+			w.WriteHeader(http.StatusTeapot)
+		}
+	})
+	h := authz.Authenticate(authz.NewHMACVerifier("secret"))(authz.Require(authz.PermPaymentsCreate)(ok))
+
+	scoped := token(t, "secret", jwt.MapClaims{"sub": "client:svc_orders", "client_id": "svc_orders", "perms": []string{authz.PermPaymentsCreate}})
+	if code := serve(h, scoped); code != http.StatusOK {
+		t.Errorf("service with scope: %d", code)
+	}
+	unscoped := token(t, "secret", jwt.MapClaims{"sub": "client:svc_orders", "client_id": "svc_orders"})
+	if code := serve(h, unscoped); code != http.StatusForbidden {
+		t.Errorf("service without scope: %d", code)
+	}
+	spoofed := token(t, "secret", jwt.MapClaims{"sub": "1", "client_id": "svc_orders", "perms": []string{authz.PermPaymentsCreate}})
+	if code := serve(h, spoofed); code != http.StatusUnauthorized {
+		t.Errorf("client_id with a user subject: %d", code)
+	}
+}
+
+func TestClientCredentialsCachesToken(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		id, secret, _ := r.BasicAuth()
+		if r.FormValue("grant_type") != "client_credentials" || id != "svc_orders" || secret != "s3cret" || r.FormValue("scope") != "payments:create" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "tok", "token_type": "Bearer", "expires_in": 300})
+	}))
+	defer srv.Close()
+
+	cc := authz.NewClientCredentials(srv.URL, "svc_orders", "s3cret", authz.PermPaymentsCreate)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer api.Close()
+	client := &http.Client{Transport: cc.Transport(nil)}
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(api.URL)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("request %d: %v %v", i, resp, err)
+		}
+		resp.Body.Close()
+	}
+	if calls != 1 {
+		t.Errorf("token endpoint called %d times", calls)
+	}
+
+	bad := authz.NewClientCredentials(srv.URL, "svc_orders", "wrong")
+	if _, err := bad.Token(); err == nil {
+		t.Error("bad secret accepted")
+	}
+}